@@ -0,0 +1,146 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CSV is a dataset of the records of a CSV file, cached in memory once parsed or parsed from the file on each read.
+type CSV struct {
+	filename string
+	parse    ParseFunc
+	length   int
+	cache    []Record
+}
+
+// NewCSV reads a CSV file, parsing and caching every record when cache is set, otherwise only counting them.
+// An uncached dataset reads the file from the start on each Get, so it is only suited to iterating in file order.
+func NewCSV(filename string, parse ParseFunc, cache bool) (*CSV, error) {
+	d := &CSV{filename: filename, parse: parse}
+	it := d.stream(!cache)
+	defer func() {
+		_ = it.Close()
+	}()
+	for it.Next() {
+		d.length++
+		if cache {
+			d.cache = append(d.cache, it.Record())
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if d.length == 0 {
+		return nil, fmt.Errorf("no records in file %s", filename)
+	}
+	return d, nil
+}
+
+// Len returns the number of records in the file.
+func (d *CSV) Len() int {
+	return d.length
+}
+
+// Get returns the parsed record at an index.
+func (d *CSV) Get(i int) (Record, error) {
+	if err := checkIndex(i, d.length); err != nil {
+		return Record{}, err
+	}
+	if d.cache != nil {
+		return d.cache[i], nil
+	}
+	it := d.stream(false)
+	defer func() {
+		_ = it.Close()
+	}()
+	for it.Next() {
+		if it.Index() == i {
+			return it.Record(), nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, fmt.Errorf("file %s has fewer than %d records", d.filename, i+1)
+}
+
+// Iterate returns an iterator over the records at the indices of order, or over every record in order when nil,
+// streaming the file when the records are not cached.
+func (d *CSV) Iterate(order []int) Iterator {
+	if d.cache != nil || order != nil {
+		return NewIterator(d, order)
+	}
+	return d.stream(false)
+}
+
+// stream returns an iterator reading the file in order, only counting the records without parsing them when skipParse is set.
+func (d *CSV) stream(skipParse bool) *csvIterator {
+	return &csvIterator{d: d, index: -1, skipParse: skipParse}
+}
+
+// csvIterator streams the records of a CSV file in order.
+type csvIterator struct {
+	d         *CSV
+	f         *os.File
+	r         *csv.Reader
+	index     int
+	record    Record
+	err       error
+	skipParse bool
+}
+
+func (it *csvIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.r == nil {
+		if it.f, it.err = os.Open(it.d.filename); it.err != nil {
+			it.err = fmt.Errorf("opening file: %v", it.err)
+			return false
+		}
+		it.r = csv.NewReader(bufio.NewReader(it.f))
+	}
+	raw, err := it.r.Read()
+	if err == io.EOF {
+		return false
+	}
+	it.index++
+	if err != nil {
+		it.err = fmt.Errorf("reading line %d: %v", it.index+1, err)
+		return false
+	}
+	if it.skipParse {
+		return true
+	}
+	inputs, targets, err := it.d.parse(raw)
+	if err != nil {
+		it.err = fmt.Errorf("parsing line %d: %v", it.index+1, err)
+		return false
+	}
+	it.record = Record{Inputs: inputs, Targets: targets}
+	return true
+}
+
+func (it *csvIterator) Record() Record {
+	return it.record
+}
+
+func (it *csvIterator) Index() int {
+	return it.index
+}
+
+func (it *csvIterator) Err() error {
+	return it.err
+}
+
+func (it *csvIterator) Close() error {
+	if it.f == nil {
+		return nil
+	}
+	err := it.f.Close()
+	it.f = nil
+	return err
+}
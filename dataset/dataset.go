@@ -0,0 +1,122 @@
+// Package dataset provides indexed access to the parsed records of a dataset, iteration in file or shuffled order,
+// and CSV datasets optionally cached in memory.
+package dataset
+
+import (
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"golang.org/x/exp/rand"
+)
+
+// Record is the network inputs and target outputs parsed from a dataset record.
+type Record struct {
+	Inputs  []float64
+	Targets []float64
+}
+
+// ParseFunc parses a raw record into network inputs and target outputs.
+type ParseFunc func(record []string) (inputs, targets []float64, err error)
+
+// Dataset is an indexed collection of records.
+type Dataset interface {
+	// Len returns the number of records.
+	Len() int
+	// Get returns the record at an index from 0 to Len()-1.
+	Get(i int) (Record, error)
+	// Iterate returns an iterator over the records at the indices of order, or over every record in order when nil.
+	Iterate(order []int) Iterator
+}
+
+// Iterator steps through the records of a dataset, Next must be called before reading the first record.
+type Iterator interface {
+	// Next advances to the next record, returning false at the end of the records or on an error.
+	Next() bool
+	// Record returns the current record.
+	Record() Record
+	// Index returns the dataset index of the current record.
+	Index() int
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+	// Close releases any resources held by the iterator.
+	Close() error
+}
+
+// NewIterator returns an iterator reading the records at the indices of order from a dataset by Get,
+// or every record in order when nil.
+func NewIterator(d Dataset, order []int) Iterator {
+	if order == nil {
+		order = make([]int, d.Len())
+		for i := range order {
+			order[i] = i
+		}
+	}
+	return &indexIterator{d: d, order: order, pos: -1}
+}
+
+type indexIterator struct {
+	d      Dataset
+	order  []int
+	pos    int
+	record Record
+	err    error
+}
+
+func (it *indexIterator) Next() bool {
+	if it.err != nil || it.pos+1 >= len(it.order) {
+		return false
+	}
+	it.pos++
+	if it.record, it.err = it.d.Get(it.order[it.pos]); it.err != nil {
+		return false
+	}
+	return true
+}
+
+func (it *indexIterator) Record() Record {
+	return it.record
+}
+
+func (it *indexIterator) Index() int {
+	return it.order[it.pos]
+}
+
+func (it *indexIterator) Err() error {
+	return it.err
+}
+
+func (it *indexIterator) Close() error {
+	return nil
+}
+
+// Shuffler draws a random order of records for each epoch from a seeded source, so runs with the same seed
+// visit the records in the same orders, and a shuffler resumed at the State of another continues its orders.
+type Shuffler struct {
+	src  *network.CountingSource
+	rand *rand.Rand
+}
+
+// NewShuffler returns a shuffler drawing orders from the source of r at its state.
+func NewShuffler(r network.Rand) *Shuffler {
+	src := r.CountingSource()
+	return &Shuffler{src: src, rand: rand.New(src)}
+}
+
+// Order returns a random permutation of the indices of a dataset.
+func (s *Shuffler) Order(d Dataset) []int {
+	return s.rand.Perm(d.Len())
+}
+
+// State returns the number of values drawn from the source, the state to resume the shuffler at.
+func (s *Shuffler) State() uint64 {
+	return s.src.State()
+}
+
+// checkIndex returns an error if an index is outside a dataset of a length.
+func checkIndex(i, length int) error {
+	if i < 0 || i >= length {
+		return fmt.Errorf("record index %d is outside the %d dataset records", i, length)
+	}
+	return nil
+}
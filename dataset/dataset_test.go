@@ -0,0 +1,134 @@
+package dataset_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/dataset"
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func parseRecord(record []string) ([]float64, []float64, error) {
+	values := make([]float64, len(record))
+	for i, s := range record {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = v
+	}
+	return values[:len(values)-1], values[len(values)-1:], nil
+}
+
+func writeFile(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "data.csv")
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func firstInputs(t *testing.T, it dataset.Iterator) []float64 {
+	defer func() {
+		_ = it.Close()
+	}()
+	var inputs []float64
+	for it.Next() {
+		inputs = append(inputs, it.Record().Inputs[0])
+		if want := float64(it.Index() + 1); it.Record().Inputs[0] != want {
+			t.Errorf("Index() = %d for record %v", it.Index(), it.Record())
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return inputs
+}
+
+func TestCSV(t *testing.T) {
+	filename := writeFile(t, "1,10\n2,20\n3,30\n4,40\n")
+	for _, cache := range []bool{true, false} {
+		t.Run("cache "+strconv.FormatBool(cache), func(t *testing.T) {
+			d, err := dataset.NewCSV(filename, parseRecord, cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Len() != 4 {
+				t.Errorf("Len() = %d, want 4", d.Len())
+			}
+			r, err := d.Get(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (dataset.Record{Inputs: []float64{3}, Targets: []float64{30}}); !reflect.DeepEqual(r, want) {
+				t.Errorf("Get(2) = %v, want %v", r, want)
+			}
+			if _, err := d.Get(4); err == nil {
+				t.Errorf("Get(4) error = nil, want error")
+			}
+			if got, want := firstInputs(t, d.Iterate(nil)), []float64{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Iterate(nil) inputs = %v, want %v", got, want)
+			}
+			if got, want := firstInputs(t, d.Iterate([]int{3, 0, 2})), []float64{4, 1, 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Iterate() inputs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewCSV_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		cache    bool
+	}{
+		{name: "missing file", filename: filepath.Join(t.TempDir(), "missing.csv")},
+		{name: "empty file", filename: writeFile(t, "")},
+		{name: "mismatched columns", filename: writeFile(t, "1,2\n1,2,3\n")},
+		{name: "cached parse error", filename: writeFile(t, "1,2\nx,2\n"), cache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := dataset.NewCSV(tt.filename, parseRecord, tt.cache); err == nil {
+				t.Errorf("NewCSV() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestShuffler_Order(t *testing.T) {
+	d, err := dataset.NewCSV(writeFile(t, "1,0\n2,0\n3,0\n4,0\n5,0\n6,0\n7,0\n8,0\n"), parseRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := dataset.NewShuffler(network.Rand{Seed: 1})
+	first, second := s.Order(d), s.Order(d)
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("Order() = %v for two epochs, want different orders", first)
+	}
+	for _, order := range [][]int{first, second} {
+		seen := map[int]bool{}
+		for _, i := range order {
+			seen[i] = true
+		}
+		if len(order) != d.Len() || len(seen) != d.Len() {
+			t.Errorf("Order() = %v, want a permutation of %d indices", order, d.Len())
+		}
+	}
+	replay := dataset.NewShuffler(network.Rand{Seed: 1})
+	if got := [][]int{replay.Order(d), replay.Order(d)}; !reflect.DeepEqual(got, [][]int{first, second}) {
+		t.Errorf("Order() with the same seed = %v, want %v", got, [][]int{first, second})
+	}
+	resumed := dataset.NewShuffler(network.Rand{Seed: 1})
+	resumed.Order(d)
+	resumed = dataset.NewShuffler(network.Rand{Seed: 1, State: resumed.State()})
+	if got := resumed.Order(d); !reflect.DeepEqual(got, second) {
+		t.Errorf("Order() resumed at State() = %v, want the second order %v", got, second)
+	}
+	if s.State() == 0 || s.State() != replay.State() {
+		t.Errorf("State() = %d after two orders, want the nonzero state %d of the replay", s.State(), replay.State())
+	}
+}
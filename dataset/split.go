@@ -0,0 +1,77 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"golang.org/x/exp/rand"
+)
+
+// Subset is a dataset of the records of another dataset at a list of indices.
+type Subset struct {
+	Dataset Dataset
+	Indices []int
+}
+
+// Len returns the number of indices.
+func (s *Subset) Len() int {
+	return len(s.Indices)
+}
+
+// Get returns the record of the dataset at the index of position i.
+func (s *Subset) Get(i int) (Record, error) {
+	if err := checkIndex(i, len(s.Indices)); err != nil {
+		return Record{}, err
+	}
+	return s.Dataset.Get(s.Indices[i])
+}
+
+// Iterate returns an iterator over the subset records at the positions of order, or over every record in order when nil.
+func (s *Subset) Iterate(order []int) Iterator {
+	return NewIterator(s, order)
+}
+
+// Split divides a dataset into training and validation subsets, holding out a fraction of the records for validation
+// drawn from the source of r, so the split is the same for the same seed. With a class function the fraction is held
+// out of the records of each class, so both subsets keep the class proportions of the dataset.
+// Both subsets keep the dataset order of their records.
+func Split(d Dataset, fraction float64, r network.Rand, class func(Record) int) (train, validation *Subset, err error) {
+	if fraction <= 0 || fraction >= 1 {
+		return nil, nil, fmt.Errorf("validation fraction %v must be between 0 and 1", fraction)
+	}
+	groups := map[int][]int{}
+	for i := 0; i < d.Len(); i++ {
+		key := 0
+		if class != nil {
+			record, err := d.Get(i)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = class(record)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	keys := make([]int, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	rnd := rand.New(r.GetSource())
+	train, validation = &Subset{Dataset: d}, &Subset{Dataset: d}
+	for _, k := range keys {
+		indices := groups[k]
+		rnd.Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] })
+		held := int(math.Round(fraction * float64(len(indices))))
+		validation.Indices = append(validation.Indices, indices[:held]...)
+		train.Indices = append(train.Indices, indices[held:]...)
+	}
+	if len(train.Indices) == 0 || len(validation.Indices) == 0 {
+		return nil, nil, fmt.Errorf("validation fraction %v of %d records leaves %d training and %d validation records", fraction, d.Len(), len(train.Indices), len(validation.Indices))
+	}
+	sort.Ints(train.Indices)
+	sort.Ints(validation.Indices)
+	return train, validation, nil
+}
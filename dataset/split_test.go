@@ -0,0 +1,98 @@
+package dataset_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/dataset"
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+// classDataset writes 20 records of class 0 and 10 of class 1, with the record number as input.
+func classDataset(t *testing.T) dataset.Dataset {
+	var b strings.Builder
+	for i := 1; i <= 30; i++ {
+		class := 0
+		if i%3 == 0 {
+			class = 1
+		}
+		fmt.Fprintf(&b, "%d,%d\n", i, class)
+	}
+	d, err := dataset.NewCSV(writeFile(t, b.String()), parseRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func targetClass(r dataset.Record) int {
+	return int(r.Targets[0])
+}
+
+func TestSplit(t *testing.T) {
+	d := classDataset(t)
+	train, validation, err := dataset.Split(d, 0.2, network.Rand{Seed: 1}, targetClass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if train.Len() != 24 || validation.Len() != 6 {
+		t.Fatalf("Split() lengths = %d and %d, want 24 and 6", train.Len(), validation.Len())
+	}
+	classes := map[int]int{}
+	it := validation.Iterate(nil)
+	for it.Next() {
+		classes[targetClass(it.Record())]++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]int{0: 4, 1: 2}; !reflect.DeepEqual(classes, want) {
+		t.Errorf("Split() validation classes = %v, want %v", classes, want)
+	}
+	seen := map[int]bool{}
+	for _, s := range []*dataset.Subset{train, validation} {
+		for i, index := range s.Indices {
+			if seen[index] {
+				t.Errorf("Split() record %d is in both subsets", index)
+			}
+			seen[index] = true
+			if i > 0 && index < s.Indices[i-1] {
+				t.Errorf("Split() indices %v are not in dataset order", s.Indices)
+				break
+			}
+		}
+	}
+	r, err := validation.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := float64(validation.Indices[0] + 1); r.Inputs[0] != want {
+		t.Errorf("Get(0) input = %v, want %v", r.Inputs[0], want)
+	}
+
+	_, again, err := dataset.Split(d, 0.2, network.Rand{Seed: 1}, targetClass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Indices, validation.Indices) {
+		t.Errorf("Split() with the same seed = %v, want %v", again.Indices, validation.Indices)
+	}
+	_, other, err := dataset.Split(d, 0.2, network.Rand{Seed: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Len() != 6 || reflect.DeepEqual(other.Indices, validation.Indices) {
+		t.Errorf("Split() with another seed = %v, want 6 other indices than %v", other.Indices, validation.Indices)
+	}
+}
+
+func TestSplit_Error(t *testing.T) {
+	d := classDataset(t)
+	for _, fraction := range []float64{0, 1, 1.5, 0.01} {
+		if _, _, err := dataset.Split(d, fraction, network.Rand{}, nil); err == nil {
+			t.Errorf("Split() fraction %v error = nil, want error", fraction)
+		}
+	}
+}
@@ -7,40 +7,117 @@ import (
 	"strings"
 
 	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
 )
 
 type runConfig struct {
 	Action           string
 	ModelFile        string
 	DataSetFile      string
+	SchemaFile       string
 	Epochs           int
+	BatchSize        int
 	TestLogBatch     int
 	TrainLogBatch    int
 	TestParseRecord  parseRecordFunc
 	TrainParseRecord parseRecordFunc
+	HeadColumns      map[string][]int
+	TargetColumns    []int
+	Labels           []string
+	LabelDelimiter   string
+	ScaleTargets     bool
+	Cache            bool
+	ValidationFile   string
+	ValidationSplit  float64
+	Monitor          string
+	Patience         int
+	MinDelta         float64
+	Shuffle          bool
+	Pipeline         *preprocess.Pipeline // converts raw records for presets and schemas, fitted to the dataset of new networks
 	networkConfig
 }
 
 type networkConfig struct {
-	Activation        network.ActivationType
+	Activations       []network.ActivationType
+	Loss              network.LossType
 	LearningRate      float64
+	Schedule          network.ScheduleConfig
+	Optimizer         network.OptimizerConfig
+	L1                float64
+	L2                float64
+	WeightDecay       float64
+	Dropout           []float64
+	Normalization     []network.NormType
+	NormMomentum      float64
+	Init              network.InitType
+	InitValue         float64
 	RandomSeed        uint64
 	InputCount        int
 	OutputCount       int
 	HiddenLayerCounts []int
+	LayerSpec         string
+	InputShape        network.ImageShape
+	Heads             []network.HeadConfig
+	Task              network.TaskType
+	Thresholds        []float64
 }
 
 func parseCmdFlags() (runConfig, error) {
-	preset := flag.String("preset", "iris", "Preset 'mnist' or 'iris' dataset processing. Source dataset must be downloaded first, please see readme.")
+	preset := flag.String("preset", "iris", "Preset 'mnist', 'iris' or 'csv' dataset processing. Source dataset must be downloaded first, please see readme. The 'csv' preset reads numeric columns, with the target columns set by -target-columns, or for each head by -head-columns, and all other columns as inputs.")
 	action := flag.String("action", "", "Action 'train' or 'test' against the dataset.")
 	model := flag.String("model", "models/default.model", "File path of network model to load and save. If it doesn't exist a new network will be created.")
+	schema := flag.String("schema", "", "File path of a JSON or YAML dataset schema declaring the role, type and normalization of each dataset column, used instead of -preset with the input and output counts inferred from the schema. Needs -dataset.")
 	dataset := flag.String("dataset", "", "File path of source dataset. (default \"datasets/{preset}_{action}.csv\")")
 	epochs := flag.Int("epochs", 0, "Number of training epochs. Ignored if not training.")
-	activationVal := flag.String("activation", "sigmoid", "Activation function 'sigmoid' or 'tanh'.")
+	cache := flag.Bool("cache", true, "Cache the parsed training dataset in memory instead of reading the file each epoch.")
+	shuffle := flag.Bool("shuffle", false, "Shuffle the training records each epoch in an order drawn from a stream of the network random seed, resumed where the last training run of the model stopped. Needs -cache.")
+	validationFile := flag.String("validation-file", "", "File path of a validation dataset to score the network on after each training epoch, logging the validation loss and accuracy.")
+	validationSplit := flag.Float64("validation-split", 0, "Fraction of the training dataset held out to score the network on after each training epoch instead of -validation-file, drawn from the network random seed and stratified by class for classification. Needs -cache.")
+	patience := flag.Int("early-stopping-patience", 0, "Epochs without improvement of the -early-stopping-monitor metric before training stops early, restoring and saving the weights of the best epoch while the model keeps counting every epoch trained. Needs -validation-split or -validation-file. (default 0, disabled)")
+	monitor := flag.String("early-stopping-monitor", monitorValidationLoss, fmt.Sprintf("Validation metric monitored by early stopping '%s' or '%s'.", monitorValidationLoss, monitorValidationAccuracy))
+	minDelta := flag.Float64("early-stopping-min-delta", 0, "Minimum change of the -early-stopping-monitor metric counted as an improvement, as a fraction for accuracy.")
+	batchSize := flag.Int("batch-size", 1, "Number of records averaged into each training update. Ignored if not training.")
+	activationsStr := flag.String("activation", "sigmoid", fmt.Sprintf("Comma-separated list of activation functions for each hidden layer followed by the output layer. A single value applies to all layers. Parameters follow a colon, like 'leaky-relu:0.2'. Options: '%s'.", strings.Join(network.ActivationNames(), "', '")))
+	lossVal := flag.String("loss", "mse", "Loss function 'mse', 'mae', 'binary-crossentropy', 'categorical-crossentropy' or 'huber'.")
 	learningRate := flag.Float64("learning-rate", 0.1, "Network learning rate.")
+	scheduleVal := flag.String("schedule", "constant", fmt.Sprintf("Learning rate schedule for new networks %s.", quotedScheduleTypes()))
+	scheduleStepSize := flag.Uint64("schedule-step-size", 10, "Epochs between 'step' decays, or epochs in the first 'cosine' cycle.")
+	scheduleGamma := flag.Float64("schedule-gamma", 0, "Learning rate multiplier for each 'step' or 'plateau' decay (default 0.1), or each epoch of 'exponential' decay (default 0.95).")
+	scheduleMinRate := flag.Float64("schedule-min-rate", 0, "Lowest learning rate reached by 'cosine' annealing and 'plateau' reductions.")
+	scheduleCycleMult := flag.Float64("schedule-cycle-mult", 1, "Cycle length multiplier after each 'cosine' warm restart.")
+	schedulePatience := flag.Uint64("schedule-patience", 10, "Epochs without loss improvement before a 'plateau' reduction.")
+	scheduleThreshold := flag.Float64("schedule-threshold", 0, "Minimum loss decrease counted as a 'plateau' improvement.")
+	warmupSteps := flag.Uint64("warmup-steps", 0, "Training steps to linearly increase the learning rate from zero, combined with any schedule.")
+	optimizerVal := flag.String("optimizer", "sgd", "Optimizer 'sgd', 'momentum', 'nesterov', 'rmsprop', 'adagrad' or 'adam'.")
+	momentum := flag.Float64("momentum", 0.9, "Momentum coefficient for the 'momentum' and 'nesterov' optimizers.")
+	decay := flag.Float64("rmsprop-decay", 0.9, "Squared gradient moving average decay for the 'rmsprop' optimizer.")
+	beta1 := flag.Float64("beta1", 0.9, "First moment decay for the 'adam' optimizer.")
+	beta2 := flag.Float64("beta2", 0.999, "Second moment decay for the 'adam' optimizer.")
+	epsilon := flag.Float64("epsilon", 1e-8, "Numerical stability term for the 'rmsprop', 'adagrad' and 'adam' optimizers.")
+	l1 := flag.Float64("l1", 0, "L1 weight penalty coefficient for new networks.")
+	l2 := flag.Float64("l2", 0, "L2 weight penalty coefficient for new networks.")
+	weightDecay := flag.Float64("weight-decay", 0, "Decoupled weight decay for new networks, the fraction of each weight removed per training step scaled by the learning rate.")
+	dropoutStr := flag.String("dropout", "", "Comma-separated list of training dropout rates for the outputs of each hidden layer of new networks. A single value applies to all hidden layers.")
+	normalizationStr := flag.String("normalization", "", fmt.Sprintf("Comma-separated list of normalizations for the weighted inputs of each hidden layer of new networks %s or 'none'. A single value applies to all hidden layers. Batch normalization needs a batch size above 1.", quotedNormTypes()))
+	normMomentum := flag.Float64("norm-momentum", 0.9, "Running statistic momentum for 'batch' normalization.")
+	initVal := flag.String("init", "uniform", fmt.Sprintf("Weight initialization for new networks %s.", quotedInitTypes()))
+	initValue := flag.Float64("init-value", 0, "Weight value for 'constant' initialization.")
 	randomSeed := flag.Uint64("random-seed", 0, "Seed for random weight generation.")
 	hiddenLayerCountsStr := flag.String("hidden-layer-counts", "", "Comma-separated list of neuron counts for hidden layers.")
+	layerSpec := flag.String("layers", "", "Comma-separated spec of the hidden layers of new networks, used instead of -hidden-layer-counts, like 'conv:8x3x3,pool:2,dense:100'. Options: 'conv:FILTERSxHEIGHTxWIDTH[:STRIDE[:PADDING]]', 'pool:SIZE[:STRIDE]', 'avgpool:SIZE[:STRIDE]', 'flatten', 'dense:UNITS', 'rnn:UNITS', 'lstm:UNITS', 'gru:UNITS' and 'dropout:RATE'. The dense output layer is added automatically.")
+	headsStr := flag.String("heads", "", "Comma-separated list of named output heads of multi-task networks as NAME:SIZE:ACTIVATION:LOSS[:WEIGHT], like 'class:3:softmax:categorical-crossentropy,value:1:linear:mse:0.5'. Heads replace the output activation and -loss, and must match the heads of a loaded model.")
+	headColumnsStr := flag.String("head-columns", "", "Comma-separated list of the zero-based dataset target columns of each head for the 'csv' preset as NAME=COLUMN[|COLUMN...], like 'class=4,value=5'. A single column for a head with more than one output holds a class index.")
+	task := flag.String("task", "classification", "Task 'classification', 'regression' or 'multi-label' of new networks. Regression networks have a linear output and testing reports RMSE, MAE, R² and MAPE instead of accuracy. Multi-label networks have independent sigmoid outputs with 'binary-crossentropy' loss and testing reports Hamming loss, subset accuracy and micro and macro F1. For both a single -activation value only applies to the hidden layers.")
+	scaleTargets := flag.Bool("scale-targets", true, "Standardize the targets of new regression networks by the mean and standard deviation of the training dataset, transforming predictions back to the target scale.")
+	targetColumnsStr := flag.String("target-columns", "", "Comma-separated list of the zero-based dataset target columns for the 'csv' preset without -heads. (default last column)")
+	thresholdsStr := flag.String("thresholds", "", "Comma-separated list of the decision threshold of each output of multi-label networks, replacing the thresholds of a loaded model. A single value applies to all outputs. (default 0.5)")
+	labelsStr := flag.String("labels", "", "Comma-separated list of the label names of each output of multi-label networks for the 'csv' preset, when the target column holds a set of label names or zero-based label indices.")
+	labelDelimiter := flag.String("label-delimiter", "|", "Delimiter between the labels of a label set for the 'csv' preset with -labels.")
+	inputShapeStr := flag.String("input-shape", "", "Image shape of the inputs for convolution and pooling layers as CHANNELSxHEIGHTxWIDTH, like '1x28x28'. (default preset image shape)")
 	flag.Parse()
+	if *schema != "" && *dataset == "" {
+		return runConfig{}, fmt.Errorf("-schema needs -dataset")
+	}
 	if *dataset == "" {
 		switch *action {
 		case "train":
@@ -52,15 +129,93 @@ func parseCmdFlags() (runConfig, error) {
 			return runConfig{}, fmt.Errorf("unknown action '%s'", *action)
 		}
 	}
-	var activation network.ActivationType
-	switch *activationVal {
-	case "sigmoid":
-		activation = network.ActivationTypeSigmoid
-	case "tanh":
-		activation = network.ActivationTypeTanh
+	activationStrs := strings.Split(*activationsStr, ",")
+	activations := make([]network.ActivationType, 0, len(activationStrs))
+	for _, s := range activationStrs {
+		activation, err := parseActivation(strings.TrimSpace(s))
+		if err != nil {
+			flag.PrintDefaults()
+			return runConfig{}, err
+		}
+		activations = append(activations, activation)
+	}
+	loss, err := parseLoss(*lossVal)
+	if err != nil {
+		flag.PrintDefaults()
+		return runConfig{}, err
+	}
+	optimizer := network.OptimizerConfig{
+		Momentum: *momentum,
+		Decay:    *decay,
+		Beta1:    *beta1,
+		Beta2:    *beta2,
+		Epsilon:  *epsilon,
+	}
+	switch *optimizerVal {
+	case "sgd":
+		optimizer.Type = network.OptimizerTypeSGD
+	case "momentum":
+		optimizer.Type = network.OptimizerTypeMomentum
+	case "nesterov":
+		optimizer.Type = network.OptimizerTypeMomentum
+		optimizer.Nesterov = true
+	case "rmsprop":
+		optimizer.Type = network.OptimizerTypeRMSProp
+	case "adagrad":
+		optimizer.Type = network.OptimizerTypeAdagrad
+	case "adam":
+		optimizer.Type = network.OptimizerTypeAdam
 	default:
 		flag.PrintDefaults()
-		return runConfig{}, fmt.Errorf("unknown activation '%s'", *activationVal)
+		return runConfig{}, fmt.Errorf("unknown optimizer '%s'", *optimizerVal)
+	}
+	schedule := network.ScheduleConfig{
+		Type:        network.ScheduleType(*scheduleVal),
+		StepSize:    *scheduleStepSize,
+		Gamma:       *scheduleGamma,
+		MinRate:     *scheduleMinRate,
+		CycleMult:   *scheduleCycleMult,
+		Patience:    *schedulePatience,
+		Threshold:   *scheduleThreshold,
+		WarmupSteps: *warmupSteps,
+	}
+	if _, err := network.NewSchedule(schedule); err != nil {
+		flag.PrintDefaults()
+		return runConfig{}, err
+	}
+	initType := network.InitType(*initVal)
+	if !validInitType(initType) {
+		flag.PrintDefaults()
+		return runConfig{}, fmt.Errorf("unknown weight initialization '%s'", *initVal)
+	}
+	if *shuffle && !*cache {
+		return runConfig{}, fmt.Errorf("-shuffle needs -cache")
+	}
+	if *validationSplit != 0 {
+		if *validationFile != "" {
+			return runConfig{}, fmt.Errorf("-validation-split and -validation-file cannot both be set")
+		}
+		if *validationSplit < 0 || *validationSplit >= 1 {
+			return runConfig{}, fmt.Errorf("validation split must be between 0 and 1, got %v", *validationSplit)
+		}
+		if !*cache {
+			return runConfig{}, fmt.Errorf("-validation-split needs -cache")
+		}
+	}
+	if *patience < 0 {
+		return runConfig{}, fmt.Errorf("early stopping patience cannot be negative, got %d", *patience)
+	}
+	if *patience > 0 && *validationSplit == 0 && *validationFile == "" {
+		return runConfig{}, fmt.Errorf("-early-stopping-patience needs -validation-split or -validation-file")
+	}
+	if *monitor != monitorValidationLoss && *monitor != monitorValidationAccuracy {
+		return runConfig{}, fmt.Errorf("unknown early stopping monitor '%s'", *monitor)
+	}
+	if *minDelta < 0 {
+		return runConfig{}, fmt.Errorf("early stopping min delta cannot be negative, got %v", *minDelta)
+	}
+	if *batchSize < 1 {
+		return runConfig{}, fmt.Errorf("batch size must be at least 1, got %d", *batchSize)
 	}
 	countStrs := strings.Split(*hiddenLayerCountsStr, ",")
 	hiddenLayerCounts := make([]int, 0, len(countStrs))
@@ -75,16 +230,143 @@ func parseCmdFlags() (runConfig, error) {
 		}
 		hiddenLayerCounts = append(hiddenLayerCounts, int(v))
 	}
+	dropoutStrs := strings.Split(*dropoutStr, ",")
+	dropout := make([]float64, 0, len(dropoutStrs))
+	for _, s := range dropoutStrs {
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return runConfig{}, fmt.Errorf("invalid dropout rate '%s'", trimmed)
+		}
+		dropout = append(dropout, v)
+	}
+	var normalization []network.NormType
+	for _, s := range strings.Split(*normalizationStr, ",") {
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			continue
+		}
+		normType, err := parseNormType(trimmed)
+		if err != nil {
+			flag.PrintDefaults()
+			return runConfig{}, err
+		}
+		if normType == network.NormTypeBatch && *batchSize < 2 {
+			return runConfig{}, fmt.Errorf("batch normalization needs a batch size of at least 2, got %d", *batchSize)
+		}
+		normalization = append(normalization, normType)
+	}
+	var inputShape network.ImageShape
+	if *inputShapeStr != "" {
+		var err error
+		if inputShape, err = parseImageShape(*inputShapeStr); err != nil {
+			return runConfig{}, err
+		}
+	}
+	heads, err := parseHeads(*headsStr)
+	if err != nil {
+		return runConfig{}, err
+	}
+	headColumns, err := parseHeadColumns(*headColumnsStr, heads)
+	if err != nil {
+		return runConfig{}, err
+	}
+	var targetColumns []int
+	for _, c := range strings.Split(*targetColumnsStr, ",") {
+		trimmed := strings.TrimSpace(c)
+		if trimmed == "" {
+			continue
+		}
+		col, err := strconv.Atoi(trimmed)
+		if err != nil || col < 0 {
+			return runConfig{}, fmt.Errorf("invalid target column '%s'", trimmed)
+		}
+		targetColumns = append(targetColumns, col)
+	}
+	var taskType network.TaskType
+	switch *task {
+	case "classification":
+		taskType = network.TaskTypeClassification
+	case "regression":
+		taskType = network.TaskTypeRegression
+	case "multi-label":
+		taskType = network.TaskTypeMultiLabel
+		lossSet := false
+		flag.Visit(func(f *flag.Flag) { lossSet = lossSet || f.Name == "loss" })
+		if !lossSet {
+			loss = network.LossTypeBinaryCrossEntropy
+		}
+	default:
+		flag.PrintDefaults()
+		return runConfig{}, fmt.Errorf("unknown task '%s'", *task)
+	}
+	var thresholds []float64
+	for _, t := range strings.Split(*thresholdsStr, ",") {
+		trimmed := strings.TrimSpace(t)
+		if trimmed == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return runConfig{}, fmt.Errorf("invalid threshold '%s'", trimmed)
+		}
+		thresholds = append(thresholds, v)
+	}
+	if len(thresholds) > 0 && taskType != network.TaskTypeMultiLabel {
+		return runConfig{}, fmt.Errorf("-thresholds needs -task=multi-label")
+	}
+	var labels []string
+	for _, l := range strings.Split(*labelsStr, ",") {
+		if trimmed := strings.TrimSpace(l); trimmed != "" {
+			labels = append(labels, trimmed)
+		}
+	}
+	if *layerSpec != "" && len(hiddenLayerCounts) > 0 {
+		return runConfig{}, fmt.Errorf("-layers and -hidden-layer-counts cannot both be set")
+	}
 	cfg := runConfig{
-		Action:      *action,
-		ModelFile:   *model,
-		DataSetFile: *dataset,
-		Epochs:      *epochs,
+		Action:          *action,
+		ModelFile:       *model,
+		DataSetFile:     *dataset,
+		SchemaFile:      *schema,
+		Epochs:          *epochs,
+		BatchSize:       *batchSize,
+		HeadColumns:     headColumns,
+		TargetColumns:   targetColumns,
+		ScaleTargets:    *scaleTargets,
+		Cache:           *cache,
+		ValidationFile:  *validationFile,
+		ValidationSplit: *validationSplit,
+		Monitor:         *monitor,
+		Patience:        *patience,
+		MinDelta:        *minDelta,
+		Shuffle:         *shuffle,
+		Labels:          labels,
+		LabelDelimiter:  *labelDelimiter,
 		networkConfig: networkConfig{
-			Activation:        activation,
+			Activations:       activations,
+			Loss:              loss,
 			LearningRate:      *learningRate,
+			Schedule:          schedule,
+			Optimizer:         optimizer,
+			L1:                *l1,
+			L2:                *l2,
+			WeightDecay:       *weightDecay,
+			Dropout:           dropout,
+			Normalization:     normalization,
+			NormMomentum:      *normMomentum,
+			Init:              initType,
+			InitValue:         *initValue,
 			RandomSeed:        *randomSeed,
 			HiddenLayerCounts: hiddenLayerCounts,
+			LayerSpec:         *layerSpec,
+			InputShape:        inputShape,
+			Heads:             heads,
+			Task:              taskType,
+			Thresholds:        thresholds,
 		},
 	}
 
@@ -96,19 +378,278 @@ func parseCmdFlags() (runConfig, error) {
 		cfgPreset = mnistPreset
 	case "iris":
 		cfgPreset = irisPreset
+	case "csv":
+		cfgPreset = csvPreset
 	default:
 		cfgPreset = func(*runConfig) error { return fmt.Errorf("unknown preset") }
 	}
+	if *schema != "" {
+		cfgPreset = schemaPreset
+	}
 	if err := cfgPreset(&cfg); err != nil {
 		return cfg, err
 	}
+	if len(cfg.Heads) > 0 && (*preset != "csv" || *schema != "") {
+		return cfg, fmt.Errorf("-heads needs the 'csv' preset to assign target columns to each head")
+	}
+	if cfg.Task != network.TaskTypeClassification && *preset != "csv" && *schema == "" {
+		return cfg, fmt.Errorf("-task=%s needs -schema or the 'csv' preset to read its targets", cfg.Task)
+	}
+	if len(cfg.Thresholds) == 1 {
+		for len(cfg.Thresholds) < cfg.OutputCount {
+			cfg.Thresholds = append(cfg.Thresholds, cfg.Thresholds[0])
+		}
+	}
 
 	if cfg.Epochs == 0 {
 		cfg.Epochs = 1
 	}
+	if cfg.LayerSpec != "" {
+		return cfg, layerSpecDefaults(&cfg)
+	}
 	if len(cfg.HiddenLayerCounts) == 0 {
 		cfg.HiddenLayerCounts = []int{cfg.InputCount}
 	}
+	layerCount := len(cfg.HiddenLayerCounts) + 1
+	expandActivations(&cfg.networkConfig, layerCount)
+	if len(cfg.Activations) != layerCount {
+		return cfg, fmt.Errorf("activation count %d must be 1 or equal the hidden layer count plus the output layer %d", len(cfg.Activations), layerCount)
+	}
+
+	if len(cfg.Dropout) == 1 {
+		for len(cfg.Dropout) < len(cfg.HiddenLayerCounts) {
+			cfg.Dropout = append(cfg.Dropout, cfg.Dropout[0])
+		}
+	}
+	if len(cfg.Dropout) > 0 && len(cfg.Dropout) != len(cfg.HiddenLayerCounts) {
+		return cfg, fmt.Errorf("dropout rate count %d must be 1 or equal the hidden layer count %d", len(cfg.Dropout), len(cfg.HiddenLayerCounts))
+	}
+	if len(cfg.Normalization) == 1 {
+		for len(cfg.Normalization) < len(cfg.HiddenLayerCounts) {
+			cfg.Normalization = append(cfg.Normalization, cfg.Normalization[0])
+		}
+	}
+	if len(cfg.Normalization) > 0 && len(cfg.Normalization) != len(cfg.HiddenLayerCounts) {
+		return cfg, fmt.Errorf("normalization count %d must be 1 or equal the hidden layer count %d", len(cfg.Normalization), len(cfg.HiddenLayerCounts))
+	}
 
 	return cfg, nil
 }
+
+// layerSpecDefaults expands the activations of a layer spec config, one for each dense and convolution layer and the output layer.
+func layerSpecDefaults(cfg *runConfig) error {
+	specs, err := network.ParseLayerSpec(cfg.LayerSpec)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Dropout) > 0 || len(cfg.Normalization) > 0 {
+		return fmt.Errorf("-dropout and -normalization cannot be used with -layers, add 'dropout:RATE' layers to the spec instead")
+	}
+	layerCount := 1
+	for _, s := range specs {
+		if s.Type == network.LayerTypeDense || s.Type == network.LayerTypeConv2D {
+			layerCount++
+		}
+	}
+	expandActivations(&cfg.networkConfig, layerCount)
+	if len(cfg.Activations) != layerCount {
+		return fmt.Errorf("activation count %d must be 1 or equal the dense and convolution layer count plus the output layer %d", len(cfg.Activations), layerCount)
+	}
+	return nil
+}
+
+// expandActivations applies a single activation to every layer, or to the hidden layers of a regression network
+// with a linear output and a multi-label network with a sigmoid output.
+func expandActivations(cfg *networkConfig, layerCount int) {
+	if len(cfg.Activations) != 1 {
+		return
+	}
+	output := map[network.TaskType]network.ActivationType{
+		network.TaskTypeRegression: network.ActivationTypeLinear,
+		network.TaskTypeMultiLabel: network.ActivationTypeSigmoid,
+	}[cfg.Task]
+	count := layerCount
+	if output != network.ActivationTypeNone {
+		count--
+	}
+	for len(cfg.Activations) < count {
+		cfg.Activations = append(cfg.Activations, cfg.Activations[0])
+	}
+	if output != network.ActivationTypeNone {
+		cfg.Activations = append(cfg.Activations[:count], output)
+	}
+}
+
+func parseImageShape(s string) (network.ImageShape, error) {
+	dims := strings.Split(s, "x")
+	if len(dims) != 3 {
+		return network.ImageShape{}, fmt.Errorf("input shape '%s' must be CHANNELSxHEIGHTxWIDTH", s)
+	}
+	values := make([]int, 0, len(dims))
+	for _, d := range dims {
+		v, err := strconv.Atoi(strings.TrimSpace(d))
+		if err != nil || v < 1 {
+			return network.ImageShape{}, fmt.Errorf("invalid input shape dimension '%s'", d)
+		}
+		values = append(values, v)
+	}
+	return network.ImageShape{Channels: values[0], Height: values[1], Width: values[2]}, nil
+}
+
+func parseLoss(name string) (network.LossType, error) {
+	switch name {
+	case "mse":
+		return network.LossTypeMSE, nil
+	case "binary-crossentropy":
+		return network.LossTypeBinaryCrossEntropy, nil
+	case "categorical-crossentropy":
+		return network.LossTypeCategoricalCrossEntropy, nil
+	case "mae":
+		return network.LossTypeMAE, nil
+	case "huber":
+		return network.LossTypeHuber, nil
+	default:
+		return 0, fmt.Errorf("unknown loss '%s'", name)
+	}
+}
+
+// parseHeads parses a comma-separated list of NAME:SIZE:ACTIVATION:LOSS[:WEIGHT] heads,
+// where the activation may have its own colon-separated parameters.
+func parseHeads(s string) ([]network.HeadConfig, error) {
+	var heads []network.HeadConfig
+	for _, headStr := range strings.Split(s, ",") {
+		trimmed := strings.TrimSpace(headStr)
+		if trimmed == "" {
+			continue
+		}
+		fields := strings.Split(trimmed, ":")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("head '%s' must be NAME:SIZE:ACTIVATION:LOSS[:WEIGHT]", trimmed)
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid head '%s' size '%s'", fields[0], fields[1])
+		}
+		h := network.HeadConfig{Name: fields[0], Size: size}
+		last := len(fields) - 1
+		if weight, err := strconv.ParseFloat(fields[last], 64); err == nil {
+			if last < 4 {
+				return nil, fmt.Errorf("head '%s' must be NAME:SIZE:ACTIVATION:LOSS[:WEIGHT]", trimmed)
+			}
+			h.Weight = weight
+			last--
+		}
+		if h.Loss, err = parseLoss(fields[last]); err != nil {
+			return nil, fmt.Errorf("head '%s': %v", h.Name, err)
+		}
+		if h.Activation, err = parseActivation(strings.Join(fields[2:last], ":")); err != nil {
+			return nil, fmt.Errorf("head '%s': %v", h.Name, err)
+		}
+		heads = append(heads, h)
+	}
+	if _, err := network.NewHeadsLayer(heads); len(heads) > 0 && err != nil {
+		return nil, err
+	}
+	return heads, nil
+}
+
+// parseHeadColumns parses a comma-separated list of NAME=COLUMN[|COLUMN...] dataset target columns for each head.
+func parseHeadColumns(s string, heads []network.HeadConfig) (map[string][]int, error) {
+	columns := map[string][]int{}
+	used := map[int]string{}
+	for _, headStr := range strings.Split(s, ",") {
+		trimmed := strings.TrimSpace(headStr)
+		if trimmed == "" {
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("head columns '%s' must be NAME=COLUMN[|COLUMN...]", trimmed)
+		}
+		name := parts[0]
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("head '%s' columns are set more than once", name)
+		}
+		for _, c := range strings.Split(parts[1], "|") {
+			col, err := strconv.Atoi(strings.TrimSpace(c))
+			if err != nil || col < 0 {
+				return nil, fmt.Errorf("invalid head '%s' column '%s'", name, c)
+			}
+			if other, ok := used[col]; ok {
+				return nil, fmt.Errorf("column %d is assigned to both heads '%s' and '%s'", col, other, name)
+			}
+			used[col] = name
+			columns[name] = append(columns[name], col)
+		}
+	}
+	known := make(map[string]bool, len(heads))
+	for _, h := range heads {
+		known[h.Name] = true
+		cols, ok := columns[h.Name]
+		if !ok {
+			return nil, fmt.Errorf("head '%s' has no -head-columns", h.Name)
+		}
+		if len(cols) != 1 && len(cols) != h.Size {
+			return nil, fmt.Errorf("head '%s' column count %d must be 1 for a class index or equal its size %d", h.Name, len(cols), h.Size)
+		}
+	}
+	for name := range columns {
+		if !known[name] {
+			return nil, fmt.Errorf("head columns set for unknown head '%s'", name)
+		}
+	}
+	return columns, nil
+}
+
+func parseActivation(name string) (network.ActivationType, error) {
+	t := network.ActivationType(name)
+	if _, err := network.NewActivation(t); err != nil {
+		return network.ActivationTypeNone, err
+	}
+	return t, nil
+}
+
+func quotedInitTypes() string {
+	names := make([]string, 0, len(network.InitTypes))
+	for _, t := range network.InitTypes {
+		names = append(names, fmt.Sprintf("'%s'", t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func validInitType(t network.InitType) bool {
+	for _, valid := range network.InitTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func quotedScheduleTypes() string {
+	names := make([]string, 0, len(network.ScheduleTypes))
+	for _, t := range network.ScheduleTypes {
+		names = append(names, fmt.Sprintf("'%s'", t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func quotedNormTypes() string {
+	names := make([]string, 0, len(network.NormTypes))
+	for _, t := range network.NormTypes {
+		names = append(names, fmt.Sprintf("'%s'", t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func parseNormType(name string) (network.NormType, error) {
+	if name == "none" {
+		return network.NormTypeNone, nil
+	}
+	for _, t := range network.NormTypes {
+		if network.NormType(name) == t {
+			return t, nil
+		}
+	}
+	return network.NormTypeNone, fmt.Errorf("unknown normalization '%s'", name)
+}
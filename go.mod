@@ -5,4 +5,5 @@ go 1.17
 require (
 	golang.org/x/exp v0.0.0-20210729172720-737cce5152fc
 	gonum.org/v1/gonum v0.9.3
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"time"
 
+	"github.com/benjohns1/neural-net-go/dataset"
 	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
 	"github.com/benjohns1/neural-net-go/storage"
 
 	"gonum.org/v1/gonum/mat"
@@ -38,15 +41,64 @@ func csvRun(cfg runConfig) error {
 		if err != nil {
 			return err
 		}
-		log.Printf("Current network trained on %d records", n.Config().Trained)
+		log.Printf("Current network trained on %d records over %d epochs", n.Config().Trained, n.Epochs())
+		if err := checkHeads(cfg.Heads, n.Config().Heads); err != nil {
+			return fmt.Errorf("loaded model: %v", err)
+		}
+		if len(cfg.Thresholds) > 0 {
+			if err := n.SetThresholds(cfg.Thresholds); err != nil {
+				return fmt.Errorf("loaded model: %v", err)
+			}
+		}
+		if n.Config().Preprocessing == nil && cfg.Pipeline != nil && !cfg.Pipeline.Fitted() {
+			return fmt.Errorf("loaded model has no preprocessing pipeline, and the declared pipeline has steps to fit to a training dataset")
+		}
 	} else if os.IsNotExist(err) {
 		log.Printf("No existing model file found at %s, creating new network with random weights seeded with %d...", cfg.ModelFile, cfg.RandomSeed)
+		layerCounts, layerSpec := append(cfg.HiddenLayerCounts, cfg.OutputCount), ""
+		if cfg.LayerSpec != "" {
+			layerCounts, layerSpec = nil, fmt.Sprintf("%s,dense:%d", cfg.LayerSpec, cfg.OutputCount)
+		}
+		if cfg.Pipeline != nil {
+			if cfg.Action != "train" && !cfg.Pipeline.Fitted() {
+				return fmt.Errorf("the preprocessing pipeline has steps to fit, which are only fitted to a training dataset")
+			}
+			if cfg.Pipeline, err = fitPipeline(*cfg.Pipeline, cfg.DataSetFile); err != nil {
+				return fmt.Errorf("fitting preprocessing pipeline: %v", err)
+			}
+			cfg.TrainParseRecord = cfg.Pipeline.Transform
+		}
+		var scaling network.TargetScaling
+		if cfg.Task == network.TaskTypeRegression && cfg.ScaleTargets {
+			if scaling, err = fitTargetScaling(cfg.DataSetFile, cfg.TrainParseRecord); err != nil {
+				return fmt.Errorf("fitting target scaling: %v", err)
+			}
+			log.Printf("Scaling targets with means %v and standard deviations %v", scaling.Mean, scaling.Std)
+		}
 		n, err = network.NewRandom(network.Config{
-			InputCount:  cfg.InputCount,
-			LayerCounts: append(cfg.HiddenLayerCounts, cfg.OutputCount),
-			Rate:        cfg.LearningRate,
-			RandSeed:    cfg.RandomSeed,
-			Activation:  cfg.Activation,
+			InputCount:    cfg.InputCount,
+			InputShape:    cfg.InputShape,
+			LayerCounts:   layerCounts,
+			LayerSpec:     layerSpec,
+			Rate:          cfg.LearningRate,
+			Schedule:      cfg.Schedule,
+			Optimizer:     cfg.Optimizer,
+			L1:            cfg.L1,
+			L2:            cfg.L2,
+			WeightDecay:   cfg.WeightDecay,
+			Dropout:       cfg.Dropout,
+			Normalization: cfg.Normalization,
+			NormMomentum:  cfg.NormMomentum,
+			Init:          cfg.Init,
+			InitValue:     cfg.InitValue,
+			RandSeed:      cfg.RandomSeed,
+			Activations:   cfg.Activations,
+			Loss:          cfg.Loss,
+			Heads:         cfg.Heads,
+			Task:          cfg.Task,
+			TargetScaling: scaling,
+			Thresholds:    cfg.Thresholds,
+			Preprocessing: cfg.Pipeline,
 		})
 		if err != nil {
 			return fmt.Errorf("creating new random network: %v", err)
@@ -54,17 +106,45 @@ func csvRun(cfg runConfig) error {
 	} else {
 		return fmt.Errorf("checking model file: %v", err)
 	}
+	if p := n.Config().Preprocessing; p != nil {
+		cfg.Pipeline = p
+	}
+	if cfg.Pipeline != nil {
+		cfg.TrainParseRecord, cfg.TestParseRecord = cfg.Pipeline.Transform, cfg.Pipeline.Transform
+	}
 
 	switch cfg.Action {
 	case "train":
-		if err := train(n, cfg.Epochs, cfg.DataSetFile, cfg.TrainLogBatch, cfg.TrainParseRecord); err != nil {
+		data, err := dataset.NewCSV(cfg.DataSetFile, dataset.ParseFunc(cfg.TrainParseRecord), cfg.Cache)
+		if err != nil {
+			return fmt.Errorf("reading training dataset: %v", err)
+		}
+		trainData, validation, err := validationData(cfg, n.Config(), data)
+		if err != nil {
+			return err
+		}
+		var shuffler *dataset.Shuffler
+		if cfg.Shuffle {
+			r := network.Rand{Seed: n.Config().RandSeed}.Stream(network.StreamShuffle)
+			r.State = n.Config().ShuffleState
+			shuffler = dataset.NewShuffler(r)
+		}
+		var stopper *earlyStopping
+		if cfg.Patience > 0 {
+			stopper = &earlyStopping{monitor: cfg.Monitor, patience: cfg.Patience, minDelta: cfg.MinDelta}
+		}
+		if err := train(n, cfg.Epochs, trainData, validation, shuffler, stopper, cfg.BatchSize, cfg.TrainLogBatch); err != nil {
 			return err
 		}
 		if err := file.Save(n, cfg.ModelFile); err != nil {
 			return err
 		}
 	case "test":
-		if err := test(n, cfg.DataSetFile, cfg.TestLogBatch, cfg.TestParseRecord); err != nil {
+		data, err := dataset.NewCSV(cfg.DataSetFile, dataset.ParseFunc(cfg.TestParseRecord), false)
+		if err != nil {
+			return fmt.Errorf("reading test dataset: %v", err)
+		}
+		if err := test(n, data, cfg.TestLogBatch); err != nil {
 			return err
 		}
 	default:
@@ -74,70 +154,252 @@ func csvRun(cfg runConfig) error {
 	return nil
 }
 
-func train(net *network.Network, epochs int, filename string, logBatch int, parseRecord parseRecordFunc) error {
-	start := time.Now()
-	cfg := net.Config()
-	l := len(cfg.LayerCounts)
-	if l == 0 {
-		return fmt.Errorf("layer counts cannot be zero")
+// checkHeads returns an error if the heads used to parse the dataset don't have the names and sizes of the model heads.
+func checkHeads(heads, modelHeads []network.HeadConfig) error {
+	if len(heads) != len(modelHeads) {
+		return fmt.Errorf("model has %d heads, -heads has %d", len(modelHeads), len(heads))
+	}
+	for i, h := range heads {
+		if h.Name != modelHeads[i].Name || h.Size != modelHeads[i].Size {
+			return fmt.Errorf("model head %d is '%s' with size %d, -heads has '%s' with size %d", i, modelHeads[i].Name, modelHeads[i].Size, h.Name, h.Size)
+		}
+	}
+	return nil
+}
+
+// fitTargetScaling reads every target of a dataset to fit their scaling.
+func fitTargetScaling(filename string, parseRecord parseRecordFunc) (network.TargetScaling, error) {
+	records, err := readRecords(filename)
+	if err != nil {
+		return network.TargetScaling{}, err
+	}
+	targets := make([][]float64, len(records))
+	for i, record := range records {
+		if _, targets[i], err = parseRecord(record); err != nil {
+			return network.TargetScaling{}, fmt.Errorf("parsing line %d: %v", i+1, err)
+		}
+	}
+	return network.FitTargetScaling(targets)
+}
+
+// fitPipeline fits a preprocessing pipeline to the records of a dataset, unless every step already has fixed parameters.
+func fitPipeline(p preprocess.Pipeline, filename string) (*preprocess.Pipeline, error) {
+	if p.Fitted() {
+		return &p, p.Validate()
 	}
+	records, err := readRecords(filename)
+	if err != nil {
+		return nil, err
+	}
+	fitted, err := p.Fit(records)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Fitted preprocessing pipeline to %d records", len(records))
+	return &fitted, nil
+}
+
+// readRecords reads every record of a CSV file.
+func readRecords(filename string) ([][]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var records [][]string
+	r := csv.NewReader(bufio.NewReader(f))
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading line %d: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// validationData returns the training and validation datasets, reading the validation dataset from -validation-file
+// or holding out -validation-split of the training dataset, stratified by class for classification networks.
+// Without either the validation dataset is nil.
+func validationData(cfg runConfig, netCfg network.Config, data dataset.Dataset) (dataset.Dataset, dataset.Dataset, error) {
+	switch {
+	case cfg.ValidationFile != "":
+		validation, err := dataset.NewCSV(cfg.ValidationFile, dataset.ParseFunc(cfg.TrainParseRecord), cfg.Cache)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading validation dataset: %v", err)
+		}
+		return data, validation, nil
+	case cfg.ValidationSplit > 0:
+		var class func(dataset.Record) int
+		if netCfg.Task == network.TaskTypeClassification && len(netCfg.Heads) == 0 {
+			class = func(r dataset.Record) int { return getTarget(r.Targets) }
+		}
+		train, validation, err := dataset.Split(data, cfg.ValidationSplit, network.Rand{Seed: netCfg.RandSeed}.Stream(network.StreamSplit), class)
+		if err != nil {
+			return nil, nil, fmt.Errorf("splitting validation dataset: %v", err)
+		}
+		log.Printf("Holding out %d of %d records for validation", validation.Len(), data.Len())
+		return train, validation, nil
+	}
+	return data, nil, nil
+}
+
+// train trains the network for a number of epochs over a dataset, in a new order drawn by the shuffler each epoch if set,
+// logging the training loss with the validation loss and accuracy after each epoch when there is a validation dataset.
+// With early stopping, training stops once the monitored validation metric stops improving,
+// and the network layers are restored to the weights of the epoch with the best value, keeping the count of records
+// and epochs trained and the optimizer state of the last epoch.
+func train(net *network.Network, epochs int, data, validation dataset.Dataset, shuffler *dataset.Shuffler, stopper *earlyStopping, batchSize int, logBatch int) error {
+	start := time.Now()
 	log.Printf("Training %d epochs", epochs)
-	for e := 1; e <= epochs; e++ {
-		if err := trainEpoch(net, e, filename, cfg, logBatch, parseRecord); err != nil {
+	var best []network.Layer
+	last, stopped := 0, false
+	for e := 1; e <= epochs && !stopped; e++ {
+		last = e
+		var order []int
+		if shuffler != nil {
+			order = shuffler.Order(data)
+			net.SetShuffleState(shuffler.State())
+		}
+		loss, err := trainEpoch(net, e, data.Iterate(order), batchSize, logBatch)
+		if err != nil {
+			return err
+		}
+		net.EndEpoch(loss)
+		if validation == nil {
+			continue
+		}
+		s, validationLoss, err := validate(net, validation, batchSize)
+		if err != nil {
 			return err
 		}
+		accuracy := s.accuracy()
+		if !math.IsNaN(accuracy) {
+			log.Printf("Epoch %d: training loss %f, validation loss %f, validation accuracy %0.2f%%", e, loss, validationLoss, accuracy*100)
+		} else {
+			log.Printf("Epoch %d: training loss %f, validation loss %f", e, loss, validationLoss)
+			s.report(fmt.Sprintf("Epoch %d: validation ", e))
+		}
+		if stopper == nil {
+			continue
+		}
+		improved, err := stopper.observe(e, validationLoss, accuracy)
+		if err != nil {
+			return err
+		}
+		if improved {
+			best = net.Layers()
+			continue
+		}
+		if stopped = stopper.stop(); stopped {
+			log.Printf("Stopping early after epoch %d: %s has not improved by more than %g for %d epochs", e, stopper.monitor, stopper.minDelta, stopper.patience)
+		}
+	}
+	log.Printf("Time to train %d epochs: %v", last, time.Since(start))
+	if stopper == nil {
+		return nil
+	}
+	if !stopped {
+		log.Printf("Stopping after the last of %d epochs: %s last improved at epoch %d", epochs, stopper.monitor, stopper.bestEpoch)
+	}
+	if stopper.bestEpoch != last {
+		if err := net.SetLayers(best); err != nil {
+			return fmt.Errorf("restoring best weights: %v", err)
+		}
+		log.Printf("Restored the weights of epoch %d with the best %s %f", stopper.bestEpoch, stopper.monitor, stopper.bestValue())
 	}
-	log.Printf("Time to train %d epochs: %v", epochs, time.Since(start))
 	return nil
 }
 
-func test(net *network.Network, filename string, logBatch int, parseRecord parseRecordFunc) error {
-	start := time.Now()
-	checkFile, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("opening file: %v", err)
+// validate scores the predictions of the network over a validation dataset, evaluated in batches,
+// returning the scorer and the mean validation loss.
+func validate(net *network.Network, data dataset.Dataset, batchSize int) (scorer, float64, error) {
+	it := data.Iterate(nil)
+	defer func() {
+		_ = it.Close()
+	}()
+	s := newScorer(net.Config())
+	inputs := make([][]float64, 0, batchSize)
+	targets := make([][]float64, 0, batchSize)
+	lossSum := 0.0
+	evaluate := func() error {
+		if len(inputs) == 0 {
+			return nil
+		}
+		loss, outputs, err := net.Evaluate(inputs, targets)
+		if err != nil {
+			return fmt.Errorf("evaluating validation records: %v", err)
+		}
+		lossSum += loss * float64(len(inputs))
+		rows, _ := outputs.Dims()
+		for j, t := range targets {
+			if err := s.add(mat.DenseCopyOf(outputs.Slice(0, rows, j, j+1)), t); err != nil {
+				return fmt.Errorf("scoring validation records: %v", err)
+			}
+		}
+		inputs, targets = inputs[:0], targets[:0]
+		return nil
+	}
+	for it.Next() {
+		r := it.Record()
+		inputs = append(inputs, r.Inputs)
+		targets = append(targets, r.Targets)
+		if len(inputs) >= batchSize {
+			if err := evaluate(); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, 0, fmt.Errorf("reading validation records: %v", err)
 	}
+	if err := evaluate(); err != nil {
+		return nil, 0, err
+	}
+	return s, lossSum / float64(data.Len()), nil
+}
+
+func test(net *network.Network, data dataset.Dataset, logBatch int) error {
+	start := time.Now()
+	it := data.Iterate(nil)
 	defer func() {
-		_ = checkFile.Close()
+		_ = it.Close()
 	}()
 
-	score := 0
-	total := 0
-	r := csv.NewReader(bufio.NewReader(checkFile))
+	s := newScorer(net.Config())
 	line := 0
 	log.Printf("Starting prediction test...")
-	for {
+	for it.Next() {
 		line++
 		if line%logBatch == 0 {
 			log.Printf("Prediction test line %d...", line)
 		}
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		inputs, targets, err := parseRecord(record)
-		if err != nil {
-			return err
-		}
-		outputs, err := net.Predict(inputs)
+		r := it.Record()
+		outputs, err := net.Predict(r.Inputs)
 		if err != nil {
 			return fmt.Errorf("predicting: %v", err)
 		}
-		if getPrediction(outputs) == getTarget(targets) {
-			score++
+		if err := s.add(outputs, r.Targets); err != nil {
+			return fmt.Errorf("scoring line %d: %v", line, err)
 		}
-		total++
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("reading test records: %v", err)
 	}
 
 	log.Printf("Took %v to test", time.Since(start))
-	log.Printf("Scored %d/%d correct predictions: %0.2f%%", score, total, float32(score)*100/float32(total))
+	s.report("")
 
 	return nil
 }
 
 func getTarget(targets []float64) int {
 	answer := 0
-	best := 0.0
+	best := math.Inf(-1)
 	for i, target := range targets {
 		if target > best {
 			answer = i
@@ -150,7 +412,7 @@ func getTarget(targets []float64) int {
 func getPrediction(outputs *mat.Dense) int {
 	rows, _ := outputs.Dims()
 	answer := 0
-	best := 0.0
+	best := math.Inf(-1)
 	for i := 0; i < rows; i++ {
 		val := outputs.At(i, 0)
 		if val > best {
@@ -161,43 +423,57 @@ func getPrediction(outputs *mat.Dense) int {
 	return answer
 }
 
-func trainEpoch(net *network.Network, e int, filename string, cfg network.Config, logBatch int, parseRecord parseRecordFunc) error {
-	testFile, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("opening file: %v", err)
-	}
+// trainEpoch trains all records of the iterator once, returning the average loss.
+func trainEpoch(net *network.Network, e int, it dataset.Iterator, batchSize int, logBatch int) (float64, error) {
 	defer func() {
-		_ = testFile.Close()
+		_ = it.Close()
 	}()
-	r := csv.NewReader(bufio.NewReader(testFile))
 	line := 0
 	batchStart := time.Now()
+	batchInputs := make([][]float64, 0, batchSize)
+	batchTargets := make([][]float64, 0, batchSize)
+	lossSum := 0.0
+	trained := 0
+	trainBatch := func() error {
+		if len(batchInputs) == 0 {
+			return nil
+		}
+		loss, err := net.TrainBatch(batchInputs, batchTargets)
+		if err != nil {
+			return fmt.Errorf("training: %v", err)
+		}
+		lossSum += loss * float64(len(batchInputs))
+		trained += len(batchInputs)
+		batchInputs = batchInputs[:0]
+		batchTargets = batchTargets[:0]
+		return nil
+	}
 	log.Printf("Epoch %d: training first %d records...", e, logBatch)
-	for {
+	for it.Next() {
 		line++
 		if line%logBatch == 0 {
-			log.Printf("Epoch %d: last batch took %v, training next %d records from line %d...", e, time.Since(batchStart), logBatch, line)
+			log.Printf("Epoch %d: last batch took %v, training next %d records from record %d...", e, time.Since(batchStart), logBatch, line)
 			batchStart = time.Now()
 		}
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		inputs, targets, err := trainingInputs(parseRecord, cfg.InputCount, record)
-		if err != nil {
-			return fmt.Errorf("parsing training input: %v", err)
-		}
-
-		if err := net.Train(inputs, targets); err != nil {
-			return fmt.Errorf("training: %v", err)
+		r := it.Record()
+		batchInputs = append(batchInputs, r.Inputs)
+		batchTargets = append(batchTargets, r.Targets)
+		if len(batchInputs) >= batchSize {
+			if err := trainBatch(); err != nil {
+				return 0, err
+			}
 		}
 	}
-	return nil
-}
-
-func trainingInputs(parseRecord parseRecordFunc, count int, record []string) (inputs []float64, targets []float64, err error) {
-	if len(record)-1 != count {
-		return nil, nil, fmt.Errorf("mismatched inputs: %d record input values, expecting input count %d", len(record)-1, count)
+	if err := it.Err(); err != nil {
+		return 0, fmt.Errorf("reading training records: %v", err)
+	}
+	if err := trainBatch(); err != nil {
+		return 0, err
+	}
+	if trained == 0 {
+		return 0, fmt.Errorf("no training records in file")
 	}
-	return parseRecord(record)
+	loss := lossSum / float64(trained)
+	log.Printf("Epoch %d: average loss %f over %d records, learning rate %g", e, loss, trained, net.Rate())
+	return loss, nil
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/dataset"
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// parseClassRecord reads a record of an input value and a class index of two classes.
+func parseClassRecord(record []string) ([]float64, []float64, error) {
+	x, err := strconv.ParseFloat(record[0], 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets := []float64{0, 0}
+	class, err := strconv.Atoi(record[1])
+	if err != nil || class < 0 || class > 1 {
+		return nil, nil, fmt.Errorf("invalid class '%s'", record[1])
+	}
+	targets[class] = 1
+	return []float64{x}, targets, nil
+}
+
+// classDataset writes a CSV dataset with count0 records of class 0 followed by count1 records of class 1.
+func classDataset(t *testing.T, name string, count0, count1 int) string {
+	var b strings.Builder
+	for i := 0; i < count0+count1; i++ {
+		class := 0
+		if i >= count0 {
+			class = 1
+		}
+		fmt.Fprintf(&b, "%d,%d\n", i, class)
+	}
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func classCounts(t *testing.T, d dataset.Dataset) [2]int {
+	var counts [2]int
+	for i := 0; i < d.Len(); i++ {
+		r, err := d.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[getTarget(r.Targets)]++
+	}
+	return counts
+}
+
+func TestValidationData(t *testing.T) {
+	data, err := dataset.NewCSV(classDataset(t, "train.csv", 16, 4), parseClassRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validationFile := classDataset(t, "validation.csv", 2, 3)
+	tests := []struct {
+		name                  string
+		cfg                   runConfig
+		netCfg                network.Config
+		wantTrainLen          int
+		wantValidationLen     int
+		wantValidationClasses [2]int // checked when not zero
+	}{
+		{
+			name:         "should train on every record without validation",
+			cfg:          runConfig{},
+			wantTrainLen: 20,
+		},
+		{
+			name:                  "should read the validation file",
+			cfg:                   runConfig{ValidationFile: validationFile, TrainParseRecord: parseClassRecord},
+			wantTrainLen:          20,
+			wantValidationLen:     5,
+			wantValidationClasses: [2]int{2, 3},
+		},
+		{
+			name:                  "should prefer the validation file to a split",
+			cfg:                   runConfig{ValidationFile: validationFile, ValidationSplit: 0.5, TrainParseRecord: parseClassRecord},
+			wantTrainLen:          20,
+			wantValidationLen:     5,
+			wantValidationClasses: [2]int{2, 3},
+		},
+		{
+			name:                  "should hold out a stratified split for classification",
+			cfg:                   runConfig{ValidationSplit: 0.25},
+			netCfg:                network.Config{Task: network.TaskTypeClassification},
+			wantTrainLen:          15,
+			wantValidationLen:     5,
+			wantValidationClasses: [2]int{4, 1},
+		},
+		{
+			name:              "should hold out a split of the requested size for regression",
+			cfg:               runConfig{ValidationSplit: 0.25},
+			netCfg:            network.Config{Task: network.TaskTypeRegression},
+			wantTrainLen:      15,
+			wantValidationLen: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			train, validation, err := validationData(tt.cfg, tt.netCfg, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if train.Len() != tt.wantTrainLen {
+				t.Errorf("validationData() train length = %d, want %d", train.Len(), tt.wantTrainLen)
+			}
+			if tt.wantValidationLen == 0 {
+				if validation != nil {
+					t.Errorf("validationData() validation = %v, want nil", validation)
+				}
+				return
+			}
+			if validation == nil || validation.Len() != tt.wantValidationLen {
+				t.Fatalf("validationData() validation = %v, want %d records", validation, tt.wantValidationLen)
+			}
+			if tt.wantValidationClasses == ([2]int{}) {
+				return
+			}
+			if got := classCounts(t, validation); got != tt.wantValidationClasses {
+				t.Errorf("validationData() validation classes = %v, want %v", got, tt.wantValidationClasses)
+			}
+			trainClasses := classCounts(t, train)
+			if tt.cfg.ValidationFile == "" && trainClasses != [2]int{16 - tt.wantValidationClasses[0], 4 - tt.wantValidationClasses[1]} {
+				t.Errorf("validationData() train classes = %v, want the records not held out", trainClasses)
+			}
+		})
+	}
+
+	if _, _, err := validationData(runConfig{ValidationFile: filepath.Join(t.TempDir(), "missing.csv"), TrainParseRecord: parseClassRecord}, network.Config{}, data); err == nil {
+		t.Errorf("validationData() with a missing validation file error = nil, want error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	net, err := network.NewRandom(network.Config{InputCount: 1, LayerCounts: []int{3, 2}, RandSeed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := dataset.NewCSV(classDataset(t, "validation.csv", 4, 3), parseClassRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs, targets [][]float64
+	for i := 0; i < data.Len(); i++ {
+		r, err := data.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs, targets = append(inputs, r.Inputs), append(targets, r.Targets)
+	}
+	want, _, err := net.Evaluate(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		batchSize int
+	}{
+		{name: "one batch", batchSize: 10},
+		{name: "single records", batchSize: 1},
+		{name: "batches with a smaller last batch", batchSize: 3},
+		{name: "batches with a last single record", batchSize: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, loss, err := validate(net, data, tt.batchSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(loss-want) > 1e-12 {
+				t.Errorf("validate() loss = %v, want the mean record loss %v", loss, want)
+			}
+			if accuracy := s.accuracy(); math.IsNaN(accuracy) || accuracy < 0 || accuracy > 1 {
+				t.Errorf("validate() accuracy = %v, want a fraction", accuracy)
+			}
+		})
+	}
+}
+
+func TestGetPrediction(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []float64
+		want    int
+	}{
+		{name: "should pick the largest output", outputs: []float64{0.1, 0.7, 0.2}, want: 1},
+		{name: "should pick the largest of all negative outputs", outputs: []float64{-3, -0.5, -2}, want: 1},
+		{name: "should pick the first of equal outputs", outputs: []float64{0, 0, 0}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPrediction(mat.NewDense(len(tt.outputs), 1, tt.outputs)); got != tt.want {
+				t.Errorf("getPrediction() = %d, want %d", got, tt.want)
+			}
+			if got := getTarget(tt.outputs); got != tt.want {
+				t.Errorf("getTarget() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -38,6 +38,42 @@ func RandomArray(size int, v float64, opts ...func(*RandomArrayCfg)) []float64 {
 	return data
 }
 
+// UniformArray returns a random array of size uniformly distributed between -limit and limit.
+func UniformArray(size int, limit float64, opts ...func(*RandomArrayCfg)) []float64 {
+	cfg := RandomArrayCfg{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dist := distuv.Uniform{
+		Min: -limit,
+		Max: limit,
+		Src: cfg.Src,
+	}
+	data := make([]float64, size)
+	for i := range data {
+		data[i] = dist.Rand()
+	}
+	return data
+}
+
+// NormalArray returns a random array of size normally distributed around zero with a standard deviation.
+func NormalArray(size int, stdDev float64, opts ...func(*RandomArrayCfg)) []float64 {
+	cfg := RandomArrayCfg{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dist := distuv.Normal{
+		Mu:    0,
+		Sigma: stdDev,
+		Src:   cfg.Src,
+	}
+	data := make([]float64, size)
+	for i := range data {
+		data[i] = dist.Rand()
+	}
+	return data
+}
+
 // FillArray creates an array of size with all elements equal to the fill value.
 func FillArray(size int, fill float64) []float64 {
 	o := make([]float64, size)
@@ -99,6 +99,67 @@ func FromVector(v []float64) (*mat.Dense, error) {
 	return New(l, 1, v)
 }
 
+// FromVectors creates a matrix with one column for each vector, all vectors must be the same length.
+func FromVectors(vs [][]float64) (*mat.Dense, error) {
+	c := len(vs)
+	if c == 0 {
+		return nil, fmt.Errorf("vector count is zero, cannot create matrix")
+	}
+	r := len(vs[0])
+	if r == 0 {
+		return nil, fmt.Errorf("vector length is zero, cannot create matrix")
+	}
+	data := make([]float64, r*c)
+	for j, v := range vs {
+		if len(v) != r {
+			return nil, fmt.Errorf("vector %d length %d must equal first vector length %d", j, len(v), r)
+		}
+		for i, x := range v {
+			data[i*c+j] = x
+		}
+	}
+	return New(r, c, data)
+}
+
+// AddColumn adds a single-column matrix to every column of the first matrix.
+func AddColumn(m, col mat.Matrix) (*mat.Dense, error) {
+	var o mat.Dense
+	if err := safe(func() error {
+		r, _ := m.Dims()
+		cr, cc := col.Dims()
+		if cr != r || cc != 1 {
+			return fmt.Errorf("column dimensions %dx%d must be %dx1", cr, cc, r)
+		}
+		o.Apply(func(i, _ int, v float64) float64 {
+			return v + col.At(i, 0)
+		}, m)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// SumColumns sums each row of a matrix into a single-column matrix.
+func SumColumns(m mat.Matrix) (*mat.Dense, error) {
+	var o *mat.Dense
+	if err := safe(func() error {
+		r, c := m.Dims()
+		o = mat.NewDense(r, 1, nil)
+		for i := 0; i < r; i++ {
+			sum := 0.0
+			for j := 0; j < c; j++ {
+				sum += m.At(i, j)
+			}
+			o.Set(i, 0, sum)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
 // ToVector creates a vector from a single-column matrix.
 func ToVector(m mat.Matrix) (v []float64, err error) {
 	if m == nil {
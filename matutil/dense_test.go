@@ -117,3 +117,138 @@ func BenchmarkDot(b *testing.B) {
 		})
 	}
 }
+
+func TestFromVectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		vs      [][]float64
+		want    *mat.Dense
+		wantErr bool
+	}{
+		{
+			name: "should create a single column from one vector",
+			vs:   [][]float64{{1, 2, 3}},
+			want: mat.NewDense(3, 1, []float64{1, 2, 3}),
+		},
+		{
+			name: "should create one column for each vector",
+			vs:   [][]float64{{1, 2}, {3, 4}, {5, 6}},
+			want: mat.NewDense(2, 3, []float64{1, 3, 5, 2, 4, 6}),
+		},
+		{
+			name:    "should error without vectors",
+			vs:      [][]float64{},
+			wantErr: true,
+		},
+		{
+			name:    "should error with empty vectors",
+			vs:      [][]float64{{}, {}},
+			wantErr: true,
+		},
+		{
+			name:    "should error with ragged vectors",
+			vs:      [][]float64{{1, 2}, {3}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromVectors(tt.vs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FromVectors() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want != nil && !mat.Equal(got, tt.want) {
+				t.Errorf("FromVectors() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	type args struct {
+		m   mat.Matrix
+		col mat.Matrix
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *mat.Dense
+		wantErr bool
+	}{
+		{
+			name: "should add the column to a single column",
+			args: args{
+				m:   mat.NewDense(2, 1, []float64{1, 2}),
+				col: mat.NewDense(2, 1, []float64{10, 20}),
+			},
+			want: mat.NewDense(2, 1, []float64{11, 22}),
+		},
+		{
+			name: "should add the column to every column",
+			args: args{
+				m:   mat.NewDense(2, 3, []float64{1, 2, 3, 4, 5, 6}),
+				col: mat.NewDense(2, 1, []float64{10, 20}),
+			},
+			want: mat.NewDense(2, 3, []float64{11, 12, 13, 24, 25, 26}),
+		},
+		{
+			name: "should error if the column has a different row count",
+			args: args{
+				m:   mat.NewDense(2, 2, []float64{1, 2, 3, 4}),
+				col: mat.NewDense(3, 1, []float64{1, 2, 3}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error if the column matrix has more than one column",
+			args: args{
+				m:   mat.NewDense(2, 2, []float64{1, 2, 3, 4}),
+				col: mat.NewDense(2, 2, []float64{1, 2, 3, 4}),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AddColumn(tt.args.m, tt.args.col)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AddColumn() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want != nil && !mat.Equal(got, tt.want) {
+				t.Errorf("AddColumn() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSumColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		m    mat.Matrix
+		want *mat.Dense
+	}{
+		{
+			name: "should return a single column unchanged",
+			m:    mat.NewDense(2, 1, []float64{1, 2}),
+			want: mat.NewDense(2, 1, []float64{1, 2}),
+		},
+		{
+			name: "should sum each row",
+			m:    mat.NewDense(2, 3, []float64{1, 2, 3, 4, 5, 6}),
+			want: mat.NewDense(2, 1, []float64{6, 15}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SumColumns(tt.m)
+			if err != nil {
+				t.Fatalf("SumColumns() error = %v", err)
+			}
+			if !mat.Equal(got, tt.want) {
+				t.Errorf("SumColumns() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
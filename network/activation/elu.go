@@ -0,0 +1,60 @@
+package activation
+
+import (
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ELU exponential linear unit, negative values saturate smoothly towards -Alpha.
+type ELU struct {
+	Alpha float64
+}
+
+// Value computes the exponential linear unit for activation of a single value.
+func (s ELU) Value(v float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return s.Alpha * (math.Exp(v) - 1)
+}
+
+// MatrixDerivative assumes the given output values are elu(v), then this function
+// computes eluPrime as 1 for positive outputs, otherwise elu(v) + alpha
+func (s ELU) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return v + s.Alpha
+	}, outputs)
+}
+
+const (
+	seluAlpha = 1.6732632423543772
+	seluScale = 1.0507009873554805
+)
+
+// SELU scaled exponential linear unit, with constants chosen so activations self-normalize towards zero mean and unit variance.
+type SELU struct{}
+
+// Value computes the scaled exponential linear unit for activation of a single value.
+func (s SELU) Value(v float64) float64 {
+	if v > 0 {
+		return seluScale * v
+	}
+	return seluScale * seluAlpha * (math.Exp(v) - 1)
+}
+
+// MatrixDerivative assumes the given output values are selu(v), then this function
+// computes seluPrime as scale for positive outputs, otherwise selu(v) + scale * alpha
+func (s SELU) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return seluScale
+		}
+		return v + seluScale*seluAlpha
+	}, outputs)
+}
@@ -0,0 +1,34 @@
+package activation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// GELU Gaussian error linear unit, the input scaled by the standard normal cumulative distribution.
+type GELU struct{}
+
+// Value computes v * Φ(v) for activation of a single value.
+func (s GELU) Value(v float64) float64 {
+	return v * normalCDF(v)
+}
+
+// MatrixDerivative cannot be computed from GELU outputs alone, use InputDerivative instead.
+func (s GELU) MatrixDerivative(mat.Matrix) (*mat.Dense, error) {
+	return nil, fmt.Errorf("gelu derivative requires the activation inputs")
+}
+
+// InputDerivative computes geluPrime from the activation inputs as Φ(v) + v * φ(v)
+func (s GELU) InputDerivative(inputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		return normalCDF(v) + v*math.Exp(-v*v/2)/math.Sqrt(2*math.Pi)
+	}, inputs)
+}
+
+func normalCDF(v float64) float64 {
+	return 0.5 * (1 + math.Erf(v/math.Sqrt2))
+}
@@ -0,0 +1,28 @@
+package activation
+
+import (
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// HardSigmoid piecewise linear approximation of the sigmoid that is cheaper to compute.
+type HardSigmoid struct{}
+
+// Value computes 0.2 * v + 0.5 clipped to [0, 1] for activation of a single value.
+func (s HardSigmoid) Value(v float64) float64 {
+	return math.Max(0, math.Min(1, 0.2*v+0.5))
+}
+
+// MatrixDerivative assumes the given output values are hardSigmoid(v), then this function
+// computes hardSigmoidPrime as 0.2 for outputs between 0 and 1, otherwise 0
+func (s HardSigmoid) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		if v > 0 && v < 1 {
+			return 0.2
+		}
+		return 0
+	}, outputs)
+}
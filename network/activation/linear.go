@@ -0,0 +1,21 @@
+package activation
+
+import (
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Linear identity activation, typically used for regression outputs.
+type Linear struct{}
+
+// Value returns the value unchanged.
+func (s Linear) Value(v float64) float64 {
+	return v
+}
+
+// MatrixDerivative is always 1.
+func (s Linear) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	rows, cols := outputs.Dims()
+	return matutil.New(rows, cols, matutil.FillArray(rows*cols, 1))
+}
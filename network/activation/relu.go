@@ -0,0 +1,52 @@
+package activation
+
+import (
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+type ReLU struct{}
+
+// Value computes the rectified linear unit for activation of a single value.
+func (s ReLU) Value(v float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return 0
+}
+
+// MatrixDerivative assumes the given output values are relu(v), then this function
+// computes reluPrime as 1 for positive outputs, otherwise 0
+func (s ReLU) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return 0
+	}, outputs)
+}
+
+// LeakyReLU is a rectified linear unit with a small non-negative Slope for negative values, so they still have a gradient.
+type LeakyReLU struct {
+	Slope float64
+}
+
+// Value computes the leaky rectified linear unit for activation of a single value.
+func (s LeakyReLU) Value(v float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return s.Slope * v
+}
+
+// MatrixDerivative assumes the given output values are leakyRelu(v), then this function
+// computes leakyReluPrime as 1 for positive outputs, otherwise the slope
+func (s LeakyReLU) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return s.Slope
+	}, outputs)
+}
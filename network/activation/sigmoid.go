@@ -18,8 +18,8 @@ func (s Sigmoid) Value(v float64) float64 {
 // MatrixDerivative assumes the given output values are sigmoid(v), then this function
 //  computes sigmoidPrime as sigmoid(v) * (1 - sigmoid(v))
 func (s Sigmoid) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
-	rows, _ := outputs.Dims()
-	ones := mat.NewDense(rows, 1, matutil.FillArray(rows, 1))
+	rows, cols := outputs.Dims()
+	ones := mat.NewDense(rows, cols, matutil.FillArray(rows*cols, 1))
 	sub, err := matutil.Sub(ones, outputs)
 	if err != nil {
 		return nil, err
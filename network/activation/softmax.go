@@ -0,0 +1,71 @@
+package activation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+type Softmax struct{}
+
+// Value computes the softmax of a single value in isolation, which is always 1, use MatrixValue to activate a layer.
+func (s Softmax) Value(float64) float64 {
+	return 1
+}
+
+// MatrixValue computes the softmax of each column, so each column sums to 1.
+func (s Softmax) MatrixValue(inputs mat.Matrix) (*mat.Dense, error) {
+	rows, cols := inputs.Dims()
+	if rows == 0 || cols == 0 {
+		return nil, fmt.Errorf("cannot compute softmax of an empty matrix")
+	}
+	outputs := mat.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		max := math.Inf(-1)
+		for i := 0; i < rows; i++ {
+			max = math.Max(max, inputs.At(i, j))
+		}
+		sum := 0.0
+		for i := 0; i < rows; i++ {
+			e := math.Exp(inputs.At(i, j) - max) // shifted by the max for numerical stability
+			outputs.Set(i, j, e)
+			sum += e
+		}
+		for i := 0; i < rows; i++ {
+			outputs.Set(i, j, outputs.At(i, j)/sum)
+		}
+	}
+	return outputs, nil
+}
+
+// MatrixDerivative assumes the given output values are softmax(v), then this function
+// computes the diagonal of the softmax Jacobian as softmax(v) * (1 - softmax(v)).
+func (s Softmax) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		return v * (1 - v)
+	}, outputs)
+}
+
+// MatrixGradient computes the loss gradient of the softmax inputs from its outputs and the loss gradient of its outputs,
+// using the full Jacobian: softmax(v) * (grads - sum(grads * softmax(v))) for each column.
+func (s Softmax) MatrixGradient(outputs, grads mat.Matrix) (*mat.Dense, error) {
+	rows, cols := outputs.Dims()
+	gr, gc := grads.Dims()
+	if rows != gr || cols != gc {
+		return nil, fmt.Errorf("output dimensions %dx%d must equal gradient dimensions %dx%d", rows, cols, gr, gc)
+	}
+	inputGrads := mat.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		dot := 0.0
+		for i := 0; i < rows; i++ {
+			dot += outputs.At(i, j) * grads.At(i, j)
+		}
+		for i := 0; i < rows; i++ {
+			inputGrads.Set(i, j, outputs.At(i, j)*(grads.At(i, j)-dot))
+		}
+	}
+	return inputGrads, nil
+}
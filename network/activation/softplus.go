@@ -0,0 +1,28 @@
+package activation
+
+import (
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+type Softplus struct{}
+
+// Value computes the softplus log(1 + e^v) for activation of a single value.
+func (s Softplus) Value(v float64) float64 {
+	// log1p(e^v) overflows for large v, where softplus(v) converges to v
+	if v > 30 {
+		return v
+	}
+	return math.Log1p(math.Exp(v))
+}
+
+// MatrixDerivative assumes the given output values are softplus(v), then this function
+// computes softplusPrime as sigmoid(v) = 1 - e^-softplus(v)
+func (s Softplus) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		return -math.Expm1(-v)
+	}, outputs)
+}
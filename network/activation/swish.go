@@ -0,0 +1,30 @@
+package activation
+
+import (
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Swish also known as SiLU, the input scaled by its sigmoid.
+type Swish struct{}
+
+// Value computes v * sigmoid(v) for activation of a single value.
+func (s Swish) Value(v float64) float64 {
+	return v * Sigmoid{}.Value(v)
+}
+
+// MatrixDerivative cannot be computed from swish outputs alone, use InputDerivative instead.
+func (s Swish) MatrixDerivative(mat.Matrix) (*mat.Dense, error) {
+	return nil, fmt.Errorf("swish derivative requires the activation inputs")
+}
+
+// InputDerivative computes swishPrime from the activation inputs as sigmoid(v) + v * sigmoid(v) * (1 - sigmoid(v))
+func (s Swish) InputDerivative(inputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Apply(func(_, _ int, v float64) float64 {
+		sig := Sigmoid{}.Value(v)
+		return sig + v*sig*(1-sig)
+	}, inputs)
+}
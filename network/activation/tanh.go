@@ -26,7 +26,7 @@ func (s Tanh) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
 	if err != nil {
 		return nil, err
 	}
-	rows, _ := outputs.Dims()
-	ones := mat.NewDense(rows, 1, matutil.FillArray(rows, 1))
+	rows, cols := outputs.Dims()
+	ones := mat.NewDense(rows, cols, matutil.FillArray(rows*cols, 1))
 	return matutil.Sub(ones, squared)
 }
@@ -0,0 +1,323 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+	"github.com/benjohns1/neural-net-go/network/activation"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ActivationType names a registered activation, optionally followed by colon-separated parameters like "leaky-relu:0.2".
+type ActivationType string
+
+const (
+	ActivationTypeNone        ActivationType = ""
+	ActivationTypeSigmoid     ActivationType = "sigmoid"
+	ActivationTypeTanh        ActivationType = "tanh"
+	ActivationTypeSoftmax     ActivationType = "softmax"
+	ActivationTypeReLU        ActivationType = "relu"
+	ActivationTypeLeakyReLU   ActivationType = "leaky-relu"
+	ActivationTypeELU         ActivationType = "elu"
+	ActivationTypeSELU        ActivationType = "selu"
+	ActivationTypeSoftplus    ActivationType = "softplus"
+	ActivationTypeSwish       ActivationType = "swish"
+	ActivationTypeSiLU        ActivationType = "silu"
+	ActivationTypeGELU        ActivationType = "gelu"
+	ActivationTypeLinear      ActivationType = "linear"
+	ActivationTypeHardSigmoid ActivationType = "hard-sigmoid"
+)
+
+// legacyActivationTypes maps the integer activation types stored by older model files to their names.
+var legacyActivationTypes = []ActivationType{
+	ActivationTypeNone,
+	ActivationTypeSigmoid,
+	ActivationTypeTanh,
+	ActivationTypeSoftmax,
+}
+
+// UnmarshalJSON accepts activation names and the integer activation types of older model files.
+func (t *ActivationType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = ActivationType(name)
+		return nil
+	}
+	var legacy int
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("activation type must be a name or legacy integer: %v", err)
+	}
+	if legacy < 0 || legacy >= len(legacyActivationTypes) {
+		return fmt.Errorf("unknown legacy activation type %d", legacy)
+	}
+	*t = legacyActivationTypes[legacy]
+	return nil
+}
+
+type Activation interface {
+	Value(float64) float64
+	MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error)
+}
+
+// MatrixActivation is an Activation where each value depends on its whole column, like softmax.
+type MatrixActivation interface {
+	Activation
+	MatrixValue(inputs mat.Matrix) (*mat.Dense, error)
+	// MatrixGradient computes the loss gradient of the activation inputs from its outputs and the loss gradient of its outputs.
+	MatrixGradient(outputs, grads mat.Matrix) (*mat.Dense, error)
+}
+
+// InputDerivativeActivation is an Activation whose derivative can't be computed from its outputs, like swish.
+type InputDerivativeActivation interface {
+	Activation
+	// InputDerivative computes the derivative from the activation inputs.
+	InputDerivative(inputs mat.Matrix) (*mat.Dense, error)
+}
+
+// ActivationFactory creates an activation from the parameters following its registered name.
+type ActivationFactory func(params []float64) (Activation, error)
+
+var activationRegistry = struct {
+	sync.RWMutex
+	factories map[string]ActivationFactory
+}{
+	factories: map[string]ActivationFactory{
+		string(ActivationTypeSigmoid):     noParams(activation.Sigmoid{}),
+		string(ActivationTypeTanh):        noParams(activation.Tanh{}),
+		string(ActivationTypeSoftmax):     noParams(activation.Softmax{}),
+		string(ActivationTypeReLU):        noParams(activation.ReLU{}),
+		string(ActivationTypeLeakyReLU):   leakyReLUFactory,
+		string(ActivationTypeELU):         eluFactory,
+		string(ActivationTypeSELU):        noParams(activation.SELU{}),
+		string(ActivationTypeSoftplus):    noParams(activation.Softplus{}),
+		string(ActivationTypeSwish):       noParams(activation.Swish{}),
+		string(ActivationTypeSiLU):        noParams(activation.Swish{}),
+		string(ActivationTypeGELU):        noParams(activation.GELU{}),
+		string(ActivationTypeLinear):      noParams(activation.Linear{}),
+		string(ActivationTypeHardSigmoid): noParams(activation.HardSigmoid{}),
+	},
+}
+
+// RegisterActivation registers a custom activation under a name so it can be configured and loaded from model files.
+func RegisterActivation(name string, a Activation) error {
+	return RegisterActivationFactory(name, noParams(a))
+}
+
+// RegisterActivationFactory registers a custom parameterized activation under a name so it can be configured and loaded from model files.
+func RegisterActivationFactory(name string, factory ActivationFactory) error {
+	if name == "" || strings.ContainsAny(name, ":,") {
+		return fmt.Errorf("invalid activation name '%s'", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("activation factory for '%s' cannot be nil", name)
+	}
+	activationRegistry.Lock()
+	defer activationRegistry.Unlock()
+	if _, ok := activationRegistry.factories[name]; ok {
+		return fmt.Errorf("activation '%s' is already registered", name)
+	}
+	activationRegistry.factories[name] = factory
+	return nil
+}
+
+// ActivationNames returns the names of all registered activations in alphabetical order.
+func ActivationNames() []string {
+	activationRegistry.RLock()
+	defer activationRegistry.RUnlock()
+	names := make([]string, 0, len(activationRegistry.factories))
+	for name := range activationRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewActivation creates a registered activation from its type, an empty type is a sigmoid for compatibility with older configs.
+func NewActivation(t ActivationType) (Activation, error) {
+	if t == ActivationTypeNone {
+		t = ActivationTypeSigmoid
+	}
+	parts := strings.Split(string(t), ":")
+	activationRegistry.RLock()
+	factory, ok := activationRegistry.factories[parts[0]]
+	activationRegistry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown activation type '%s'", t)
+	}
+	params := make([]float64, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid activation '%s' parameter '%s'", parts[0], p)
+		}
+		params = append(params, v)
+	}
+	a, err := factory(params)
+	if err != nil {
+		return nil, fmt.Errorf("activation '%s': %v", t, err)
+	}
+	return a, nil
+}
+
+func noParams(a Activation) ActivationFactory {
+	return func(params []float64) (Activation, error) {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("takes no parameters")
+		}
+		return a, nil
+	}
+}
+
+func leakyReLUFactory(params []float64) (Activation, error) {
+	switch len(params) {
+	case 0:
+		return activation.LeakyReLU{Slope: 0.01}, nil
+	case 1:
+		if params[0] < 0 {
+			return nil, fmt.Errorf("slope %v cannot be negative", params[0])
+		}
+		return activation.LeakyReLU{Slope: params[0]}, nil
+	default:
+		return nil, fmt.Errorf("takes at most 1 slope parameter")
+	}
+}
+
+func eluFactory(params []float64) (Activation, error) {
+	switch len(params) {
+	case 0:
+		return activation.ELU{Alpha: 1}, nil
+	case 1:
+		if params[0] < 0 {
+			return nil, fmt.Errorf("alpha %v cannot be negative", params[0])
+		}
+		return activation.ELU{Alpha: params[0]}, nil
+	default:
+		return nil, fmt.Errorf("takes at most 1 alpha parameter")
+	}
+}
+
+// layerActivationTypes returns the configured activation type for each layer,
+// falling back to the single activation of older configs when none are set per layer.
+func layerActivationTypes(cfg Config) []ActivationType {
+	return activationTypes(cfg, len(cfg.LayerCounts))
+}
+
+// activationTypes returns the configured activation types, or a number of them from the single activation of older configs.
+func activationTypes(cfg Config, count int) []ActivationType {
+	if len(cfg.Activations) > 0 {
+		return cfg.Activations
+	}
+	types := make([]ActivationType, count)
+	for i := range types {
+		types[i] = cfg.Activation
+	}
+	if len(types) > 0 && cfg.OutputActivation != ActivationTypeNone {
+		types[len(types)-1] = cfg.OutputActivation
+	}
+	return types
+}
+
+// activate applies an activation function to a layer's weighted inputs.
+func activate(a Activation, inputs mat.Matrix) (*mat.Dense, error) {
+	if ma, ok := a.(MatrixActivation); ok {
+		return ma.MatrixValue(inputs)
+	}
+	return matutil.Apply(func(_, _ int, v float64) float64 { return a.Value(v) }, inputs)
+}
+
+// activationGradient computes the loss gradient of a layer's weighted inputs from the inputs, the outputs and the loss gradient of its outputs.
+func activationGradient(a Activation, inputs, outputs, grads mat.Matrix) (*mat.Dense, error) {
+	if ta, ok := a.(tapeActivation); ok {
+		return ta.gradient(inputs, grads)
+	}
+	if ma, ok := a.(MatrixActivation); ok {
+		return ma.MatrixGradient(outputs, grads)
+	}
+	var actDer *mat.Dense
+	var err error
+	if ia, ok := a.(InputDerivativeActivation); ok {
+		actDer, err = ia.InputDerivative(inputs)
+	} else {
+		actDer, err = a.MatrixDerivative(outputs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("applying activation derivative: %v", err)
+	}
+	return matutil.MulElem(grads, actDer)
+}
+
+// activationLayer applies an activation function to its inputs.
+type activationLayer struct {
+	t ActivationType
+	a Activation
+}
+
+type activationLayerConfig struct {
+	Activation ActivationType
+}
+
+// activationCache holds the inputs and outputs of an activation forward pass.
+type activationCache struct {
+	inputs  *mat.Dense
+	outputs *mat.Dense
+}
+
+// NewActivationLayer creates a layer applying a registered activation, an empty type is a sigmoid for compatibility with older configs.
+func NewActivationLayer(t ActivationType) (Layer, error) {
+	a, err := NewActivation(t)
+	if err != nil {
+		return nil, err
+	}
+	return activationLayer{t: t, a: a}, nil
+}
+
+func decodeActivationLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg activationLayerConfig
+	if err := unmarshalLayerConfig(LayerTypeActivation, config, &cfg, params, state, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewActivationLayer(cfg.Activation)
+}
+
+func (l activationLayer) Type() LayerType {
+	return LayerTypeActivation
+}
+
+func (l activationLayer) Forward(inputs *mat.Dense, _ Pass) (*mat.Dense, interface{}, error) {
+	outputs, err := activate(l.a, inputs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("applying activation function: %v", err)
+	}
+	return outputs, activationCache{inputs: inputs, outputs: outputs}, nil
+}
+
+func (l activationLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	c, ok := cache.(activationCache)
+	if !ok {
+		return nil, nil, fmt.Errorf("activation layer cache must be from a forward pass")
+	}
+	inputGrads, err := activationGradient(l.a, c.inputs, c.outputs, grads)
+	return inputGrads, nil, err
+}
+
+func (l activationLayer) Params() []Param {
+	return nil
+}
+
+func (l activationLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("activation layer has no params, got %d", len(params))
+	}
+	return l, nil
+}
+
+func (l activationLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := marshalLayerConfig(activationLayerConfig{Activation: l.t})
+	return config, nil, err
+}
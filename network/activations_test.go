@@ -0,0 +1,161 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewActivation_Derivative(t *testing.T) {
+	inputs := []float64{-3, -1.5, -0.5, -0.1, 0.1, 0.5, 1.5, 3}
+	for _, name := range network.ActivationNames() {
+		if name == string(network.ActivationTypeSoftmax) {
+			continue // not element-wise, covered by the softmax network tests
+		}
+		t.Run(name, func(t *testing.T) {
+			a, err := network.NewActivation(network.ActivationType(name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := mat.NewDense(len(inputs), 1, inputs)
+			out := mat.NewDense(len(inputs), 1, nil)
+			out.Apply(func(_, _ int, v float64) float64 { return a.Value(v) }, in)
+			var got *mat.Dense
+			if ia, ok := a.(network.InputDerivativeActivation); ok {
+				got, err = ia.InputDerivative(in)
+			} else {
+				got, err = a.MatrixDerivative(out)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			const h = 1e-6
+			for i, v := range inputs {
+				want := (a.Value(v+h) - a.Value(v-h)) / (2 * h)
+				if d := got.At(i, 0); math.Abs(d-want) > 1e-5 {
+					t.Errorf("derivative at %v = %v, want %v", v, d, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewActivation(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       network.ActivationType
+		input   float64
+		want    float64
+		wantErr bool
+	}{
+		{name: "should default to sigmoid", t: network.ActivationTypeNone, input: 0, want: 0.5},
+		{name: "should use the default leaky relu slope", t: network.ActivationTypeLeakyReLU, input: -1, want: -0.01},
+		{name: "should parse a leaky relu slope", t: "leaky-relu:0.2", input: -1, want: -0.2},
+		{name: "should parse an elu alpha", t: "elu:2", input: -math.Inf(1), want: -2},
+		{name: "should error on an unknown activation", t: "unknown", wantErr: true},
+		{name: "should error on an invalid parameter", t: "leaky-relu:x", wantErr: true},
+		{name: "should error on a negative slope", t: "leaky-relu:-1", wantErr: true},
+		{name: "should error on parameters for an activation that takes none", t: "relu:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := network.NewActivation(tt.t)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewActivation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := a.Value(tt.input); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type doubleActivation struct{}
+
+func (d doubleActivation) Value(v float64) float64 {
+	return 2 * v
+}
+
+func (d doubleActivation) MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error) {
+	r, c := outputs.Dims()
+	o := mat.NewDense(r, c, nil)
+	o.Apply(func(_, _ int, _ float64) float64 { return 2 }, outputs)
+	return o, nil
+}
+
+func init() {
+	if err := network.RegisterActivation("test-double", doubleActivation{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterActivation(t *testing.T) {
+	if err := network.RegisterActivation("test-double", doubleActivation{}); err == nil {
+		t.Errorf("RegisterActivation() expected error registering a duplicate name")
+	}
+	if err := network.RegisterActivation("sigmoid", doubleActivation{}); err == nil {
+		t.Errorf("RegisterActivation() expected error replacing a built-in activation")
+	}
+	if err := network.RegisterActivation("bad:name", doubleActivation{}); err == nil {
+		t.Errorf("RegisterActivation() expected error for a name containing a parameter separator")
+	}
+
+	n, err := network.NewRandom(network.Config{
+		InputCount:  2,
+		LayerCounts: []int{2, 1},
+		Activations: []network.ActivationType{"test-double", network.ActivationTypeLinear},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.Config().Activations, n.Config().Activations; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored activations = %v, want %v", got, want)
+	}
+	if got, want := predictVector(t, restored, []float64{1, 2}), predictVector(t, n, []float64{1, 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored Predict() = %v, want %v", got, want)
+	}
+}
+
+func TestActivationType_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    network.ActivationType
+		wantErr bool
+	}{
+		{data: `"relu"`, want: network.ActivationTypeReLU},
+		{data: `0`, want: network.ActivationTypeNone},
+		{data: `1`, want: network.ActivationTypeSigmoid},
+		{data: `2`, want: network.ActivationTypeTanh},
+		{data: `3`, want: network.ActivationTypeSoftmax},
+		{data: `4`, wantErr: true},
+		{data: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got network.ActivationType
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
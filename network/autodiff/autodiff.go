@@ -0,0 +1,391 @@
+// Package autodiff computes gradients of matrix expressions with reverse-mode automatic differentiation.
+// Operations on nodes record themselves on a tape, and a single Backward call propagates gradients
+// from a result to every node it was computed from.
+package autodiff
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Tape records the operations of an expression in the order they were computed.
+// The first failed operation is kept as the tape's error, and operations after it are skipped.
+type Tape struct {
+	nodes []*Node
+	err   error
+}
+
+// Node is a matrix value recorded on a tape, along with its gradient after Backward.
+type Node struct {
+	tape  *Tape
+	value *mat.Dense
+	grad  *mat.Dense
+	// backward adds the gradients of the node's operands from the node's gradient
+	backward func(grad *mat.Dense) error
+}
+
+// NewTape creates an empty tape.
+func NewTape() *Tape {
+	return &Tape{}
+}
+
+// Var records a matrix as an input node of the tape.
+func (t *Tape) Var(m *mat.Dense) *Node {
+	if m == nil {
+		return t.fail(fmt.Errorf("variable cannot be nil"))
+	}
+	return t.record(m, nil)
+}
+
+// Err returns the error of the first failed operation recorded on the tape, if any.
+func (t *Tape) Err() error {
+	return t.err
+}
+
+func (t *Tape) record(value *mat.Dense, backward func(grad *mat.Dense) error) *Node {
+	n := &Node{tape: t, value: value, backward: backward}
+	t.nodes = append(t.nodes, n)
+	return n
+}
+
+func (t *Tape) fail(err error) *Node {
+	if t.err == nil {
+		t.err = err
+	}
+	return &Node{tape: t}
+}
+
+// Value returns the node's matrix, nil if the tape failed before computing it.
+func (n *Node) Value() *mat.Dense {
+	return n.value
+}
+
+// Grad returns the gradient of the last Backward result with respect to the node, zero if the node didn't contribute to it.
+func (n *Node) Grad() *mat.Dense {
+	if n.grad == nil && n.value != nil {
+		r, c := n.value.Dims()
+		return mat.NewDense(r, c, nil)
+	}
+	return n.grad
+}
+
+// Backward computes the gradient of the sum of the node's values with respect to every node recorded before it.
+func (n *Node) Backward() error {
+	if n.value == nil {
+		return n.BackwardFrom(nil)
+	}
+	r, c := n.value.Dims()
+	return n.BackwardFrom(mat.NewDense(r, c, matutil.FillArray(r*c, 1)))
+}
+
+// BackwardFrom propagates a gradient of the node's values, like the loss gradient of a layer's outputs,
+// to every node recorded before it.
+func (n *Node) BackwardFrom(grad *mat.Dense) error {
+	t := n.tape
+	if t.err != nil {
+		return t.err
+	}
+	if grad == nil {
+		return fmt.Errorf("gradient cannot be nil")
+	}
+	r, c := n.value.Dims()
+	if gr, gc := grad.Dims(); gr != r || gc != c {
+		return fmt.Errorf("gradient dimensions %dx%d must equal node dimensions %dx%d", gr, gc, r, c)
+	}
+	last := -1
+	for i, node := range t.nodes {
+		node.grad = nil
+		if node == n {
+			last = i
+		}
+	}
+	if last < 0 {
+		return fmt.Errorf("node is not recorded on its tape")
+	}
+	n.grad = mat.DenseCopyOf(grad)
+	for i := last; i >= 0; i-- {
+		node := t.nodes[i]
+		if node.grad == nil || node.backward == nil {
+			continue
+		}
+		if err := node.backward(node.grad); err != nil {
+			return fmt.Errorf("propagating gradient: %v", err)
+		}
+	}
+	return nil
+}
+
+// accumulate adds to the gradient of a node.
+func (n *Node) accumulate(grad *mat.Dense) {
+	if n.grad == nil {
+		n.grad = mat.DenseCopyOf(grad)
+		return
+	}
+	n.grad.Add(n.grad, grad)
+}
+
+// unary records an operation of a single node, skipping it if the tape has failed.
+func (n *Node) unary(forward func(x *mat.Dense) (*mat.Dense, error), backward func(x, y, grad *mat.Dense) (*mat.Dense, error)) *Node {
+	t := n.tape
+	if t.err != nil {
+		return &Node{tape: t}
+	}
+	y, err := forward(n.value)
+	if err != nil {
+		return t.fail(err)
+	}
+	return t.record(y, func(grad *mat.Dense) error {
+		g, err := backward(n.value, y, grad)
+		if err != nil {
+			return err
+		}
+		n.accumulate(g)
+		return nil
+	})
+}
+
+// binary records an operation of two nodes on the same tape, skipping it if the tape has failed.
+func (n *Node) binary(o *Node, forward func(a, b *mat.Dense) (*mat.Dense, error), backward func(a, b, grad *mat.Dense) (*mat.Dense, *mat.Dense, error)) *Node {
+	t := n.tape
+	if o.tape != t {
+		return t.fail(fmt.Errorf("operands must be recorded on the same tape"))
+	}
+	if t.err != nil {
+		return &Node{tape: t}
+	}
+	y, err := forward(n.value, o.value)
+	if err != nil {
+		return t.fail(err)
+	}
+	return t.record(y, func(grad *mat.Dense) error {
+		ga, gb, err := backward(n.value, o.value, grad)
+		if err != nil {
+			return err
+		}
+		n.accumulate(ga)
+		o.accumulate(gb)
+		return nil
+	})
+}
+
+func sameDims(a, b *mat.Dense) error {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	if ar != br || ac != bc {
+		return fmt.Errorf("operand dimensions %dx%d and %dx%d must be equal", ar, ac, br, bc)
+	}
+	return nil
+}
+
+// Add records the element-wise sum of two nodes of equal dimensions.
+func (n *Node) Add(o *Node) *Node {
+	return n.binary(o, func(a, b *mat.Dense) (*mat.Dense, error) {
+		if err := sameDims(a, b); err != nil {
+			return nil, err
+		}
+		return matutil.Add(a, b)
+	}, func(_, _, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		return grad, grad, nil
+	})
+}
+
+// Sub records the element-wise difference of two nodes of equal dimensions.
+func (n *Node) Sub(o *Node) *Node {
+	return n.binary(o, func(a, b *mat.Dense) (*mat.Dense, error) {
+		if err := sameDims(a, b); err != nil {
+			return nil, err
+		}
+		return matutil.Sub(a, b)
+	}, func(_, _, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		neg, err := matutil.Scale(-1, grad)
+		return grad, neg, err
+	})
+}
+
+// MulElem records the element-wise product of two nodes of equal dimensions.
+func (n *Node) MulElem(o *Node) *Node {
+	return n.binary(o, func(a, b *mat.Dense) (*mat.Dense, error) {
+		if err := sameDims(a, b); err != nil {
+			return nil, err
+		}
+		return matutil.MulElem(a, b)
+	}, func(a, b, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		ga, err := matutil.MulElem(grad, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		gb, err := matutil.MulElem(grad, a)
+		return ga, gb, err
+	})
+}
+
+// DivElem records the element-wise quotient of two nodes of equal dimensions.
+func (n *Node) DivElem(o *Node) *Node {
+	return n.binary(o, func(a, b *mat.Dense) (*mat.Dense, error) {
+		if err := sameDims(a, b); err != nil {
+			return nil, err
+		}
+		return matutil.Apply(func(i, j int, v float64) float64 { return v / b.At(i, j) }, a)
+	}, func(a, b, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		ga, err := matutil.Apply(func(i, j int, g float64) float64 { return g / b.At(i, j) }, grad)
+		if err != nil {
+			return nil, nil, err
+		}
+		gb, err := matutil.Apply(func(i, j int, g float64) float64 {
+			d := b.At(i, j)
+			return -g * a.At(i, j) / (d * d)
+		}, grad)
+		return ga, gb, err
+	})
+}
+
+// Mul records the matrix product of two nodes.
+func (n *Node) Mul(o *Node) *Node {
+	return n.binary(o, func(a, b *mat.Dense) (*mat.Dense, error) {
+		if _, ac := a.Dims(); ac != b.RawMatrix().Rows {
+			return nil, fmt.Errorf("matrix product operand columns %d must equal second operand rows %d", ac, b.RawMatrix().Rows)
+		}
+		return matutil.Dot(a, b)
+	}, func(a, b, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		ga, err := matutil.Dot(grad, b.T())
+		if err != nil {
+			return nil, nil, err
+		}
+		gb, err := matutil.Dot(a.T(), grad)
+		return ga, gb, err
+	})
+}
+
+// AddColumn records the sum of a node and a single-column node added to each of its columns, like biases.
+func (n *Node) AddColumn(col *Node) *Node {
+	return n.binary(col, func(a, c *mat.Dense) (*mat.Dense, error) {
+		return matutil.AddColumn(a, c)
+	}, func(_, _, grad *mat.Dense) (*mat.Dense, *mat.Dense, error) {
+		gc, err := matutil.SumColumns(grad)
+		return grad, gc, err
+	})
+}
+
+// Scale records the node multiplied by a constant.
+func (n *Node) Scale(f float64) *Node {
+	return n.unary(func(x *mat.Dense) (*mat.Dense, error) {
+		return matutil.Scale(f, x)
+	}, func(_, _, grad *mat.Dense) (*mat.Dense, error) {
+		return matutil.Scale(f, grad)
+	})
+}
+
+// Map records a function applied to each element of the node, with its derivative
+// computed from the input and output of each element.
+func (n *Node) Map(f func(x float64) float64, derivative func(x, y float64) float64) *Node {
+	return n.unary(func(x *mat.Dense) (*mat.Dense, error) {
+		return matutil.Apply(func(_, _ int, v float64) float64 { return f(v) }, x)
+	}, func(x, y, grad *mat.Dense) (*mat.Dense, error) {
+		return matutil.Apply(func(i, j int, g float64) float64 { return g * derivative(x.At(i, j), y.At(i, j)) }, grad)
+	})
+}
+
+// Exp records the exponential of each element.
+func (n *Node) Exp() *Node {
+	return n.Map(math.Exp, func(_, y float64) float64 { return y })
+}
+
+// Log records the natural logarithm of each element.
+func (n *Node) Log() *Node {
+	return n.Map(math.Log, func(x, _ float64) float64 { return 1 / x })
+}
+
+// Tanh records the hyperbolic tangent of each element.
+func (n *Node) Tanh() *Node {
+	return n.Map(math.Tanh, func(_, y float64) float64 { return 1 - y*y })
+}
+
+// Sigmoid records the logistic function of each element.
+func (n *Node) Sigmoid() *Node {
+	return n.Map(func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }, func(_, y float64) float64 { return y * (1 - y) })
+}
+
+// Square records the square of each element.
+func (n *Node) Square() *Node {
+	return n.Map(func(x float64) float64 { return x * x }, func(x, _ float64) float64 { return 2 * x })
+}
+
+// Abs records the absolute value of each element, with a zero gradient at zero.
+func (n *Node) Abs() *Node {
+	return n.Map(math.Abs, func(x, _ float64) float64 {
+		switch {
+		case x > 0:
+			return 1
+		case x < 0:
+			return -1
+		default:
+			return 0
+		}
+	})
+}
+
+// Max records the larger of each element and a constant, like ReLU with a constant of zero.
+func (n *Node) Max(c float64) *Node {
+	return n.Map(func(x float64) float64 { return math.Max(x, c) }, func(x, _ float64) float64 {
+		if x > c {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Sum records the sum of all elements as a 1x1 node.
+func (n *Node) Sum() *Node {
+	return n.unary(func(x *mat.Dense) (*mat.Dense, error) {
+		return mat.NewDense(1, 1, []float64{mat.Sum(x)}), nil
+	}, func(x, _, grad *mat.Dense) (*mat.Dense, error) {
+		r, c := x.Dims()
+		return mat.NewDense(r, c, matutil.FillArray(r*c, grad.At(0, 0))), nil
+	})
+}
+
+// Mean records the mean of all elements as a 1x1 node.
+func (n *Node) Mean() *Node {
+	if n.value == nil {
+		return n.Sum()
+	}
+	r, c := n.value.Dims()
+	return n.Sum().Scale(1 / float64(r*c))
+}
+
+// ColumnSums records the sum of each column as a single-row node.
+func (n *Node) ColumnSums() *Node {
+	return n.unary(func(x *mat.Dense) (*mat.Dense, error) {
+		r, c := x.Dims()
+		sums := mat.NewDense(1, c, nil)
+		for j := 0; j < c; j++ {
+			sums.Set(0, j, mat.Sum(x.Slice(0, r, j, j+1)))
+		}
+		return sums, nil
+	}, func(x, _, grad *mat.Dense) (*mat.Dense, error) {
+		r, c := x.Dims()
+		return matutil.Apply(func(_, j int, _ float64) float64 { return grad.At(0, j) }, mat.NewDense(r, c, nil))
+	})
+}
+
+// BroadcastRows records a single-row node repeated as a number of rows.
+func (n *Node) BroadcastRows(rows int) *Node {
+	return n.unary(func(x *mat.Dense) (*mat.Dense, error) {
+		r, c := x.Dims()
+		if r != 1 || rows < 1 {
+			return nil, fmt.Errorf("broadcast node must have a single row repeated at least once, got %d rows repeated %d times", r, rows)
+		}
+		return matutil.Apply(func(_, j int, _ float64) float64 { return x.At(0, j) }, mat.NewDense(rows, c, nil))
+	}, func(_, _, grad *mat.Dense) (*mat.Dense, error) {
+		_, c := grad.Dims()
+		sums := mat.NewDense(1, c, nil)
+		for j := 0; j < c; j++ {
+			sums.Set(0, j, mat.Sum(grad.ColView(j)))
+		}
+		return sums, nil
+	})
+}
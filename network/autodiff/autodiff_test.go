@@ -0,0 +1,124 @@
+package autodiff_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network/autodiff"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNode_Backward(t *testing.T) {
+	a := mat.NewDense(2, 3, []float64{0.5, -1, 2, 0.1, 0.7, -0.3})
+	b := mat.NewDense(2, 3, []float64{1.5, 0.2, -0.4, 0.9, -1.2, 0.6})
+	w := mat.NewDense(3, 2, []float64{0.3, -0.6, 0.8, 0.2, -0.5, 0.4})
+	col := mat.NewDense(2, 1, []float64{0.25, -0.75})
+	softmax := func(x *autodiff.Node) *autodiff.Node {
+		e := x.Exp()
+		r, _ := x.Value().Dims()
+		return e.DivElem(e.ColumnSums().BroadcastRows(r))
+	}
+	tests := []struct {
+		name string
+		f    func(a, b, w, col *autodiff.Node) *autodiff.Node
+	}{
+		{name: "add", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.Add(b).Square() }},
+		{name: "sub", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.Sub(b).Square() }},
+		{name: "mul elem", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.MulElem(b).MulElem(a) }},
+		{name: "div elem", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.DivElem(b.Exp()) }},
+		{name: "mul", f: func(a, _, w, _ *autodiff.Node) *autodiff.Node { return a.Mul(w).Tanh() }},
+		{name: "add column", f: func(a, _, _, col *autodiff.Node) *autodiff.Node { return a.AddColumn(col).Sigmoid() }},
+		{name: "scale and max", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.Scale(3).Max(0.2).MulElem(b) }},
+		{name: "log and abs", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.Abs().Log().MulElem(b) }},
+		{name: "mean", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return a.MulElem(b).Mean() }},
+		{name: "softmax", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { return softmax(a).MulElem(b) }},
+		{name: "reused node", f: func(a, b, _, _ *autodiff.Node) *autodiff.Node { s := a.Add(b); return s.MulElem(s).Add(s) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := func(values ...*mat.Dense) float64 {
+				tape := autodiff.NewTape()
+				out := tt.f(tape.Var(values[0]), tape.Var(values[1]), tape.Var(values[2]), tape.Var(values[3]))
+				if err := tape.Err(); err != nil {
+					t.Fatal(err)
+				}
+				return mat.Sum(out.Value())
+			}
+			tape := autodiff.NewTape()
+			vars := []*autodiff.Node{tape.Var(a), tape.Var(b), tape.Var(w), tape.Var(col)}
+			out := tt.f(vars[0], vars[1], vars[2], vars[3])
+			if err := out.Backward(); err != nil {
+				t.Fatal(err)
+			}
+			values := []*mat.Dense{a, b, w, col}
+			const h = 1e-6
+			for v, value := range values {
+				r, c := value.Dims()
+				for i := 0; i < r; i++ {
+					for j := 0; j < c; j++ {
+						plus, minus := copies(values), copies(values)
+						plus[v].Set(i, j, value.At(i, j)+h)
+						minus[v].Set(i, j, value.At(i, j)-h)
+						want := (sum(plus...) - sum(minus...)) / (2 * h)
+						if got := vars[v].Grad().At(i, j); math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+							t.Errorf("Grad() of variable %d at %d,%d = %v, want %v", v, i, j, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func copies(values []*mat.Dense) []*mat.Dense {
+	c := make([]*mat.Dense, len(values))
+	for i, v := range values {
+		c[i] = mat.DenseCopyOf(v)
+	}
+	return c
+}
+
+func TestNode_BackwardFrom(t *testing.T) {
+	tape := autodiff.NewTape()
+	x := tape.Var(mat.NewDense(2, 1, []float64{1, 2}))
+	y := x.Scale(3)
+	if err := y.BackwardFrom(mat.NewDense(2, 1, []float64{0.5, -1})); err != nil {
+		t.Fatal(err)
+	}
+	if want := mat.NewDense(2, 1, []float64{1.5, -3}); !mat.Equal(x.Grad(), want) {
+		t.Errorf("Grad() = %v, want %v", mat.Formatted(x.Grad()), mat.Formatted(want))
+	}
+	if err := y.BackwardFrom(mat.NewDense(1, 1, nil)); err == nil {
+		t.Errorf("BackwardFrom() with mismatched gradient dimensions error = nil, want error")
+	}
+	// gradients are recomputed, not accumulated, by each call
+	if err := y.Backward(); err != nil {
+		t.Fatal(err)
+	}
+	if want := mat.NewDense(2, 1, []float64{3, 3}); !mat.Equal(x.Grad(), want) {
+		t.Errorf("Grad() after second Backward() = %v, want %v", mat.Formatted(x.Grad()), mat.Formatted(want))
+	}
+}
+
+func TestTape_Err(t *testing.T) {
+	tape := autodiff.NewTape()
+	a := tape.Var(mat.NewDense(2, 2, nil))
+	b := tape.Var(mat.NewDense(3, 1, nil))
+	out := a.Add(b).Exp().Sum()
+	if tape.Err() == nil {
+		t.Fatalf("Err() after adding mismatched dimensions = nil, want error")
+	}
+	if out.Value() != nil {
+		t.Errorf("Value() after a failed operation = %v, want nil", out.Value())
+	}
+	if err := out.Backward(); err == nil {
+		t.Errorf("Backward() after a failed operation error = nil, want error")
+	}
+
+	other := autodiff.NewTape()
+	a.Add(other.Var(mat.NewDense(2, 2, nil)))
+	if err := other.Err(); err != nil {
+		t.Errorf("Err() of the other tape = %v, want nil", err)
+	}
+}
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	LayerTypeConv2D    LayerType = "conv-2d"
+	LayerTypeMaxPool2D LayerType = "max-pool-2d"
+	LayerTypeAvgPool2D LayerType = "avg-pool-2d"
+	LayerTypeFlatten   LayerType = "flatten"
+)
+
+// ImageShape is the channels, height and width of image data, stored in each matrix column channel by channel, row by row.
+type ImageShape struct {
+	Channels int
+	Height   int
+	Width    int
+}
+
+// Size returns the number of values in an image.
+func (s ImageShape) Size() int {
+	return s.Channels * s.Height * s.Width
+}
+
+func (s ImageShape) String() string {
+	return fmt.Sprintf("%dx%dx%d", s.Channels, s.Height, s.Width)
+}
+
+func (s ImageShape) validate() error {
+	if s.Channels < 1 || s.Height < 1 || s.Width < 1 {
+		return fmt.Errorf("image shape %s dimensions must be at least 1", s)
+	}
+	return nil
+}
+
+// window is the geometry of a kernel or pool sliding over image rows and columns.
+type window struct {
+	Height  int
+	Width   int
+	Stride  int
+	Padding int
+}
+
+// outputSize returns the number of window positions along an image dimension.
+func (w window) outputSize(size, windowSize int) int {
+	return (size+2*w.Padding-windowSize)/w.Stride + 1
+}
+
+// outputShape returns the height and width of the window positions over an image.
+func (w window) outputShape(in ImageShape) (int, int, error) {
+	if w.Height < 1 || w.Width < 1 || w.Stride < 1 || w.Padding < 0 {
+		return 0, 0, fmt.Errorf("window %dx%d must be at least 1x1 with a stride of at least 1 and non-negative padding, got stride %d and padding %d", w.Height, w.Width, w.Stride, w.Padding)
+	}
+	height, width := w.outputSize(in.Height, w.Height), w.outputSize(in.Width, w.Width)
+	if height < 1 || width < 1 {
+		return 0, 0, fmt.Errorf("window %dx%d doesn't fit image %s with padding %d", w.Height, w.Width, in, w.Padding)
+	}
+	return height, width, nil
+}
+
+// conv2DLayer is a 2D convolution layer, sliding each filter over all input channels to produce one output channel per filter.
+type conv2DLayer struct {
+	in      ImageShape
+	window  window
+	out     ImageShape
+	weights *mat.Dense // one row for each filter, one column for each input channel, kernel row and kernel column
+	biases  *mat.Dense // single-column bias for each filter
+}
+
+type conv2DLayerConfig struct {
+	Input  ImageShape
+	Kernel window
+}
+
+// NewConv2DLayer creates a 2D convolution layer over images of an input shape, with a kernel size, stride and zero padding.
+// Weights have one row for each filter and a column for each input channel, kernel row and kernel column in that order,
+// biases are a single column with one bias for each filter. Nil biases are zero.
+func NewConv2DLayer(in ImageShape, kernelHeight, kernelWidth, stride, padding int, weights, biases *mat.Dense) (Layer, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	w := window{Height: kernelHeight, Width: kernelWidth, Stride: stride, Padding: padding}
+	height, width, err := w.outputShape(in)
+	if err != nil {
+		return nil, err
+	}
+	if weights == nil {
+		return nil, fmt.Errorf("convolution weights cannot be nil")
+	}
+	filters, cols := weights.Dims()
+	if want := in.Channels * kernelHeight * kernelWidth; cols != want {
+		return nil, fmt.Errorf("convolution weight columns %d must equal input channels times kernel size %d", cols, want)
+	}
+	if biases == nil {
+		biases = mat.NewDense(filters, 1, nil)
+	}
+	if rc, cc := biases.Dims(); rc != filters || cc != 1 {
+		return nil, fmt.Errorf("convolution bias dimensions %dx%d must be %dx1", rc, cc, filters)
+	}
+	return conv2DLayer{
+		in:      in,
+		window:  w,
+		out:     ImageShape{Channels: filters, Height: height, Width: width},
+		weights: weights,
+		biases:  biases,
+	}, nil
+}
+
+func decodeConv2DLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg conv2DLayerConfig
+	if err := unmarshalLayerConfig(LayerTypeConv2D, config, &cfg, params, state, 2, 0); err != nil {
+		return nil, err
+	}
+	return NewConv2DLayer(cfg.Input, cfg.Kernel.Height, cfg.Kernel.Width, cfg.Kernel.Stride, cfg.Kernel.Padding, params[0], params[1])
+}
+
+func (l conv2DLayer) Type() LayerType {
+	return LayerTypeConv2D
+}
+
+func (l conv2DLayer) Forward(inputs *mat.Dense, _ Pass) (*mat.Dense, interface{}, error) {
+	rows, cols := inputs.Dims()
+	if rows != l.in.Size() {
+		return nil, nil, fmt.Errorf("convolution input rows %d must equal image size %d of shape %s", rows, l.in.Size(), l.in)
+	}
+	outputs := mat.NewDense(l.out.Size(), cols, nil)
+	patches := make([]*mat.Dense, cols)
+	for j := 0; j < cols; j++ {
+		patches[j] = im2col(mat.Col(nil, j, inputs), l.in, l.window)
+		product, err := matutil.Dot(l.weights, patches[j])
+		if err != nil {
+			return nil, nil, fmt.Errorf("applying filters: %v", err)
+		}
+		weighted, err := matutil.AddColumn(product, l.biases)
+		if err != nil {
+			return nil, nil, fmt.Errorf("applying biases: %v", err)
+		}
+		outputs.SetCol(j, weighted.RawMatrix().Data)
+	}
+	return outputs, patches, nil
+}
+
+func (l conv2DLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	patches, ok := cache.([]*mat.Dense)
+	if !ok {
+		return nil, nil, fmt.Errorf("convolution layer cache must be its input patches")
+	}
+	_, cols := grads.Dims()
+	if len(patches) != cols {
+		return nil, nil, fmt.Errorf("convolution gradient batch size %d must equal forward pass batch size %d", cols, len(patches))
+	}
+	positions := l.out.Height * l.out.Width
+	weightGrad := mat.NewDense(l.out.Channels, l.in.Channels*l.window.Height*l.window.Width, nil)
+	biasGrad := mat.NewDense(l.out.Channels, 1, nil)
+	inputGrads := mat.NewDense(l.in.Size(), cols, nil)
+	for j := 0; j < cols; j++ {
+		outputGrads := mat.NewDense(l.out.Channels, positions, mat.Col(nil, j, grads))
+		var recordWeightGrad mat.Dense
+		recordWeightGrad.Mul(outputGrads, patches[j].T())
+		weightGrad.Add(weightGrad, &recordWeightGrad)
+		for f := 0; f < l.out.Channels; f++ {
+			biasGrad.Set(f, 0, biasGrad.At(f, 0)+mat.Sum(outputGrads.RowView(f)))
+		}
+		var patchGrads mat.Dense
+		patchGrads.Mul(l.weights.T(), outputGrads)
+		inputGrads.SetCol(j, col2im(&patchGrads, l.in, l.window))
+	}
+	scale := 1 / float64(cols)
+	weightGrad.Scale(scale, weightGrad)
+	biasGrad.Scale(scale, biasGrad)
+	return inputGrads, []*mat.Dense{weightGrad, biasGrad}, nil
+}
+
+func (l conv2DLayer) Params() []Param {
+	return []Param{{Value: l.weights, Regularized: true}, {Value: l.biases}}
+}
+
+func (l conv2DLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 2 {
+		return nil, fmt.Errorf("convolution layer must have 2 params, got %d", len(params))
+	}
+	return NewConv2DLayer(l.in, l.window.Height, l.window.Width, l.window.Stride, l.window.Padding, params[0], params[1])
+}
+
+func (l conv2DLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := marshalLayerConfig(conv2DLayerConfig{Input: l.in, Kernel: l.window})
+	return config, nil, err
+}
+
+// im2col arranges the window patches of an image as columns, one for each window position,
+// with a row for each channel, window row and window column. Padding is zero.
+func im2col(image []float64, in ImageShape, w window) *mat.Dense {
+	height, width := w.outputSize(in.Height, w.Height), w.outputSize(in.Width, w.Width)
+	patches := mat.NewDense(in.Channels*w.Height*w.Width, height*width, nil)
+	eachPatchValue(in, w, height, width, func(row, col, index int) {
+		patches.Set(row, col, image[index])
+	})
+	return patches
+}
+
+// col2im sums window patch gradients arranged by im2col back into the gradient of each image value.
+func col2im(patches *mat.Dense, in ImageShape, w window) []float64 {
+	height, width := w.outputSize(in.Height, w.Height), w.outputSize(in.Width, w.Width)
+	image := make([]float64, in.Size())
+	eachPatchValue(in, w, height, width, func(row, col, index int) {
+		image[index] += patches.At(row, col)
+	})
+	return image
+}
+
+// eachPatchValue calls fn with the patch row and column of every window value inside the image, and the index of the image value.
+func eachPatchValue(in ImageShape, w window, height, width int, fn func(row, col, index int)) {
+	for c := 0; c < in.Channels; c++ {
+		for ky := 0; ky < w.Height; ky++ {
+			for kx := 0; kx < w.Width; kx++ {
+				row := (c*w.Height+ky)*w.Width + kx
+				for oy := 0; oy < height; oy++ {
+					y := oy*w.Stride + ky - w.Padding
+					if y < 0 || y >= in.Height {
+						continue
+					}
+					for ox := 0; ox < width; ox++ {
+						x := ox*w.Stride + kx - w.Padding
+						if x < 0 || x >= in.Width {
+							continue
+						}
+						fn(row, oy*width+ox, (c*in.Height+y)*in.Width+x)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// image3x3 is a single-channel 3x3 image with values 1 to 9, row by row.
+var image3x3 = mat.NewDense(9, 1, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+func TestNewConv2DLayer_Forward(t *testing.T) {
+	in := network.ImageShape{Channels: 1, Height: 3, Width: 3}
+	diagonal := mat.NewDense(1, 4, []float64{1, 0, 0, 1})
+	tests := []struct {
+		name    string
+		stride  int
+		padding int
+		want    []float64
+	}{
+		{name: "should sum each window diagonal", stride: 1, want: []float64{6.5, 8.5, 12.5, 14.5}},
+		{name: "should treat padding as zero", stride: 2, padding: 1, want: []float64{1.5, 3.5, 7.5, 14.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := network.NewConv2DLayer(in, 2, 2, tt.stride, tt.padding, diagonal, mat.NewDense(1, 1, []float64{0.5}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs, _, err := l.Forward(image3x3, network.Pass{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outputs.RawMatrix().Data; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Forward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewConv2DLayer_Error(t *testing.T) {
+	in := network.ImageShape{Channels: 2, Height: 3, Width: 3}
+	tests := []struct {
+		name    string
+		kernel  int
+		stride  int
+		weights *mat.Dense
+	}{
+		{name: "should error on weights without a column for each channel", kernel: 2, stride: 1, weights: mat.NewDense(1, 4, nil)},
+		{name: "should error on a kernel larger than the image", kernel: 4, stride: 1, weights: mat.NewDense(1, 32, nil)},
+		{name: "should error on a zero stride", kernel: 2, weights: mat.NewDense(1, 8, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := network.NewConv2DLayer(in, tt.kernel, tt.kernel, tt.stride, 0, tt.weights, nil); err == nil {
+				t.Errorf("NewConv2DLayer() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNewPool2DLayer(t *testing.T) {
+	in := network.ImageShape{Channels: 1, Height: 3, Width: 3}
+	must := newLayer(t)
+	tests := []struct {
+		name          string
+		layer         network.Layer
+		want          []float64
+		wantInputGrad []float64
+	}{
+		{
+			name:          "should route max pooling gradients to each maximum",
+			layer:         must(network.NewMaxPool2DLayer(in, 2, 1)),
+			want:          []float64{5, 6, 8, 9},
+			wantInputGrad: []float64{0, 0, 0, 0, 1, 2, 0, 3, 4},
+		},
+		{
+			name:          "should spread average pooling gradients over each window",
+			layer:         must(network.NewAvgPool2DLayer(in, 2, 1)),
+			want:          []float64{3, 4, 6, 7},
+			wantInputGrad: []float64{0.25, 0.75, 0.5, 1, 2.5, 1.5, 0.75, 1.75, 1},
+		},
+		{
+			name:          "should default the stride to the window size",
+			layer:         must(network.NewMaxPool2DLayer(in, 2, 0)),
+			want:          []float64{5},
+			wantInputGrad: []float64{0, 0, 0, 0, 1, 0, 0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, cache, err := tt.layer.Forward(image3x3, network.Pass{Training: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outputs.RawMatrix().Data; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Forward() = %v, want %v", got, tt.want)
+			}
+			grads := make([]float64, len(tt.want))
+			for i := range grads {
+				grads[i] = float64(i + 1)
+			}
+			inputGrads, _, err := tt.layer.Backward(mat.NewDense(len(grads), 1, grads), cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := inputGrads.RawMatrix().Data; !reflect.DeepEqual(got, tt.wantInputGrad) {
+				t.Errorf("Backward() input gradients = %v, want %v", got, tt.wantInputGrad)
+			}
+		})
+	}
+}
+
+func TestNewConv2DLayer_Backward(t *testing.T) {
+	in := network.ImageShape{Channels: 2, Height: 4, Width: 3}
+	weights := mat.NewDense(3, 2*2*2, nil)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 8; j++ {
+			weights.Set(i, j, math.Sin(float64(i*8+j)))
+		}
+	}
+	biases := mat.NewDense(3, 1, []float64{0.1, -0.2, 0.3})
+	inputs := mat.NewDense(in.Size(), 1, nil)
+	for i := 0; i < in.Size(); i++ {
+		inputs.Set(i, 0, math.Cos(float64(i)))
+	}
+	l, err := network.NewConv2DLayer(in, 2, 2, 2, 1, weights, biases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLayerGradients(t, l, inputs, network.Pass{})
+}
+
+func TestParseLayerSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []network.LayerSpec
+		wantErr bool
+	}{
+		{
+			name: "should parse each layer kind",
+			spec: "conv:8x3x3, pool:2, flatten, dense:100, dropout:0.5",
+			want: []network.LayerSpec{
+				{Type: network.LayerTypeConv2D, Units: 8, Height: 3, Width: 3, Stride: 1},
+				{Type: network.LayerTypeMaxPool2D, Height: 2, Width: 2, Stride: 2},
+				{Type: network.LayerTypeFlatten},
+				{Type: network.LayerTypeDense, Units: 100},
+				{Type: network.LayerTypeDropout, Rate: 0.5},
+			},
+		},
+		{
+			name: "should parse convolution stride and padding and pooling strides",
+			spec: "conv:4x5x3:2:1,avgpool:3:1,maxpool:2:1,conv:2x3",
+			want: []network.LayerSpec{
+				{Type: network.LayerTypeConv2D, Units: 4, Height: 5, Width: 3, Stride: 2, Padding: 1},
+				{Type: network.LayerTypeAvgPool2D, Height: 3, Width: 3, Stride: 1},
+				{Type: network.LayerTypeMaxPool2D, Height: 2, Width: 2, Stride: 1},
+				{Type: network.LayerTypeConv2D, Units: 2, Height: 3, Width: 3, Stride: 1},
+			},
+		},
+		{
+			name: "should parse recurrent layers",
+			spec: "rnn:4,lstm:8,gru:2",
+			want: []network.LayerSpec{
+				{Type: network.LayerTypeRNN, Units: 4},
+				{Type: network.LayerTypeLSTM, Units: 8},
+				{Type: network.LayerTypeGRU, Units: 2},
+			},
+		},
+		{name: "should error on an empty spec", spec: " , ", wantErr: true},
+		{name: "should error on an unknown layer", spec: "dense:10,attention:4", wantErr: true},
+		{name: "should error on a convolution without a kernel", spec: "conv:8", wantErr: true},
+		{name: "should error on a dense layer without units", spec: "dense", wantErr: true},
+		{name: "should error on an invalid pooling size", spec: "pool:two", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := network.ParseLayerSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLayerSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLayerSpec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRandom_LayerSpec(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       network.Config
+		wantTypes []network.LayerType
+		wantErr   bool
+	}{
+		{
+			name: "should add an activation after each weighted layer",
+			cfg:  network.Config{InputCount: 36, InputShape: network.ImageShape{Channels: 1, Height: 6, Width: 6}, LayerSpec: "conv:2x3x3,pool:2,flatten,dense:3"},
+			wantTypes: []network.LayerType{
+				network.LayerTypeConv2D, network.LayerTypeActivation, network.LayerTypeMaxPool2D, network.LayerTypeFlatten,
+				network.LayerTypeDense, network.LayerTypeActivation,
+			},
+		},
+		{
+			name:      "should default the input shape to a column of 1x1 channels",
+			cfg:       network.Config{InputCount: 4, LayerSpec: "dense:3,dropout:0.5,dense:1"},
+			wantTypes: []network.LayerType{network.LayerTypeDense, network.LayerTypeActivation, network.LayerTypeDropout, network.LayerTypeDense, network.LayerTypeActivation},
+		},
+		{name: "should error on an input shape not matching the input count", cfg: network.Config{InputCount: 35, InputShape: network.ImageShape{Channels: 1, Height: 6, Width: 6}, LayerSpec: "dense:3"}, wantErr: true},
+		{name: "should error on a kernel larger than the image", cfg: network.Config{InputCount: 4, InputShape: network.ImageShape{Channels: 1, Height: 2, Width: 2}, LayerSpec: "conv:1x3x3,dense:1"}, wantErr: true},
+		{name: "should error on layer counts with a spec", cfg: network.Config{InputCount: 4, LayerCounts: []int{1}, LayerSpec: "dense:1"}, wantErr: true},
+		{name: "should error on an activation count not matching the weighted layers", cfg: network.Config{InputCount: 4, LayerSpec: "dense:2,dense:1", Activations: []network.ActivationType{network.ActivationTypeReLU}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := network.NewRandom(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRandom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []network.LayerType
+			for _, l := range n.Layers() {
+				got = append(got, l.Type())
+			}
+			if !reflect.DeepEqual(got, tt.wantTypes) {
+				t.Errorf("Layers() types = %v, want %v", got, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainConv(t *testing.T) {
+	// 4x4 images of a vertical or horizontal bar
+	inputs := [][]float64{
+		{0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0},
+		{0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0},
+		{0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0},
+	}
+	targets := [][]float64{{1, 0}, {1, 0}, {0, 1}, {0, 1}}
+	for _, pool := range []string{"pool:2", "avgpool:2"} {
+		t.Run(pool, func(t *testing.T) {
+			n, err := network.NewRandom(network.Config{
+				InputCount:  16,
+				InputShape:  network.ImageShape{Channels: 1, Height: 4, Width: 4},
+				LayerSpec:   "conv:4x3x3:1:1," + pool + ",flatten,dense:2",
+				Activations: []network.ActivationType{network.ActivationTypeReLU, network.ActivationTypeSoftmax},
+				Loss:        network.LossTypeCategoricalCrossEntropy,
+				Rate:        0.05,
+				Optimizer:   network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+				Init:        network.InitTypeHeUniform,
+				RandSeed:    3,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := n.TrainBatch(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last float64
+			for i := 0; i < 100; i++ {
+				if last, err = n.TrainBatch(inputs, targets); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last > first/4 {
+				t.Errorf("TrainBatch() loss after training = %v, want below a quarter of the first loss %v", last, first)
+			}
+
+			data, err := json.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := &network.Network{}
+			if err := json.Unmarshal(data, restored); err != nil {
+				t.Fatal(err)
+			}
+			for _, input := range inputs {
+				if got, want := predictVector(t, restored, input), predictVector(t, n, input); !reflect.DeepEqual(got, want) {
+					t.Errorf("restored Predict(%v) = %v, want %v", input, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/network/autodiff"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// denseLayer is a fully connected layer computing weights · inputs + biases.
+type denseLayer struct {
+	weights *mat.Dense // one row for each output and one column for each input
+	biases  *mat.Dense // single-column bias for each output
+}
+
+// NewDenseLayer creates a fully connected layer from its weights, with one row for each output and one column for each input,
+// and a single-column bias for each output. Nil biases are zero.
+func NewDenseLayer(weights, biases *mat.Dense) (Layer, error) {
+	if weights == nil {
+		return nil, fmt.Errorf("dense layer weights cannot be nil")
+	}
+	rows, _ := weights.Dims()
+	if biases == nil {
+		biases = mat.NewDense(rows, 1, nil)
+	}
+	if rc, cc := biases.Dims(); rc != rows || cc != 1 {
+		return nil, fmt.Errorf("dense layer bias dimensions %dx%d must be %dx1", rc, cc, rows)
+	}
+	return denseLayer{weights: weights, biases: biases}, nil
+}
+
+func decodeDenseLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	if err := unmarshalLayerConfig(LayerTypeDense, config, nil, params, state, 2, 0); err != nil {
+		return nil, err
+	}
+	return NewDenseLayer(params[0], params[1])
+}
+
+func (l denseLayer) Type() LayerType {
+	return LayerTypeDense
+}
+
+func (l denseLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	return forwardTape(inputs, []*mat.Dense{l.weights, l.biases}, pass, denseForward)
+}
+
+// denseForward computes weights · inputs + biases.
+func denseForward(inputs *autodiff.Node, params []*autodiff.Node, _ Pass) *autodiff.Node {
+	return params[0].Mul(inputs).AddColumn(params[1])
+}
+
+func (l denseLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	return backwardTape(grads, cache)
+}
+
+func (l denseLayer) Params() []Param {
+	return []Param{{Value: l.weights, Regularized: true}, {Value: l.biases}}
+}
+
+func (l denseLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 2 {
+		return nil, fmt.Errorf("dense layer must have 2 params, got %d", len(params))
+	}
+	return NewDenseLayer(params[0], params[1])
+}
+
+func (l denseLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	return nil, nil, nil
+}
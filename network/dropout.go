@@ -0,0 +1,160 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// validateDropout checks there is a rate in [0, 1) for each hidden layer, or none at all.
+func validateDropout(rates []float64, layerCount int) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	if len(rates) != layerCount-1 {
+		return fmt.Errorf("dropout rate count %d must equal hidden layer count %d", len(rates), layerCount-1)
+	}
+	for i, rate := range rates {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("hidden layer %d dropout rate %v must be at least 0 and less than 1", i, rate)
+		}
+	}
+	return nil
+}
+
+// dropoutLayer applies inverted dropout to its inputs in passes with a random source, and passes them through unchanged otherwise.
+// Each value is dropped with the layer's rate and kept values are scaled by 1 / (1 - rate),
+// so the expected outputs match the unmasked outputs used for prediction.
+type dropoutLayer struct {
+	rate float64
+}
+
+type dropoutLayerConfig struct {
+	Rate float64
+}
+
+// NewDropoutLayer creates a dropout layer that drops each value with a rate in [0, 1).
+func NewDropoutLayer(rate float64) (Layer, error) {
+	if rate < 0 || rate >= 1 {
+		return nil, fmt.Errorf("dropout rate %v must be at least 0 and less than 1", rate)
+	}
+	return dropoutLayer{rate: rate}, nil
+}
+
+func decodeDropoutLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg dropoutLayerConfig
+	if err := unmarshalLayerConfig(LayerTypeDropout, config, &cfg, params, state, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewDropoutLayer(cfg.Rate)
+}
+
+func (l dropoutLayer) Type() LayerType {
+	return LayerTypeDropout
+}
+
+func (l dropoutLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	if pass.Rand == nil || l.rate == 0 {
+		return inputs, (*mat.Dense)(nil), nil
+	}
+	rows, cols := inputs.Dims()
+	data := make([]float64, rows*cols)
+	for j := range data {
+		if pass.Rand.Float64() >= l.rate {
+			data[j] = 1 / (1 - l.rate)
+		}
+	}
+	mask := mat.NewDense(rows, cols, data)
+	outputs, err := dropout(inputs, mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputs, mask, nil
+}
+
+func (l dropoutLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	mask, ok := cache.(*mat.Dense)
+	if !ok {
+		return nil, nil, fmt.Errorf("dropout layer cache must be its mask")
+	}
+	inputGrads, err := dropout(grads, mask)
+	return inputGrads, nil, err
+}
+
+func (l dropoutLayer) Params() []Param {
+	return nil
+}
+
+func (l dropoutLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("dropout layer has no params, got %d", len(params))
+	}
+	return l, nil
+}
+
+func (l dropoutLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := marshalLayerConfig(dropoutLayerConfig{Rate: l.rate})
+	return config, nil, err
+}
+
+// dropout applies a dropout mask to a layer's outputs or output gradients, a nil mask leaves them unchanged.
+func dropout(m, mask *mat.Dense) (*mat.Dense, error) {
+	if mask == nil {
+		return m, nil
+	}
+	masked, err := matutil.MulElem(m, mask)
+	if err != nil {
+		return nil, fmt.Errorf("applying dropout mask: %v", err)
+	}
+	return masked, nil
+}
+
+// PredictMC estimates outputs with Monte-Carlo dropout, returning the mean and variance of each output over a number of
+// stochastic passes with dropout applied. Masks are drawn from a new source at the network's random state,
+// so repeated calls return the same estimate and training is unaffected.
+func (n Network) PredictMC(inputData []float64, passes int) (*mat.Dense, *mat.Dense, error) {
+	if passes < 1 {
+		return nil, nil, fmt.Errorf("pass count %d must be at least 1", passes)
+	}
+	repeated := make([][]float64, passes)
+	for i := range repeated {
+		repeated[i] = inputData
+	}
+	inputs, err := matutil.FromVectors(repeated)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating matrix from input data: %v", err)
+	}
+	src := Rand{n.cfg.RandSeed, n.cfg.RandState}.GetSource()
+	final, _, err := propagateForwards(inputs, n.layers, Pass{Rand: rand.New(src)})
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, _ := final.Dims()
+	mean := mat.NewDense(rows, 1, nil)
+	variance := mat.NewDense(rows, 1, nil)
+	for i := 0; i < rows; i++ {
+		row := final.RawRowView(i)
+		var sum, sumSquares float64
+		for _, v := range row {
+			sum += v
+			sumSquares += v * v
+		}
+		m := sum / float64(passes)
+		mean.Set(i, 0, m)
+		variance.Set(i, 0, math.Max(0, sumSquares/float64(passes)-m*m))
+	}
+	if n.cfg.TargetScaling.enabled() {
+		if mean, err = n.unscale(mean); err != nil {
+			return nil, nil, err
+		}
+		variance.Apply(func(i, _ int, v float64) float64 {
+			return v * n.cfg.TargetScaling.Std[i] * n.cfg.TargetScaling.Std[i]
+		}, variance)
+	}
+	return mean, variance, nil
+}
@@ -0,0 +1,132 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func newDropout(t *testing.T, dropout []float64) *network.Network {
+	t.Helper()
+	n, err := network.NewRandom(network.Config{
+		InputCount:  3,
+		LayerCounts: []int{8, 8, 2},
+		Rate:        0.1,
+		Dropout:     dropout,
+		RandSeed:    7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNewRandom_Dropout(t *testing.T) {
+	tests := []struct {
+		name    string
+		dropout []float64
+		wantErr bool
+	}{
+		{name: "should allow no dropout"},
+		{name: "should allow a rate for each hidden layer", dropout: []float64{0.5, 0}},
+		{name: "should error on a missing hidden layer rate", dropout: []float64{0.5}, wantErr: true},
+		{name: "should error on a rate for the output layer", dropout: []float64{0.5, 0.5, 0.5}, wantErr: true},
+		{name: "should error on a negative rate", dropout: []float64{-0.1, 0}, wantErr: true},
+		{name: "should error on a rate of 1", dropout: []float64{1, 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := network.NewRandom(network.Config{InputCount: 3, LayerCounts: []int{8, 8, 2}, Dropout: tt.dropout})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRandom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainDropout(t *testing.T) {
+	input, target := []float64{1, 0.5, -1}, []float64{1, 0}
+	plain := newDropout(t, nil)
+	dropped := newDropout(t, []float64{0.5, 0.5})
+	if got, want := predictVector(t, dropped, input), predictVector(t, plain, input); !reflect.DeepEqual(got, want) {
+		t.Errorf("Predict() with dropout = %v, want deterministic %v", got, want)
+	}
+
+	continuous := newDropout(t, []float64{0.5, 0.5})
+	resumed := newDropout(t, []float64{0.5, 0.5})
+	for i := 0; i < 5; i++ {
+		if err := plain.Train(input, target); err != nil {
+			t.Fatal(err)
+		}
+		if err := continuous.Train(input, target); err != nil {
+			t.Fatal(err)
+		}
+		if err := resumed.Train(input, target); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(resumed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumed = &network.Network{}
+		if err := json.Unmarshal(data, resumed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := predictVector(t, continuous, input)
+	if got := predictVector(t, resumed, input); !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed Predict() = %v, want %v", got, want)
+	}
+	if got := predictVector(t, plain, input); reflect.DeepEqual(got, want) {
+		t.Errorf("Predict() after training with dropout = %v, want different from training without dropout", got)
+	}
+	if plain.Config().RandState == continuous.Config().RandState {
+		t.Errorf("RandState = %v, want advanced by dropout masks", continuous.Config().RandState)
+	}
+}
+
+func TestNetwork_PredictMC(t *testing.T) {
+	input := []float64{1, 0.5, -1}
+
+	plain := newDropout(t, nil)
+	mean, variance, err := plain.PredictMC(input, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := predictVector(t, plain, input)
+	for i, got := range mean.RawMatrix().Data {
+		if math.Abs(got-want[i]) > 1e-12 {
+			t.Errorf("PredictMC() mean %d without dropout = %v, want %v", i, got, want[i])
+		}
+	}
+	for _, v := range variance.RawMatrix().Data {
+		if v > 1e-12 {
+			t.Errorf("PredictMC() variance without dropout = %v, want 0", v)
+		}
+	}
+
+	dropped := newDropout(t, []float64{0.5, 0.5})
+	mean, variance, err = dropped.PredictMC(input, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range variance.RawMatrix().Data {
+		if v <= 0 {
+			t.Errorf("PredictMC() variance with dropout = %v, want positive", v)
+		}
+	}
+	repeatMean, repeatVariance, err := dropped.PredictMC(input, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repeatMean, mean) || !reflect.DeepEqual(repeatVariance, variance) {
+		t.Errorf("repeated PredictMC() = %v, %v, want %v, %v", repeatMean, repeatVariance, mean, variance)
+	}
+
+	if _, _, err := dropped.PredictMC(input, 0); err == nil {
+		t.Errorf("PredictMC() expected error for zero passes")
+	}
+}
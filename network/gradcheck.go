@@ -0,0 +1,292 @@
+package network
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// gradCheckStep is the step size of the central finite differences.
+const gradCheckStep = 1e-6
+
+// GradCheck is the worst relative error of the analytic gradients of a layer compared with central finite differences.
+// Errors are relative to the larger magnitude of the two gradients, or absolute below a magnitude of 1,
+// so tiny gradients don't report large errors from rounding alone.
+type GradCheck struct {
+	Layer      int
+	Type       LayerType
+	InputError float64
+	ParamError float64
+}
+
+// MaxError returns the worst of the input and param gradient errors.
+func (c GradCheck) MaxError() float64 {
+	return math.Max(c.InputError, c.ParamError)
+}
+
+func (c GradCheck) String() string {
+	return fmt.Sprintf("layer %d %s: input error %.3g, param error %.3g", c.Layer, c.Type, c.InputError, c.ParamError)
+}
+
+// CheckGradients compares the analytic gradients of the training loss for a batch of inputs and targets with central
+// finite differences, reporting the worst relative error of the input and param gradients of each layer.
+// The loss includes any L1 and L2 weight penalties. Random layers such as dropout draw the same values for every pass,
+// and the network is left unchanged.
+func (n Network) CheckGradients(inputs, targets [][]float64) ([]GradCheck, error) {
+	if len(inputs) != len(targets) {
+		return nil, fmt.Errorf("input batch size %d must equal target batch size %d", len(inputs), len(targets))
+	}
+	inputMatrix, err := matutil.FromVectors(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("creating input matrix: %v", err)
+	}
+	targetMatrix, err := matutil.FromVectors(targets)
+	if err != nil {
+		return nil, fmt.Errorf("creating target matrix: %v", err)
+	}
+	return n.checkGradients(inputMatrix, targetMatrix, 0)
+}
+
+// CheckSequenceGradients compares the analytic gradients of the training loss for a batch of sequences of equal length
+// with central finite differences, backpropagating through every time step from a zero hidden state.
+// The targets of a sequence are either one for each step, or a single target for the last step only, as in TrainSequence.
+func (n Network) CheckSequenceGradients(inputs, targets [][][]float64) ([]GradCheck, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("sequence batch cannot be empty")
+	}
+	if len(inputs) != len(targets) {
+		return nil, fmt.Errorf("input batch size %d must equal target batch size %d", len(inputs), len(targets))
+	}
+	steps := len(inputs[0])
+	inputMatrix, err := sequenceMatrix(inputs, 0, steps)
+	if err != nil {
+		return nil, fmt.Errorf("creating input matrix: %v", err)
+	}
+	targetSteps := steps
+	if len(targets[0]) == 1 {
+		targetSteps = 1
+	}
+	targetMatrix, err := sequenceMatrix(targets, 0, targetSteps)
+	if err != nil {
+		return nil, fmt.Errorf("creating target matrix: %v", err)
+	}
+	return n.checkGradients(inputMatrix, targetMatrix, steps)
+}
+
+// CheckLayerGradients compares the analytic input and param gradients of a single layer with central finite differences,
+// for a loss weighting each output element by a distinct value. The layer runs in training mode, with random values drawn
+// from a source seeded from pass.Rand, if any, so every pass draws the same values.
+func CheckLayerGradients(l Layer, inputs *mat.Dense, pass Pass) (GradCheck, error) {
+	var seed uint64
+	if pass.Rand != nil {
+		seed = pass.Rand.Uint64()
+	}
+	c := gradChecker{seed: seed, steps: pass.Steps}
+	outputs, cache, err := c.forwardLayer(l, 0, inputs)
+	if err != nil {
+		return GradCheck{}, err
+	}
+	rows, cols := outputs.Dims()
+	weights := mat.NewDense(rows, cols, nil)
+	weights.Apply(func(i, j int, _ float64) float64 { return float64(i*cols+j+1) / float64(rows*cols) }, weights)
+	c.loss = func(_ []Layer, outputs *mat.Dense) (float64, error) {
+		var product mat.Dense
+		product.MulElem(outputs, weights)
+		return mat.Sum(&product), nil
+	}
+	inputGrads, paramGrads, err := l.Backward(weights, cache)
+	if err != nil {
+		return GradCheck{}, fmt.Errorf("layer %s: %v", l.Type(), err)
+	}
+	// param gradients are averaged over the output columns
+	return c.checkLayer([]Layer{l}, 0, inputs, inputGrads, paramGrads, 1, 1/float64(cols))
+}
+
+// gradChecker computes the loss of a stack of layers for finite differences.
+type gradChecker struct {
+	seed  uint64
+	steps int
+	loss  func(layers []Layer, outputs *mat.Dense) (float64, error)
+}
+
+// checkGradients compares the analytic gradients of the training loss with finite differences for every layer.
+func (n Network) checkGradients(inputs, targets *mat.Dense, steps int) ([]GradCheck, error) {
+	c := gradChecker{seed: n.cfg.RandSeed, steps: steps}
+	c.loss = func(layers []Layer, outputs *mat.Dense) (float64, error) {
+		scored, err := lastColumns(outputs, targets)
+		if err != nil {
+			return 0, err
+		}
+		loss, err := n.loss.Value(targets, scored)
+		if err != nil {
+			return 0, fmt.Errorf("computing loss: %v", err)
+		}
+		var params []Param
+		for _, l := range layers {
+			params = append(params, l.Params()...)
+		}
+		return loss + penalty(n.cfg.L1, n.cfg.L2, params), nil
+	}
+
+	layerInputs, caches, outputs, err := c.forward(n.layers, 0, inputs)
+	if err != nil {
+		return nil, err
+	}
+	scored, err := lastColumns(outputs, targets)
+	if err != nil {
+		return nil, err
+	}
+	outputGrads, end, err := outputGradient(n.loss, n.layers, targets, scored)
+	if err != nil {
+		return nil, fmt.Errorf("computing output gradient: %v", err)
+	}
+	grads := padColumns(outputGrads, outputs)
+	inputGrads := make([]*mat.Dense, len(n.layers))
+	paramGrads := make([][]*mat.Dense, len(n.layers))
+	for i := len(n.layers) - 1; i >= 0; i-- {
+		if i >= end {
+			// the output gradient of fused layers is for their inputs
+			inputGrads[i] = grads
+			continue
+		}
+		grads, paramGrads[i], err = n.layers[i].Backward(grads, caches[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %d %s: %v", i, n.layers[i].Type(), err)
+		}
+		if paramGrads[i], err = regularize(n.cfg.L1, n.cfg.L2, n.layers[i].Params(), paramGrads[i]); err != nil {
+			return nil, err
+		}
+		inputGrads[i] = grads
+	}
+
+	// input gradients sum the loss gradients of every output column, rather than averaging them
+	_, cols := outputs.Dims()
+	checks := make([]GradCheck, len(n.layers))
+	for i := range n.layers {
+		if checks[i], err = c.checkLayer(n.layers, i, layerInputs[i], inputGrads[i], paramGrads[i], float64(cols), 1); err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+// checkLayer compares the analytic gradients of the inputs and params of layer i in a stack with finite differences
+// of the loss, with the input and param gradients scaled relative to the loss by inputScale and paramScale.
+func (c gradChecker) checkLayer(layers []Layer, i int, inputs, inputGrads *mat.Dense, paramGrads []*mat.Dense, inputScale, paramScale float64) (GradCheck, error) {
+	l := layers[i]
+	check := GradCheck{Layer: i, Type: l.Type()}
+	if r, cols := inputs.Dims(); inputGrads == nil || !sameDims(inputGrads, r, cols) {
+		return check, fmt.Errorf("layer %d %s input gradient dimensions must equal input dimensions %dx%d", i, l.Type(), r, cols)
+	}
+	var err error
+	check.InputError, err = c.worstError(inputs, inputGrads, inputScale, func(shifted *mat.Dense) (float64, error) {
+		return c.lossFrom(layers, i, shifted)
+	})
+	if err != nil {
+		return check, err
+	}
+
+	params := paramValues(l.Params())
+	if len(paramGrads) != len(params) {
+		return check, fmt.Errorf("layer %d %s gradient count %d must equal param count %d", i, l.Type(), len(paramGrads), len(params))
+	}
+	for p, value := range params {
+		if r, cols := value.Dims(); paramGrads[p] == nil || !sameDims(paramGrads[p], r, cols) {
+			return check, fmt.Errorf("layer %d %s param %d gradient dimensions must equal param dimensions %dx%d", i, l.Type(), p, r, cols)
+		}
+		worst, err := c.worstError(value, paramGrads[p], paramScale, func(shifted *mat.Dense) (float64, error) {
+			values := append([]*mat.Dense(nil), params...)
+			values[p] = shifted
+			updated, err := l.WithParams(values)
+			if err != nil {
+				return 0, fmt.Errorf("layer %d %s: %v", i, l.Type(), err)
+			}
+			shiftedLayers := append([]Layer(nil), layers...)
+			shiftedLayers[i] = updated
+			return c.lossFrom(shiftedLayers, i, inputs)
+		})
+		if err != nil {
+			return check, err
+		}
+		check.ParamError = math.Max(check.ParamError, worst)
+	}
+	return check, nil
+}
+
+// worstError compares each analytic gradient of a value with the central finite difference of a loss function of the value
+// scaled by scale, returning the worst relative error.
+func (c gradChecker) worstError(value, grads *mat.Dense, scale float64, loss func(shifted *mat.Dense) (float64, error)) (float64, error) {
+	worst := 0.0
+	r, cols := value.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < cols; j++ {
+			shifted := mat.DenseCopyOf(value)
+			shifted.Set(i, j, value.At(i, j)+gradCheckStep)
+			plus, err := loss(shifted)
+			if err != nil {
+				return 0, err
+			}
+			shifted.Set(i, j, value.At(i, j)-gradCheckStep)
+			minus, err := loss(shifted)
+			if err != nil {
+				return 0, err
+			}
+			want := (plus - minus) / (2 * gradCheckStep) * scale
+			got := grads.At(i, j)
+			worst = math.Max(worst, math.Abs(got-want)/math.Max(1, math.Max(math.Abs(got), math.Abs(want))))
+		}
+	}
+	return worst, nil
+}
+
+// lossFrom computes the loss of the inputs of layer start propagated through the rest of the stack.
+func (c gradChecker) lossFrom(layers []Layer, start int, inputs *mat.Dense) (float64, error) {
+	_, _, outputs, err := c.forward(layers, start, inputs)
+	if err != nil {
+		return 0, err
+	}
+	return c.loss(layers, outputs)
+}
+
+// forward propagates the inputs of layer start through the rest of the stack in training mode,
+// returning the inputs and cache of each of those layers and the final outputs.
+func (c gradChecker) forward(layers []Layer, start int, inputs *mat.Dense) ([]*mat.Dense, []interface{}, *mat.Dense, error) {
+	layerInputs := make([]*mat.Dense, len(layers))
+	caches := make([]interface{}, len(layers))
+	for i := start; i < len(layers); i++ {
+		outputs, cache, err := c.forwardLayer(layers[i], i, inputs)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		layerInputs[i], caches[i] = inputs, cache
+		inputs = outputs
+	}
+	return layerInputs, caches, inputs, nil
+}
+
+// forwardLayer propagates inputs through layer i in training mode, seeding its random values by its index
+// so every pass draws the same values.
+func (c gradChecker) forwardLayer(l Layer, i int, inputs *mat.Dense) (*mat.Dense, interface{}, error) {
+	pass := Pass{Training: true, Rand: rand.New(rand.NewSource(c.seed + uint64(i))), Steps: c.steps}
+	var outputs *mat.Dense
+	var cache interface{}
+	var err error
+	if r, ok := l.(RecurrentLayer); ok {
+		outputs, cache, _, err = r.ForwardSequence(inputs, pass, nil)
+	} else {
+		outputs, cache, err = l.Forward(inputs, pass)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("layer %d %s: %v", i, l.Type(), err)
+	}
+	return outputs, cache, nil
+}
+
+func sameDims(m mat.Matrix, r, c int) bool {
+	mr, mc := m.Dims()
+	return mr == r && mc == c
+}
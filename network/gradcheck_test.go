@@ -0,0 +1,174 @@
+package network_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// gradTolerance is the worst relative gradient error accepted from central finite differences.
+const gradTolerance = 1e-6
+
+// sinRecords returns count records of size values from sinMatrix.
+func sinRecords(count, size int, seed float64) [][]float64 {
+	m := sinMatrix(count, size, seed)
+	records := make([][]float64, count)
+	for i := range records {
+		records[i] = m.RawRowView(i)
+	}
+	return records
+}
+
+func TestNetwork_CheckGradients(t *testing.T) {
+	oneHot := [][]float64{{1, 0}, {0, 1}, {1, 0}}
+	tests := []struct {
+		name    string
+		cfg     network.Config
+		targets [][]float64
+	}{
+		{name: "sigmoid mse", cfg: network.Config{LayerCounts: []int{4, 2}, Activation: network.ActivationTypeSigmoid}},
+		{name: "softmax categorical cross-entropy", cfg: network.Config{
+			LayerCounts: []int{4, 2},
+			Activations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSoftmax},
+			Loss:        network.LossTypeCategoricalCrossEntropy,
+		}, targets: oneHot},
+		{name: "sigmoid binary cross-entropy", cfg: network.Config{
+			LayerCounts: []int{4, 2},
+			Activations: []network.ActivationType{network.ActivationTypeELU, network.ActivationTypeSigmoid},
+			Loss:        network.LossTypeBinaryCrossEntropy,
+		}, targets: oneHot},
+		{name: "huber", cfg: network.Config{
+			LayerCounts: []int{4, 2},
+			Activations: []network.ActivationType{network.ActivationTypeSwish, network.ActivationTypeLinear},
+			Loss:        network.LossTypeHuber,
+			HuberDelta:  0.3,
+		}},
+		{name: "registered loss and tape activation", cfg: network.Config{
+			LayerCounts: []int{4, 2},
+			Activations: []network.ActivationType{"test-softsign", network.ActivationTypeLinear},
+			LossName:    "test-half-squared-error",
+		}},
+		{name: "l1 and l2 penalties", cfg: network.Config{
+			LayerCounts: []int{4, 2},
+			Activation:  network.ActivationTypeTanh,
+			L1:          0.01,
+			L2:          0.1,
+		}},
+		{name: "dropout and batch normalization", cfg: network.Config{
+			LayerCounts:   []int{5, 4, 2},
+			Activations:   []network.ActivationType{network.ActivationTypeGELU, network.ActivationTypeSoftplus, network.ActivationTypeLinear},
+			Dropout:       []float64{0.5, 0.3},
+			Normalization: []network.NormType{network.NormTypeBatch, network.NormTypeLayer},
+		}},
+		{name: "convolution and pooling", cfg: network.Config{
+			InputShape:  network.ImageShape{Channels: 1, Height: 4, Width: 4},
+			LayerSpec:   "conv:2x3x3:1:1,maxpool:2,conv:2x2x2,avgpool:1,flatten,dense:2",
+			Activations: []network.ActivationType{network.ActivationTypeLeakyReLU, network.ActivationTypeSELU, network.ActivationTypeSigmoid},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.InputCount, cfg.Rate, cfg.RandSeed = 3, 0.1, 7
+			if cfg.InputShape.Size() > 0 {
+				cfg.InputCount = cfg.InputShape.Size()
+			}
+			n, err := network.NewRandom(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			targets := tt.targets
+			if targets == nil {
+				targets = sinRecords(3, 2, 2)
+			}
+			checks, err := n.CheckGradients(sinRecords(3, cfg.InputCount, 1), targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(checks) != len(n.Layers()) {
+				t.Fatalf("CheckGradients() returned %d checks, want one for each of %d layers", len(checks), len(n.Layers()))
+			}
+			for _, check := range checks {
+				if check.MaxError() > gradTolerance {
+					t.Errorf("CheckGradients() %v, want errors below %v", check, gradTolerance)
+				}
+			}
+		})
+	}
+}
+
+func TestNetwork_CheckSequenceGradients(t *testing.T) {
+	inputs, targets := echoSequences(2, 4)
+	lastStep := make([][][]float64, len(targets))
+	for i, sequence := range targets {
+		lastStep[i] = sequence[len(sequence)-1:]
+	}
+	for _, spec := range []string{"rnn:3", "lstm:3", "gru:3"} {
+		for name, targets := range map[string][][][]float64{"every step": targets, "last step": lastStep} {
+			t.Run(spec+" "+name, func(t *testing.T) {
+				checks, err := newRecurrent(t, spec, 2).CheckSequenceGradients(inputs, targets)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, check := range checks {
+					if check.MaxError() > gradTolerance {
+						t.Errorf("CheckSequenceGradients() %v, want errors below %v", check, gradTolerance)
+					}
+				}
+			})
+		}
+	}
+}
+
+// doublingLayer doubles its inputs, with a Backward that forgets to double the gradients.
+type doublingLayer struct{}
+
+func (doublingLayer) Type() network.LayerType { return "test-doubling" }
+
+func (doublingLayer) Forward(inputs *mat.Dense, _ network.Pass) (*mat.Dense, interface{}, error) {
+	var outputs mat.Dense
+	outputs.Scale(2, inputs)
+	return &outputs, nil, nil
+}
+
+func (doublingLayer) Backward(grads *mat.Dense, _ interface{}) (*mat.Dense, []*mat.Dense, error) {
+	return grads, nil, nil
+}
+
+func (doublingLayer) Params() []network.Param { return nil }
+
+func (l doublingLayer) WithParams([]*mat.Dense) (network.Layer, error) { return l, nil }
+
+func (doublingLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) { return nil, nil, nil }
+
+func TestCheckLayerGradients(t *testing.T) {
+	check, err := network.CheckLayerGradients(doublingLayer{}, sinMatrix(2, 3, 1), network.Pass{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.InputError < 0.1 {
+		t.Errorf("CheckLayerGradients() input error of an incorrect Backward = %v, want at least 0.1", check.InputError)
+	}
+
+	must := newLayer(t)
+	n, err := network.NewFromLayers(network.Config{InputCount: 2, Rate: 0.1}, []network.Layer{
+		must(network.NewDenseLayer(sinMatrix(2, 2, 2), nil)),
+		doublingLayer{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := n.CheckGradients(sinRecords(3, 2, 3), sinRecords(3, 2, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks[0].ParamError < 0.1 || checks[1].InputError < 0.1 {
+		t.Errorf("CheckGradients() = %v, want errors of at least 0.1 from the incorrect Backward", checks)
+	}
+	if _, err := n.CheckGradients(sinRecords(3, 2, 3), sinRecords(2, 2, 4)); err == nil {
+		t.Errorf("CheckGradients() with mismatched batch sizes error = nil, want error")
+	}
+}
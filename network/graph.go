@@ -0,0 +1,481 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const LayerTypeGraph LayerType = "graph"
+
+func init() {
+	// registered here rather than in the registry literal, since decoding a graph decodes its nodes from the registry
+	layerRegistry.decoders[LayerTypeGraph] = decodeGraphLayer
+}
+
+// MergeType selects how a graph node combines the outputs of several nodes.
+type MergeType string
+
+const (
+	MergeTypeNone   MergeType = ""
+	MergeTypeAdd    MergeType = "add"    // element-wise sum of outputs with equal dimensions
+	MergeTypeConcat MergeType = "concat" // outputs stacked row-wise in input order
+)
+
+// GraphInput is a named input of a graph layer, taking the next Size rows of the layer inputs in declaration order.
+// A network with several inputs, like image pixels and tabular metadata, is predicted and trained
+// from the concatenation of every input's values.
+type GraphInput struct {
+	Name string
+	Size int
+}
+
+// GraphNode is a named node of a graph layer, applying either a layer to the output of a single input,
+// or a merge to the outputs of several. Inputs name graph inputs or other nodes.
+type GraphNode struct {
+	Name   string
+	Layer  Layer
+	Merge  MergeType
+	Inputs []string
+}
+
+// graphLayer is a directed acyclic graph of layers and merges, computing the output of one of its nodes.
+type graphLayer struct {
+	inputs  []GraphInput
+	nodes   []GraphNode // in topological order
+	sources [][]int     // indexes into the graph inputs followed by the nodes, for the inputs of each node
+	output  int         // index of the output node
+}
+
+// graphCache holds the output of every graph input and node, and the cache of each layer node, from a forward pass.
+type graphCache struct {
+	values []*mat.Dense
+	caches []interface{}
+}
+
+type graphLayerConfig struct {
+	Inputs []GraphInput
+	Nodes  []graphNodeConfig
+	Output string
+}
+
+type graphNodeConfig struct {
+	Name       string
+	Type       LayerType       `json:",omitempty"`
+	Config     json.RawMessage `json:",omitempty"`
+	ParamCount int             `json:",omitempty"`
+	StateCount int             `json:",omitempty"`
+	Merge      MergeType       `json:",omitempty"`
+	Inputs     []string
+}
+
+// NewGraphLayer creates a layer from a directed acyclic graph of nodes, in any order, outputting the named node.
+// Every input and node must contribute to the output. Recurrent layers in a graph start each pass from a zero hidden state.
+func NewGraphLayer(inputs []GraphInput, nodes []GraphNode, output string) (Layer, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("graph must have at least one input")
+	}
+	index := make(map[string]int, len(inputs)+len(nodes))
+	for i, in := range inputs {
+		if in.Name == "" {
+			return nil, fmt.Errorf("graph input %d name cannot be empty", i)
+		}
+		if in.Size < 1 {
+			return nil, fmt.Errorf("graph input '%s' size %d must be at least 1", in.Name, in.Size)
+		}
+		if _, ok := index[in.Name]; ok {
+			return nil, fmt.Errorf("graph name '%s' is used more than once", in.Name)
+		}
+		index[in.Name] = i
+	}
+	nodeIndex := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("graph node %d name cannot be empty", i)
+		}
+		if _, ok := index[node.Name]; ok {
+			return nil, fmt.Errorf("graph name '%s' is used more than once", node.Name)
+		}
+		index[node.Name] = len(inputs) + i
+		nodeIndex[node.Name] = i
+	}
+	for _, node := range nodes {
+		if err := validateGraphNode(node); err != nil {
+			return nil, err
+		}
+		for _, name := range node.Inputs {
+			if _, ok := index[name]; !ok {
+				return nil, fmt.Errorf("graph node '%s' input '%s' is not a graph input or node", node.Name, name)
+			}
+		}
+	}
+	if i, ok := index[output]; !ok || i < len(inputs) {
+		return nil, fmt.Errorf("graph output '%s' must be a graph node", output)
+	}
+
+	order, err := graphOrder(nodes, nodeIndex)
+	if err != nil {
+		return nil, err
+	}
+	l := graphLayer{inputs: inputs, nodes: make([]GraphNode, len(nodes)), sources: make([][]int, len(nodes))}
+	position := make(map[string]int, len(inputs)+len(nodes))
+	for i, in := range inputs {
+		position[in.Name] = i
+	}
+	for i, n := range order {
+		l.nodes[i] = nodes[n]
+		position[nodes[n].Name] = len(inputs) + i
+	}
+	for i, node := range l.nodes {
+		for _, name := range node.Inputs {
+			l.sources[i] = append(l.sources[i], position[name])
+		}
+	}
+	l.output = position[output]
+	if err := l.validateUsed(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// NewResidualBlock creates a graph layer adding its inputs to the outputs of a stack of layers, which must keep the input size.
+func NewResidualBlock(size int, layers []Layer) (Layer, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("residual block must have at least one layer")
+	}
+	nodes := make([]GraphNode, 0, len(layers)+1)
+	previous := "input"
+	for i, l := range layers {
+		name := "layer" + strconv.Itoa(i)
+		nodes = append(nodes, GraphNode{Name: name, Layer: l, Inputs: []string{previous}})
+		previous = name
+	}
+	nodes = append(nodes, GraphNode{Name: "residual", Merge: MergeTypeAdd, Inputs: []string{"input", previous}})
+	return NewGraphLayer([]GraphInput{{Name: "input", Size: size}}, nodes, "residual")
+}
+
+func decodeGraphLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg graphLayerConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshaling %s layer config: %v", LayerTypeGraph, err)
+	}
+	nodes := make([]GraphNode, len(cfg.Nodes))
+	for i, node := range cfg.Nodes {
+		nodes[i] = GraphNode{Name: node.Name, Merge: node.Merge, Inputs: node.Inputs}
+		if node.Type == "" {
+			continue
+		}
+		if node.ParamCount < 0 || node.ParamCount > len(params) || node.StateCount < 0 || node.StateCount > len(state) {
+			return nil, fmt.Errorf("graph node '%s' needs %d params and %d state matrices, only %d and %d left", node.Name, node.ParamCount, node.StateCount, len(params), len(state))
+		}
+		l, err := decodeLayer(node.Type, node.Config, params[:node.ParamCount], state[:node.StateCount])
+		if err != nil {
+			return nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+		}
+		nodes[i].Layer = l
+		params, state = params[node.ParamCount:], state[node.StateCount:]
+	}
+	if len(params) != 0 || len(state) != 0 {
+		return nil, fmt.Errorf("%s layer has %d params and %d state matrices more than its nodes", LayerTypeGraph, len(params), len(state))
+	}
+	return NewGraphLayer(cfg.Inputs, nodes, cfg.Output)
+}
+
+// validateGraphNode checks a node has either a layer with a single input, or a merge of at least two.
+func validateGraphNode(node GraphNode) error {
+	switch {
+	case node.Layer != nil && node.Merge != MergeTypeNone:
+		return fmt.Errorf("graph node '%s' cannot have both a layer and a merge", node.Name)
+	case node.Layer != nil:
+		if len(node.Inputs) != 1 {
+			return fmt.Errorf("graph node '%s' layer must have 1 input, got %d", node.Name, len(node.Inputs))
+		}
+	case node.Merge == MergeTypeAdd || node.Merge == MergeTypeConcat:
+		if len(node.Inputs) < 2 {
+			return fmt.Errorf("graph node '%s' %s merge must have at least 2 inputs, got %d", node.Name, node.Merge, len(node.Inputs))
+		}
+	case node.Merge == MergeTypeNone:
+		return fmt.Errorf("graph node '%s' must have a layer or a merge", node.Name)
+	default:
+		return fmt.Errorf("graph node '%s' has unknown merge type '%s'", node.Name, node.Merge)
+	}
+	return nil
+}
+
+// graphOrder sorts the nodes so each comes after the nodes it takes inputs from, keeping the declaration order otherwise.
+func graphOrder(nodes []GraphNode, nodeIndex map[string]int) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(nodes))
+	order := make([]int, 0, len(nodes))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visiting:
+			return fmt.Errorf("graph has a cycle through node '%s'", nodes[i].Name)
+		case visited:
+			return nil
+		}
+		marks[i] = visiting
+		for _, name := range nodes[i].Inputs {
+			n, ok := nodeIndex[name]
+			if !ok {
+				continue
+			}
+			if err := visit(n); err != nil {
+				return err
+			}
+		}
+		marks[i] = visited
+		order = append(order, i)
+		return nil
+	}
+	for i := range nodes {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// validateUsed checks every graph input and node contributes to the output.
+func (l graphLayer) validateUsed() error {
+	used := make([]bool, len(l.inputs)+len(l.nodes))
+	used[l.output] = true
+	for i := len(l.nodes) - 1; i >= 0; i-- {
+		if !used[len(l.inputs)+i] {
+			continue
+		}
+		for _, s := range l.sources[i] {
+			used[s] = true
+		}
+	}
+	for i, u := range used {
+		if u {
+			continue
+		}
+		if i < len(l.inputs) {
+			return fmt.Errorf("graph input '%s' is not used by the output", l.inputs[i].Name)
+		}
+		return fmt.Errorf("graph node '%s' is not used by the output", l.nodes[i-len(l.inputs)].Name)
+	}
+	return nil
+}
+
+func (l graphLayer) Type() LayerType {
+	return LayerTypeGraph
+}
+
+func (l graphLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	rows, cols := inputs.Dims()
+	size := 0
+	for _, in := range l.inputs {
+		size += in.Size
+	}
+	if rows != size {
+		return nil, nil, fmt.Errorf("graph layer input rows %d must equal graph input size %d", rows, size)
+	}
+	c := graphCache{values: make([]*mat.Dense, len(l.inputs)+len(l.nodes)), caches: make([]interface{}, len(l.nodes))}
+	offset := 0
+	for i, in := range l.inputs {
+		c.values[i] = mat.DenseCopyOf(inputs.Slice(offset, offset+in.Size, 0, cols))
+		offset += in.Size
+	}
+	for i, node := range l.nodes {
+		values := make([]*mat.Dense, len(l.sources[i]))
+		for j, s := range l.sources[i] {
+			values[j] = c.values[s]
+		}
+		var outputs *mat.Dense
+		var err error
+		if node.Layer != nil {
+			outputs, c.caches[i], err = node.Layer.Forward(values[0], pass)
+		} else {
+			outputs, err = merge(node.Merge, values)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+		}
+		c.values[len(l.inputs)+i] = outputs
+	}
+	return c.values[l.output], c, nil
+}
+
+func (l graphLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	c, ok := cache.(graphCache)
+	if !ok {
+		return nil, nil, fmt.Errorf("graph layer cache must be from a forward pass")
+	}
+	// gradients of outputs used by several nodes are summed
+	sums := make([]*mat.Dense, len(c.values))
+	sums[l.output] = grads
+	nodeGrads := make([][]*mat.Dense, len(l.nodes))
+	for i := len(l.nodes) - 1; i >= 0; i-- {
+		node := l.nodes[i]
+		g := sums[len(l.inputs)+i]
+		var inputGrads []*mat.Dense
+		if node.Layer != nil {
+			inputGrad, paramGrads, err := node.Layer.Backward(g, c.caches[i])
+			if err != nil {
+				return nil, nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+			}
+			if len(paramGrads) != len(node.Layer.Params()) {
+				return nil, nil, fmt.Errorf("graph node '%s' gradient count %d must equal param count %d", node.Name, len(paramGrads), len(node.Layer.Params()))
+			}
+			inputGrads, nodeGrads[i] = []*mat.Dense{inputGrad}, paramGrads
+		} else {
+			inputGrads = l.mergeGradients(node.Merge, i, g, c.values)
+		}
+		for j, s := range l.sources[i] {
+			if sums[s] == nil {
+				sums[s] = inputGrads[j]
+				continue
+			}
+			var sum mat.Dense
+			sum.Add(sums[s], inputGrads[j])
+			sums[s] = &sum
+		}
+	}
+	inputGrads := sums[0]
+	if len(l.inputs) > 1 {
+		stacked, err := merge(MergeTypeConcat, sums[:len(l.inputs)])
+		if err != nil {
+			return nil, nil, err
+		}
+		inputGrads = stacked
+	}
+	var paramGrads []*mat.Dense
+	for _, g := range nodeGrads {
+		paramGrads = append(paramGrads, g...)
+	}
+	return inputGrads, paramGrads, nil
+}
+
+// mergeGradients returns the loss gradient of each input of merge node i from the gradient of its output.
+func (l graphLayer) mergeGradients(t MergeType, i int, grads *mat.Dense, values []*mat.Dense) []*mat.Dense {
+	inputGrads := make([]*mat.Dense, len(l.sources[i]))
+	_, cols := grads.Dims()
+	offset := 0
+	for j, s := range l.sources[i] {
+		if t == MergeTypeAdd {
+			inputGrads[j] = grads
+			continue
+		}
+		rows, _ := values[s].Dims()
+		inputGrads[j] = mat.DenseCopyOf(grads.Slice(offset, offset+rows, 0, cols))
+		offset += rows
+	}
+	return inputGrads
+}
+
+func (l graphLayer) Params() []Param {
+	var params []Param
+	for _, node := range l.nodes {
+		if node.Layer != nil {
+			params = append(params, node.Layer.Params()...)
+		}
+	}
+	return params
+}
+
+func (l graphLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if count := len(l.Params()); len(params) != count {
+		return nil, fmt.Errorf("%s layer must have %d params, got %d", LayerTypeGraph, count, len(params))
+	}
+	updated := l
+	updated.nodes = append([]GraphNode(nil), l.nodes...)
+	for i, node := range updated.nodes {
+		if node.Layer == nil {
+			continue
+		}
+		count := len(node.Layer.Params())
+		layer, err := node.Layer.WithParams(params[:count])
+		if err != nil {
+			return nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+		}
+		updated.nodes[i].Layer = layer
+		params = params[count:]
+	}
+	return updated, nil
+}
+
+// Update updates the state of each stateful layer in the graph from the cache of a training forward pass.
+func (l graphLayer) Update(cache interface{}) (Layer, error) {
+	c, ok := cache.(graphCache)
+	if !ok {
+		return nil, fmt.Errorf("graph layer cache must be from a forward pass")
+	}
+	updated := l
+	updated.nodes = append([]GraphNode(nil), l.nodes...)
+	for i, node := range updated.nodes {
+		stateful, ok := node.Layer.(StatefulLayer)
+		if !ok {
+			continue
+		}
+		layer, err := stateful.Update(c.caches[i])
+		if err != nil {
+			return nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+		}
+		updated.nodes[i].Layer = layer
+	}
+	return updated, nil
+}
+
+func (l graphLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	cfg := graphLayerConfig{Inputs: l.inputs, Nodes: make([]graphNodeConfig, len(l.nodes))}
+	var state []*mat.Dense
+	for i, node := range l.nodes {
+		cfg.Nodes[i] = graphNodeConfig{Name: node.Name, Merge: node.Merge, Inputs: node.Inputs}
+		if node.Layer == nil {
+			continue
+		}
+		config, nodeState, err := node.Layer.MarshalLayer()
+		if err != nil {
+			return nil, nil, fmt.Errorf("graph node '%s': %v", node.Name, err)
+		}
+		cfg.Nodes[i].Type = node.Layer.Type()
+		cfg.Nodes[i].Config = config
+		cfg.Nodes[i].ParamCount = len(node.Layer.Params())
+		cfg.Nodes[i].StateCount = len(nodeState)
+		state = append(state, nodeState...)
+	}
+	cfg.Output = l.nodes[l.output-len(l.inputs)].Name
+	config, err := marshalLayerConfig(cfg)
+	return config, state, err
+}
+
+// merge combines the outputs of several graph nodes.
+func merge(t MergeType, values []*mat.Dense) (*mat.Dense, error) {
+	rows, cols := values[0].Dims()
+	if t == MergeTypeAdd {
+		sum := mat.DenseCopyOf(values[0])
+		for _, v := range values[1:] {
+			if r, c := v.Dims(); r != rows || c != cols {
+				return nil, fmt.Errorf("add merge input dimensions %dx%d must equal %dx%d", r, c, rows, cols)
+			}
+			sum.Add(sum, v)
+		}
+		return sum, nil
+	}
+	total := 0
+	for _, v := range values {
+		r, c := v.Dims()
+		if c != cols {
+			return nil, fmt.Errorf("concat merge input columns %d must equal %d", c, cols)
+		}
+		total += r
+	}
+	stacked := mat.NewDense(total, cols, nil)
+	offset := 0
+	for _, v := range values {
+		r, _ := v.Dims()
+		stacked.Slice(offset, offset+r, 0, cols).(*mat.Dense).Copy(v)
+		offset += r
+	}
+	return stacked, nil
+}
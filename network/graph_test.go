@@ -0,0 +1,173 @@
+package network_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewGraphLayer_Error(t *testing.T) {
+	must := newLayer(t)
+	dense := must(network.NewDenseLayer(sinMatrix(2, 2, 1), nil))
+	inputs := []network.GraphInput{{Name: "x", Size: 2}}
+	tests := []struct {
+		name   string
+		inputs []network.GraphInput
+		nodes  []network.GraphNode
+		output string
+	}{
+		{name: "no inputs", nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"x"}}}, output: "a"},
+		{name: "empty input size", inputs: []network.GraphInput{{Name: "x"}}, nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"x"}}}, output: "a"},
+		{name: "duplicate name", inputs: inputs, nodes: []network.GraphNode{{Name: "x", Layer: dense, Inputs: []string{"x"}}}, output: "x"},
+		{name: "unknown input", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"y"}}}, output: "a"},
+		{name: "layer with two inputs", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"x", "x"}}}, output: "a"},
+		{name: "merge with one input", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Merge: network.MergeTypeAdd, Inputs: []string{"x"}}}, output: "a"},
+		{name: "unknown merge", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Merge: "multiply", Inputs: []string{"x", "x"}}}, output: "a"},
+		{name: "layer and merge", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Layer: dense, Merge: network.MergeTypeAdd, Inputs: []string{"x", "x"}}}, output: "a"},
+		{name: "cycle", inputs: inputs, nodes: []network.GraphNode{
+			{Name: "a", Layer: dense, Inputs: []string{"c"}},
+			{Name: "b", Layer: dense, Inputs: []string{"a"}},
+			{Name: "c", Merge: network.MergeTypeAdd, Inputs: []string{"x", "b"}},
+		}, output: "c"},
+		{name: "unknown output", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"x"}}}, output: "b"},
+		{name: "input output", inputs: inputs, nodes: []network.GraphNode{{Name: "a", Layer: dense, Inputs: []string{"x"}}}, output: "x"},
+		{name: "unused node", inputs: inputs, nodes: []network.GraphNode{
+			{Name: "a", Layer: dense, Inputs: []string{"x"}},
+			{Name: "b", Layer: dense, Inputs: []string{"x"}},
+		}, output: "a"},
+		{name: "unused input", inputs: []network.GraphInput{{Name: "x", Size: 2}, {Name: "y", Size: 1}}, nodes: []network.GraphNode{
+			{Name: "a", Layer: dense, Inputs: []string{"x"}},
+		}, output: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := network.NewGraphLayer(tt.inputs, tt.nodes, tt.output); err == nil {
+				t.Errorf("NewGraphLayer() error = nil, want error")
+			}
+		})
+	}
+}
+
+// newMultiInputGraph creates a graph from a 1x3x3 image and 2 metadata values, concatenating convolved image features
+// with a batch normalized residual block over the metadata, with nodes declared out of order.
+func newMultiInputGraph(t *testing.T) network.Layer {
+	t.Helper()
+	must := newLayer(t)
+	image := network.ImageShape{Channels: 1, Height: 3, Width: 3}
+	residual := must(network.NewResidualBlock(2, []network.Layer{
+		must(network.NewDenseLayer(sinMatrix(2, 2, 3), sinMatrix(2, 1, 4))),
+		must(network.NewNormLayer(network.NormTypeBatch, 2, 0, 0.9)),
+		must(network.NewActivationLayer(network.ActivationTypeTanh)),
+	}))
+	graph, err := network.NewGraphLayer([]network.GraphInput{{Name: "pixels", Size: image.Size()}, {Name: "metadata", Size: 2}}, []network.GraphNode{
+		{Name: "features", Merge: network.MergeTypeConcat, Inputs: []string{"flat", "residual", "metadata"}},
+		{Name: "conv", Layer: must(network.NewConv2DLayer(image, 2, 2, 1, 0, sinMatrix(1, 4, 1), sinMatrix(1, 1, 2))), Inputs: []string{"pixels"}},
+		{Name: "sigmoid", Layer: must(network.NewActivationLayer(network.ActivationTypeSigmoid)), Inputs: []string{"conv"}},
+		{Name: "flat", Layer: network.NewFlattenLayer(), Inputs: []string{"sigmoid"}},
+		{Name: "residual", Layer: residual, Inputs: []string{"metadata"}},
+	}, "features")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func TestGraphLayer_Backward(t *testing.T) {
+	graph := newMultiInputGraph(t)
+	outputs, _, err := graph.Forward(sinMatrix(11, 3, 5), network.Pass{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, c := outputs.Dims(); r != 8 || c != 3 {
+		t.Errorf("Forward() dimensions = %dx%d, want 8x3", r, c)
+	}
+	if _, _, err := graph.Forward(sinMatrix(10, 3, 5), network.Pass{}); err == nil {
+		t.Errorf("Forward() with too few input rows error = nil, want error")
+	}
+	checkLayerGradients(t, graph, sinMatrix(11, 3, 5), network.Pass{})
+}
+
+func TestNetwork_TrainGraph(t *testing.T) {
+	must := newLayer(t)
+	cfg := network.Config{InputCount: 11, Rate: 0.1, RandSeed: 3}
+	n, err := network.NewFromLayers(cfg, []network.Layer{
+		newMultiInputGraph(t),
+		must(network.NewNormLayer(network.NormTypeBatch, 8, 0, 0.9)),
+		must(network.NewDenseLayer(sinMatrix(1, 8, 6), nil)),
+		must(network.NewActivationLayer(network.ActivationTypeSigmoid)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := network.NewFromLayers(network.Config{InputCount: 10}, []network.Layer{newMultiInputGraph(t)}); err == nil {
+		t.Errorf("NewFromLayers() with mismatched graph input count error = nil, want error")
+	}
+	inputs, targets := sinRecords(4, 11, 7), [][]float64{{1}, {0}, {0}, {1}}
+	checks, err := n.CheckGradients(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range checks {
+		if check.MaxError() > gradTolerance {
+			t.Errorf("CheckGradients() %v, want errors below %v", check, gradTolerance)
+		}
+	}
+	first, err := n.TrainBatch(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last float64
+	for i := 0; i < 50; i++ {
+		if last, err = n.TrainBatch(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last >= first {
+		t.Errorf("TrainBatch() loss after training = %v, want less than %v", last, first)
+	}
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := predictVector(t, restored, inputs[0]), predictVector(t, n, inputs[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored Predict() = %v, want %v", got, want)
+	}
+	_, wantState, err := n.Layers()[0].MarshalLayer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gotState, err := restored.Layers()[0].MarshalLayer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wantState) != 2 || !reflect.DeepEqual(gotState, wantState) {
+		t.Errorf("restored graph state = %v, want running statistics %v", gotState, wantState)
+	}
+}
+
+func TestNewResidualBlock(t *testing.T) {
+	must := newLayer(t)
+	block, err := network.NewResidualBlock(2, []network.Layer{must(network.NewDenseLayer(mat.NewDense(2, 2, []float64{1, 2, 3, 4}), nil))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, _, err := block.Forward(mat.NewDense(2, 1, []float64{1, 1}), network.Pass{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mat.NewDense(2, 1, []float64{4, 8}); !mat.Equal(outputs, want) {
+		t.Errorf("Forward() = %v, want %v", mat.Formatted(outputs), mat.Formatted(want))
+	}
+	if _, err := network.NewResidualBlock(2, nil); err == nil {
+		t.Errorf("NewResidualBlock() without layers error = nil, want error")
+	}
+}
@@ -0,0 +1,267 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const LayerTypeHeads LayerType = "heads"
+
+// HeadConfig is a named output head of a multi-task network, a group of consecutive output rows
+// with its own activation and loss. The loss of the network is the weighted sum of the loss of each head.
+type HeadConfig struct {
+	Name       string
+	Size       int
+	Activation ActivationType
+	Loss       LossType
+	LossName   string  // registered custom loss, used instead of Loss when set
+	HuberDelta float64 // defaults to 1 when zero
+	Weight     float64 // loss weight, defaults to 1 when zero
+}
+
+func (h HeadConfig) weight() float64 {
+	if h.Weight == 0 {
+		return 1
+	}
+	return h.Weight
+}
+
+// validateHeads checks each head has a unique name, a size and a valid loss weight.
+func validateHeads(heads []HeadConfig) error {
+	names := make(map[string]bool, len(heads))
+	for i, h := range heads {
+		if h.Name == "" {
+			return fmt.Errorf("head %d name cannot be empty", i)
+		}
+		if names[h.Name] {
+			return fmt.Errorf("head name '%s' is used more than once", h.Name)
+		}
+		names[h.Name] = true
+		if h.Size < 1 {
+			return fmt.Errorf("head '%s' size %d must be at least 1", h.Name, h.Size)
+		}
+		if h.Weight < 0 {
+			return fmt.Errorf("head '%s' loss weight %v cannot be negative", h.Name, h.Weight)
+		}
+	}
+	return nil
+}
+
+// splitHeads returns the rows of a matrix belonging to each head.
+func splitHeads(heads []HeadConfig, m *mat.Dense) ([]*mat.Dense, error) {
+	rows, cols := m.Dims()
+	size := 0
+	for _, h := range heads {
+		size += h.Size
+	}
+	if rows != size {
+		return nil, fmt.Errorf("row count %d must equal total head size %d", rows, size)
+	}
+	parts := make([]*mat.Dense, len(heads))
+	offset := 0
+	for i, h := range heads {
+		parts[i] = mat.DenseCopyOf(m.Slice(offset, offset+h.Size, 0, cols))
+		offset += h.Size
+	}
+	return parts, nil
+}
+
+// newActivationLayer creates the activation layer following a weighted layer, or the heads layer for the output layer of a multi-task config.
+func newActivationLayer(cfg Config, t ActivationType, output bool) (Layer, error) {
+	if output && len(cfg.Heads) > 0 {
+		return NewHeadsLayer(cfg.Heads)
+	}
+	return NewActivationLayer(t)
+}
+
+// headsLayer applies the activation of each head to its output rows, replacing the output activation layer of a multi-task network.
+type headsLayer struct {
+	heads       []HeadConfig
+	activations []Layer
+}
+
+type headsLayerConfig struct {
+	Heads []headLayerConfig
+}
+
+type headLayerConfig struct {
+	Name       string
+	Size       int
+	Activation ActivationType
+}
+
+// NewHeadsLayer creates a layer applying the activation of each head to its rows of the inputs.
+func NewHeadsLayer(heads []HeadConfig) (Layer, error) {
+	if len(heads) == 0 {
+		return nil, fmt.Errorf("heads layer must have at least one head")
+	}
+	if err := validateHeads(heads); err != nil {
+		return nil, err
+	}
+	l := headsLayer{heads: heads, activations: make([]Layer, len(heads))}
+	for i, h := range heads {
+		a, err := NewActivationLayer(h.Activation)
+		if err != nil {
+			return nil, fmt.Errorf("head '%s': %v", h.Name, err)
+		}
+		l.activations[i] = a
+	}
+	return l, nil
+}
+
+func decodeHeadsLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg headsLayerConfig
+	if err := unmarshalLayerConfig(LayerTypeHeads, config, &cfg, params, state, 0, 0); err != nil {
+		return nil, err
+	}
+	heads := make([]HeadConfig, len(cfg.Heads))
+	for i, h := range cfg.Heads {
+		heads[i] = HeadConfig{Name: h.Name, Size: h.Size, Activation: h.Activation}
+	}
+	return NewHeadsLayer(heads)
+}
+
+func (l headsLayer) Type() LayerType {
+	return LayerTypeHeads
+}
+
+func (l headsLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	parts, err := splitHeads(l.heads, inputs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("heads layer inputs: %v", err)
+	}
+	caches := make([]interface{}, len(parts))
+	for i, a := range l.activations {
+		if parts[i], caches[i], err = a.Forward(parts[i], pass); err != nil {
+			return nil, nil, fmt.Errorf("head '%s': %v", l.heads[i].Name, err)
+		}
+	}
+	outputs, err := merge(MergeTypeConcat, parts)
+	return outputs, caches, err
+}
+
+func (l headsLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	caches, ok := cache.([]interface{})
+	if !ok || len(caches) != len(l.heads) {
+		return nil, nil, fmt.Errorf("heads layer cache must be from a forward pass")
+	}
+	parts, err := splitHeads(l.heads, grads)
+	if err != nil {
+		return nil, nil, fmt.Errorf("heads layer gradients: %v", err)
+	}
+	for i, a := range l.activations {
+		if parts[i], _, err = a.Backward(parts[i], caches[i]); err != nil {
+			return nil, nil, fmt.Errorf("head '%s': %v", l.heads[i].Name, err)
+		}
+	}
+	inputGrads, err := merge(MergeTypeConcat, parts)
+	return inputGrads, nil, err
+}
+
+func (l headsLayer) Params() []Param {
+	return nil
+}
+
+func (l headsLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("heads layer has no params, got %d", len(params))
+	}
+	return l, nil
+}
+
+func (l headsLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	cfg := headsLayerConfig{Heads: make([]headLayerConfig, len(l.heads))}
+	for i, h := range l.heads {
+		cfg.Heads[i] = headLayerConfig{Name: h.Name, Size: h.Size, Activation: h.Activation}
+	}
+	config, err := marshalLayerConfig(cfg)
+	return config, nil, err
+}
+
+// headsLoss is the weighted sum of the loss of each head over its output rows.
+type headsLoss struct {
+	heads  []HeadConfig
+	losses []Loss
+}
+
+func newHeadsLoss(heads []HeadConfig) (Loss, error) {
+	if err := validateHeads(heads); err != nil {
+		return nil, err
+	}
+	l := headsLoss{heads: heads, losses: make([]Loss, len(heads))}
+	for i, h := range heads {
+		hl, err := newLoss(Config{Loss: h.Loss, LossName: h.LossName, HuberDelta: h.HuberDelta})
+		if err != nil {
+			return nil, fmt.Errorf("head '%s': %v", h.Name, err)
+		}
+		l.losses[i] = hl
+	}
+	return l, nil
+}
+
+// Value computes the weighted sum of the mean loss of each head.
+func (l headsLoss) Value(targets, outputs mat.Matrix) (float64, error) {
+	targetParts, outputParts, err := l.split(targets, outputs)
+	if err != nil {
+		return 0, err
+	}
+	sum := 0.0
+	for i, hl := range l.losses {
+		v, err := hl.Value(targetParts[i], outputParts[i])
+		if err != nil {
+			return 0, fmt.Errorf("head '%s': %v", l.heads[i].Name, err)
+		}
+		sum += l.heads[i].weight() * v
+	}
+	return sum, nil
+}
+
+// Derivative computes the weighted loss gradient of each output element, without averaging over the columns.
+func (l headsLoss) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	targetParts, outputParts, err := l.split(targets, outputs)
+	if err != nil {
+		return nil, err
+	}
+	grads := make([]*mat.Dense, len(l.losses))
+	for i, hl := range l.losses {
+		if grads[i], err = hl.Derivative(targetParts[i], outputParts[i]); err != nil {
+			return nil, fmt.Errorf("head '%s': %v", l.heads[i].Name, err)
+		}
+		grads[i].Scale(l.heads[i].weight(), grads[i])
+	}
+	return merge(MergeTypeConcat, grads)
+}
+
+func (l headsLoss) split(targets, outputs mat.Matrix) ([]*mat.Dense, []*mat.Dense, error) {
+	targetParts, err := splitHeads(l.heads, mat.DenseCopyOf(targets))
+	if err != nil {
+		return nil, nil, fmt.Errorf("targets: %v", err)
+	}
+	outputParts, err := splitHeads(l.heads, mat.DenseCopyOf(outputs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("outputs: %v", err)
+	}
+	return targetParts, outputParts, nil
+}
+
+// PredictHeads outputs from a trained multi-task network, mapping the name of each configured head to its outputs.
+func (n Network) PredictHeads(inputData []float64) (map[string]*mat.Dense, error) {
+	if len(n.cfg.Heads) == 0 {
+		return nil, fmt.Errorf("network has no configured heads")
+	}
+	outputs, err := n.Predict(inputData)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := splitHeads(n.cfg.Heads, outputs)
+	if err != nil {
+		return nil, fmt.Errorf("splitting outputs: %v", err)
+	}
+	heads := make(map[string]*mat.Dense, len(parts))
+	for i, h := range n.cfg.Heads {
+		heads[h.Name] = parts[i]
+	}
+	return heads, nil
+}
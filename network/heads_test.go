@@ -0,0 +1,176 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/loss"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var multiTaskHeads = []network.HeadConfig{
+	{Name: "class", Size: 3, Activation: network.ActivationTypeSoftmax, Loss: network.LossTypeCategoricalCrossEntropy},
+	{Name: "value", Size: 1, Activation: network.ActivationTypeLinear, Loss: network.LossTypeHuber, Weight: 0.5},
+}
+
+func newMultiTask(t *testing.T, cfg network.Config) *network.Network {
+	t.Helper()
+	cfg.InputCount, cfg.Heads, cfg.Rate, cfg.RandSeed = 2, multiTaskHeads, 0.1, 4
+	if cfg.LayerSpec == "" {
+		cfg.LayerCounts = []int{6, 4}
+		cfg.Activations = []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeNone}
+	}
+	n, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// multiTaskRecords returns inputs with targets for the quadrant class of each input followed by the sum of its values.
+func multiTaskRecords() ([][]float64, [][]float64) {
+	inputs := sinRecords(12, 2, 3)
+	targets := make([][]float64, len(inputs))
+	for i, in := range inputs {
+		class := 0
+		if in[0] > 0 {
+			class = 1
+			if in[1] > 0 {
+				class = 2
+			}
+		}
+		targets[i] = []float64{0, 0, 0, in[0] + in[1]}
+		targets[i][class] = 1
+	}
+	return inputs, targets
+}
+
+func TestNetwork_PredictHeads(t *testing.T) {
+	inputs, targets := multiTaskRecords()
+	for name, cfg := range map[string]network.Config{
+		"layer counts": {},
+		"layer spec":   {LayerSpec: "dense:6,dense:4", Activations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeNone}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			n := newMultiTask(t, cfg)
+			checks, err := n.CheckGradients(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, check := range checks {
+				if check.MaxError() > gradTolerance {
+					t.Errorf("CheckGradients() %v, want errors below %v", check, gradTolerance)
+				}
+			}
+			first, err := n.TrainBatch(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last float64
+			for i := 0; i < 200; i++ {
+				if last, err = n.TrainBatch(inputs, targets); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last >= first/2 {
+				t.Errorf("TrainBatch() loss after training = %v, want less than half of %v", last, first)
+			}
+
+			heads, err := n.PredictHeads(inputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(heads) != 2 {
+				t.Fatalf("PredictHeads() = %v, want class and value heads", heads)
+			}
+			if r, _ := heads["class"].Dims(); r != 3 || math.Abs(mat.Sum(heads["class"])-1) > 1e-9 {
+				t.Errorf("PredictHeads() class = %v, want 3 softmax probabilities", mat.Formatted(heads["class"]))
+			}
+			if r, _ := heads["value"].Dims(); r != 1 {
+				t.Errorf("PredictHeads() value rows = %d, want 1", r)
+			}
+
+			data, err := json.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := &network.Network{}
+			if err := json.Unmarshal(data, restored); err != nil {
+				t.Fatal(err)
+			}
+			restoredHeads, err := restored.PredictHeads(inputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(restoredHeads, heads) {
+				t.Errorf("restored PredictHeads() = %v, want %v", restoredHeads, heads)
+			}
+		})
+	}
+}
+
+func TestNetwork_HeadsLoss(t *testing.T) {
+	n := newMultiTask(t, network.Config{})
+	inputs, targets := multiTaskRecords()
+	outputs := make([][]float64, len(inputs))
+	for i, in := range inputs {
+		outputs[i] = predictVector(t, n, in)
+	}
+	got, err := n.TrainBatch(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetMatrix, outputMatrix := mat.NewDense(4, len(inputs), nil), mat.NewDense(4, len(inputs), nil)
+	for i := range inputs {
+		targetMatrix.SetCol(i, targets[i])
+		outputMatrix.SetCol(i, outputs[i])
+	}
+	class, err := loss.CategoricalCrossEntropy{}.Value(targetMatrix.Slice(0, 3, 0, len(inputs)), outputMatrix.Slice(0, 3, 0, len(inputs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := loss.Huber{Delta: 1}.Value(targetMatrix.Slice(3, 4, 0, len(inputs)), outputMatrix.Slice(3, 4, 0, len(inputs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := class + 0.5*value; math.Abs(got-want) > 1e-12 {
+		t.Errorf("TrainBatch() loss = %v, want weighted sum of head losses %v", got, want)
+	}
+
+	plain, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.PredictHeads(inputs[0]); err == nil {
+		t.Errorf("PredictHeads() without heads error = nil, want error")
+	}
+}
+
+func TestNewHeadsLayer_Error(t *testing.T) {
+	tests := []struct {
+		name  string
+		heads []network.HeadConfig
+	}{
+		{name: "no heads"},
+		{name: "empty name", heads: []network.HeadConfig{{Size: 1}}},
+		{name: "duplicate name", heads: []network.HeadConfig{{Name: "a", Size: 1}, {Name: "a", Size: 2}}},
+		{name: "empty size", heads: []network.HeadConfig{{Name: "a"}}},
+		{name: "negative weight", heads: []network.HeadConfig{{Name: "a", Size: 1, Weight: -1}}},
+		{name: "unknown activation", heads: []network.HeadConfig{{Name: "a", Size: 1, Activation: "unknown"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := network.NewHeadsLayer(tt.heads); err == nil {
+				t.Errorf("NewHeadsLayer() error = nil, want error")
+			}
+		})
+	}
+	_, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{3}, Activations: []network.ActivationType{network.ActivationTypeNone}, Heads: multiTaskHeads})
+	if err == nil {
+		t.Errorf("NewRandom() with output count 3 for heads of total size 4 error = nil, want error")
+	}
+}
@@ -0,0 +1,109 @@
+package network
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// InitType selects how NewRandom initializes layer weights.
+type InitType string
+
+const (
+	InitTypeNone          InitType = ""
+	InitTypeUniform       InitType = "uniform"
+	InitTypeXavierUniform InitType = "xavier-uniform"
+	InitTypeXavierNormal  InitType = "xavier-normal"
+	InitTypeHeUniform     InitType = "he-uniform"
+	InitTypeHeNormal      InitType = "he-normal"
+	InitTypeLeCunUniform  InitType = "lecun-uniform"
+	InitTypeLeCunNormal   InitType = "lecun-normal"
+	InitTypeOrthogonal    InitType = "orthogonal"
+	InitTypeZeros         InitType = "zeros"
+	InitTypeConstant      InitType = "constant"
+)
+
+// InitTypes lists all weight initialization types.
+var InitTypes = []InitType{
+	InitTypeUniform,
+	InitTypeXavierUniform,
+	InitTypeXavierNormal,
+	InitTypeHeUniform,
+	InitTypeHeNormal,
+	InitTypeLeCunUniform,
+	InitTypeLeCunNormal,
+	InitTypeOrthogonal,
+	InitTypeZeros,
+	InitTypeConstant,
+}
+
+// initWeights creates a layer weight matrix with rows outputs and cols inputs, an empty type is uniform for compatibility with older configs.
+// Constant initialization fills every weight with value.
+func initWeights(t InitType, value float64, rows, cols int, src rand.Source) (*mat.Dense, error) {
+	fanIn, fanOut := float64(cols), float64(rows)
+	opt := matutil.OptRandomArraySource(src)
+	size := rows * cols
+	var data []float64
+	switch t {
+	case InitTypeNone, InitTypeUniform:
+		data = matutil.RandomArray(size, fanIn, opt)
+	case InitTypeXavierUniform:
+		data = matutil.UniformArray(size, math.Sqrt(6/(fanIn+fanOut)), opt)
+	case InitTypeXavierNormal:
+		data = matutil.NormalArray(size, math.Sqrt(2/(fanIn+fanOut)), opt)
+	case InitTypeHeUniform:
+		data = matutil.UniformArray(size, math.Sqrt(6/fanIn), opt)
+	case InitTypeHeNormal:
+		data = matutil.NormalArray(size, math.Sqrt(2/fanIn), opt)
+	case InitTypeLeCunUniform:
+		data = matutil.UniformArray(size, math.Sqrt(3/fanIn), opt)
+	case InitTypeLeCunNormal:
+		data = matutil.NormalArray(size, math.Sqrt(1/fanIn), opt)
+	case InitTypeOrthogonal:
+		return orthogonal(rows, cols, opt)
+	case InitTypeZeros:
+		data = make([]float64, size)
+	case InitTypeConstant:
+		data = matutil.FillArray(size, value)
+	default:
+		return nil, fmt.Errorf("unknown weight initialization type '%s'", t)
+	}
+	return matutil.New(rows, cols, data)
+}
+
+// orthogonal creates a matrix with orthonormal rows or columns, whichever there are fewer of,
+// from the QR decomposition of a random normal matrix.
+func orthogonal(rows, cols int, opt func(*matutil.RandomArrayCfg)) (*mat.Dense, error) {
+	long, short := rows, cols
+	if rows < cols {
+		long, short = cols, rows
+	}
+	random, err := matutil.New(long, short, matutil.NormalArray(long*short, 1, opt))
+	if err != nil {
+		return nil, err
+	}
+	var qr mat.QR
+	qr.Factorize(random)
+	var q, r mat.Dense
+	qr.QTo(&q)
+	qr.RTo(&r)
+	// flip column signs by the R diagonal so the result is uniformly distributed over orthogonal matrices
+	o := mat.NewDense(long, short, nil)
+	for j := 0; j < short; j++ {
+		sign := 1.0
+		if r.At(j, j) < 0 {
+			sign = -1
+		}
+		for i := 0; i < long; i++ {
+			o.Set(i, j, sign*q.At(i, j))
+		}
+	}
+	if rows < cols {
+		return mat.DenseCopyOf(o.T()), nil
+	}
+	return o, nil
+}
@@ -0,0 +1,149 @@
+package network_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+// linearWeights returns the weight columns of a single linear layer network by predicting each unit input.
+func linearWeights(t *testing.T, n *network.Network) [][]float64 {
+	t.Helper()
+	count := n.Config().InputCount
+	cols := make([][]float64, 0, count)
+	for i := 0; i < count; i++ {
+		input := make([]float64, count)
+		input[i] = 1
+		cols = append(cols, predictVector(t, n, input))
+	}
+	return cols
+}
+
+func newLinear(t *testing.T, init network.InitType, inputs, outputs int, seed uint64) *network.Network {
+	t.Helper()
+	n, err := network.NewRandom(network.Config{
+		InputCount:  inputs,
+		LayerCounts: []int{outputs},
+		Activations: []network.ActivationType{network.ActivationTypeLinear},
+		Init:        init,
+		InitValue:   0.5,
+		RandSeed:    seed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNewRandom_InitReproducible(t *testing.T) {
+	for _, init := range network.InitTypes {
+		t.Run(string(init), func(t *testing.T) {
+			first := linearWeights(t, newLinear(t, init, 4, 3, 1))
+			second := linearWeights(t, newLinear(t, init, 4, 3, 1))
+			if !reflect.DeepEqual(first, second) {
+				t.Errorf("weights from the same seed differ: %v != %v", first, second)
+			}
+			other := linearWeights(t, newLinear(t, init, 4, 3, 2))
+			random := init != network.InitTypeZeros && init != network.InitTypeConstant
+			if random == reflect.DeepEqual(first, other) {
+				t.Errorf("weights from different seeds equal = %v, want %v", !random, !random)
+			}
+		})
+	}
+}
+
+func TestNewRandom_InitDistribution(t *testing.T) {
+	const inputs, outputs = 200, 100
+	tests := []struct {
+		init       network.InitType
+		wantStdDev float64
+	}{
+		{init: network.InitTypeNone, wantStdDev: 1 / math.Sqrt(inputs) / math.Sqrt(3)},
+		{init: network.InitTypeXavierUniform, wantStdDev: math.Sqrt(6.0/(inputs+outputs)) / math.Sqrt(3)},
+		{init: network.InitTypeXavierNormal, wantStdDev: math.Sqrt(2.0 / (inputs + outputs))},
+		{init: network.InitTypeHeUniform, wantStdDev: math.Sqrt(6.0/inputs) / math.Sqrt(3)},
+		{init: network.InitTypeHeNormal, wantStdDev: math.Sqrt(2.0 / inputs)},
+		{init: network.InitTypeLeCunUniform, wantStdDev: math.Sqrt(3.0/inputs) / math.Sqrt(3)},
+		{init: network.InitTypeLeCunNormal, wantStdDev: math.Sqrt(1.0 / inputs)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.init), func(t *testing.T) {
+			sum, sumSq, count := 0.0, 0.0, 0.0
+			for _, col := range linearWeights(t, newLinear(t, tt.init, inputs, outputs, 0)) {
+				for _, v := range col {
+					sum += v
+					sumSq += v * v
+					count++
+				}
+			}
+			mean := sum / count
+			stdDev := math.Sqrt(sumSq/count - mean*mean)
+			if math.Abs(mean) > tt.wantStdDev/10 {
+				t.Errorf("weight mean = %v, want near 0", mean)
+			}
+			if math.Abs(stdDev-tt.wantStdDev) > tt.wantStdDev/20 {
+				t.Errorf("weight standard deviation = %v, want %v", stdDev, tt.wantStdDev)
+			}
+		})
+	}
+}
+
+func TestNewRandom_InitOrthogonal(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputs, outputs int
+	}{
+		{name: "square", inputs: 5, outputs: 5},
+		{name: "more inputs than outputs", inputs: 6, outputs: 3},
+		{name: "more outputs than inputs", inputs: 3, outputs: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols := linearWeights(t, newLinear(t, network.InitTypeOrthogonal, tt.inputs, tt.outputs, 0))
+			// the shorter dimension's vectors must be orthonormal
+			vectors := cols
+			if tt.outputs < tt.inputs {
+				vectors = make([][]float64, tt.outputs)
+				for i := range vectors {
+					vectors[i] = make([]float64, tt.inputs)
+					for j := range cols {
+						vectors[i][j] = cols[j][i]
+					}
+				}
+			}
+			for i := range vectors {
+				for j := range vectors {
+					dot := 0.0
+					for k := range vectors[i] {
+						dot += vectors[i][k] * vectors[j][k]
+					}
+					want := 0.0
+					if i == j {
+						want = 1
+					}
+					if math.Abs(dot-want) > 1e-9 {
+						t.Errorf("vector %d dot vector %d = %v, want %v", i, j, dot, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewRandom_InitConstant(t *testing.T) {
+	for _, col := range linearWeights(t, newLinear(t, network.InitTypeConstant, 3, 2, 0)) {
+		for _, v := range col {
+			if v != 0.5 {
+				t.Errorf("constant weight = %v, want 0.5", v)
+			}
+		}
+	}
+}
+
+func TestNewRandom_InitUnknown(t *testing.T) {
+	if _, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{1}, Init: "unknown"}); err == nil {
+		t.Errorf("NewRandom() expected error for an unknown init type")
+	}
+}
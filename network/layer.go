@@ -0,0 +1,208 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// LayerType names a registered layer kind used to restore layers from model files.
+type LayerType string
+
+const (
+	LayerTypeDense         LayerType = "dense"
+	LayerTypeActivation    LayerType = "activation"
+	LayerTypeDropout       LayerType = "dropout"
+	LayerTypeNormalization LayerType = "normalization"
+)
+
+// Layer is one stage of a network, transforming a matrix with one column per record into the inputs of the next layer.
+// Layers are immutable, training replaces them with updated copies.
+type Layer interface {
+	// Type returns the registered kind of the layer.
+	Type() LayerType
+	// Forward returns the layer outputs for a matrix of inputs, and any values Backward needs from the pass.
+	Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error)
+	// Backward returns the loss gradient of the inputs from the loss gradient of the outputs and the cache of a training forward pass,
+	// along with the gradient of each param averaged over the batch.
+	Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error)
+	// Params returns the trainable parameters of the layer, if any.
+	Params() []Param
+	// WithParams returns a copy of the layer with new values for each of its params, in the same order.
+	WithParams(params []*mat.Dense) (Layer, error)
+	// MarshalLayer returns the layer configuration and any non-trainable state, restored by its registered LayerDecoder along with its params.
+	MarshalLayer() (json.RawMessage, []*mat.Dense, error)
+}
+
+// StatefulLayer is a Layer with non-trainable state updated by training, like running statistics.
+type StatefulLayer interface {
+	Layer
+	// Update returns a copy of the layer with its state updated from the cache of a training forward pass.
+	Update(cache interface{}) (Layer, error)
+}
+
+// Param is a trainable layer parameter.
+type Param struct {
+	Value       *mat.Dense
+	Regularized bool // whether L1 and L2 penalties and weight decay apply, true for weights but not biases
+}
+
+// Pass describes a forward pass through the layers of a network.
+type Pass struct {
+	Training bool       // training passes use batch statistics instead of running statistics
+	Rand     *rand.Rand // draws dropout masks, nil disables dropout
+	Steps    int        // time steps of sequence inputs, stored step by step with one column for each sequence, zero for inputs that aren't sequences
+}
+
+// LayerDecoder restores a layer from the configuration, params and state returned by its MarshalLayer and Params.
+type LayerDecoder func(config json.RawMessage, params, state []*mat.Dense) (Layer, error)
+
+var layerRegistry = struct {
+	sync.RWMutex
+	decoders map[LayerType]LayerDecoder
+}{
+	decoders: map[LayerType]LayerDecoder{
+		LayerTypeDense:         decodeDenseLayer,
+		LayerTypeActivation:    decodeActivationLayer,
+		LayerTypeDropout:       decodeDropoutLayer,
+		LayerTypeNormalization: decodeNormLayer,
+		LayerTypeConv2D:        decodeConv2DLayer,
+		LayerTypeMaxPool2D:     decodePool2DLayer(LayerTypeMaxPool2D),
+		LayerTypeAvgPool2D:     decodePool2DLayer(LayerTypeAvgPool2D),
+		LayerTypeFlatten:       decodeFlattenLayer,
+		LayerTypeRNN:           decodeRecurrentLayer(LayerTypeRNN),
+		LayerTypeLSTM:          decodeRecurrentLayer(LayerTypeLSTM),
+		LayerTypeGRU:           decodeRecurrentLayer(LayerTypeGRU),
+		LayerTypeHeads:         decodeHeadsLayer,
+	},
+}
+
+// RegisterLayer registers a custom layer kind so networks containing it can be loaded from model files.
+func RegisterLayer(t LayerType, decoder LayerDecoder) error {
+	if t == "" {
+		return fmt.Errorf("layer type cannot be empty")
+	}
+	if decoder == nil {
+		return fmt.Errorf("layer decoder for '%s' cannot be nil", t)
+	}
+	layerRegistry.Lock()
+	defer layerRegistry.Unlock()
+	if _, ok := layerRegistry.decoders[t]; ok {
+		return fmt.Errorf("layer type '%s' is already registered", t)
+	}
+	layerRegistry.decoders[t] = decoder
+	return nil
+}
+
+// LayerTypes returns all registered layer types in alphabetical order.
+func LayerTypes() []LayerType {
+	layerRegistry.RLock()
+	defer layerRegistry.RUnlock()
+	types := make([]LayerType, 0, len(layerRegistry.decoders))
+	for t := range layerRegistry.decoders {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// decodeLayer restores a layer of a registered type.
+func decodeLayer(t LayerType, config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	layerRegistry.RLock()
+	decoder, ok := layerRegistry.decoders[t]
+	layerRegistry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown layer type '%s'", t)
+	}
+	return decoder(config, params, state)
+}
+
+// paramValues returns the values of params.
+func paramValues(params []Param) []*mat.Dense {
+	values := make([]*mat.Dense, 0, len(params))
+	for _, p := range params {
+		values = append(values, p.Value)
+	}
+	return values
+}
+
+// propagateForwards passes inputs through each layer, returning the final outputs and the cache of each layer.
+func propagateForwards(inputs *mat.Dense, layers []Layer, pass Pass) (*mat.Dense, []interface{}, error) {
+	outputs, caches, _, err := propagateSequence(inputs, layers, pass, nil)
+	return outputs, caches, err
+}
+
+// propagateSequence passes inputs through each layer, starting each recurrent layer from its hidden state in states, if any.
+// Returns the final outputs, the cache of each layer and the hidden state of each recurrent layer after the last time step.
+func propagateSequence(inputs *mat.Dense, layers []Layer, pass Pass, states [][]*mat.Dense) (*mat.Dense, []interface{}, [][]*mat.Dense, error) {
+	caches := make([]interface{}, len(layers))
+	finals := make([][]*mat.Dense, len(layers))
+	for i, l := range layers {
+		var outputs *mat.Dense
+		var err error
+		if r, ok := l.(RecurrentLayer); ok {
+			var state []*mat.Dense
+			if i < len(states) {
+				state = states[i]
+			}
+			outputs, caches[i], finals[i], err = r.ForwardSequence(inputs, pass, state)
+		} else {
+			outputs, caches[i], err = l.Forward(inputs, pass)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("layer %d %s: %v", i, l.Type(), err)
+		}
+		inputs = outputs
+	}
+	return inputs, caches, finals, nil
+}
+
+// propagateBackwards passes the loss gradient of the outputs of a stack of layers back through each layer,
+// returning the gradients of every layer's params in stack order.
+func propagateBackwards(grads *mat.Dense, layers []Layer, caches []interface{}) ([]*mat.Dense, error) {
+	layerGrads := make([][]*mat.Dense, len(layers))
+	count := 0
+	for i := len(layers) - 1; i >= 0; i-- {
+		var err error
+		grads, layerGrads[i], err = layers[i].Backward(grads, caches[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %d %s: %v", i, layers[i].Type(), err)
+		}
+		if len(layerGrads[i]) != len(layers[i].Params()) {
+			return nil, fmt.Errorf("layer %d %s gradient count %d must equal param count %d", i, layers[i].Type(), len(layerGrads[i]), len(layers[i].Params()))
+		}
+		count += len(layerGrads[i])
+	}
+	paramGrads := make([]*mat.Dense, 0, count)
+	for _, g := range layerGrads {
+		paramGrads = append(paramGrads, g...)
+	}
+	return paramGrads, nil
+}
+
+// marshalLayerConfig encodes a layer configuration.
+func marshalLayerConfig(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling layer config: %v", err)
+	}
+	return data, nil
+}
+
+// unmarshalLayerConfig decodes a layer configuration and checks the param and state counts.
+func unmarshalLayerConfig(t LayerType, config json.RawMessage, v interface{}, params, state []*mat.Dense, paramCount, stateCount int) error {
+	if len(params) != paramCount || len(state) != stateCount {
+		return fmt.Errorf("%s layer must have %d params and %d state matrices, got %d and %d", t, paramCount, stateCount, len(params), len(state))
+	}
+	if v == nil || len(config) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(config, v); err != nil {
+		return fmt.Errorf("unmarshaling %s layer config: %v", t, err)
+	}
+	return nil
+}
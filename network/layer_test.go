@@ -0,0 +1,179 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// scaleLayer multiplies its inputs by a constant factor.
+type scaleLayer struct {
+	Factor float64
+}
+
+func (l scaleLayer) Type() network.LayerType {
+	return "test-scale"
+}
+
+func (l scaleLayer) Forward(inputs *mat.Dense, _ network.Pass) (*mat.Dense, interface{}, error) {
+	outputs, err := matutil.Scale(l.Factor, inputs)
+	return outputs, nil, err
+}
+
+func (l scaleLayer) Backward(grads *mat.Dense, _ interface{}) (*mat.Dense, []*mat.Dense, error) {
+	inputGrads, err := matutil.Scale(l.Factor, grads)
+	return inputGrads, nil, err
+}
+
+func (l scaleLayer) Params() []network.Param {
+	return nil
+}
+
+func (l scaleLayer) WithParams(_ []*mat.Dense) (network.Layer, error) {
+	return l, nil
+}
+
+func (l scaleLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := json.Marshal(l)
+	return config, nil, err
+}
+
+func init() {
+	err := network.RegisterLayer("test-scale", func(config json.RawMessage, _, _ []*mat.Dense) (network.Layer, error) {
+		var l scaleLayer
+		err := json.Unmarshal(config, &l)
+		return l, err
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func newLayer(t *testing.T) func(network.Layer, error) network.Layer {
+	return func(l network.Layer, err error) network.Layer {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+}
+
+func TestNewFromLayers(t *testing.T) {
+	must := newLayer(t)
+	layers := []network.Layer{
+		must(network.NewDenseLayer(mat.NewDense(2, 2, []float64{1, 2, 3, 4}), mat.NewDense(2, 1, []float64{0.5, -0.5}))),
+		must(network.NewActivationLayer(network.ActivationTypeTanh)),
+		must(network.NewDenseLayer(mat.NewDense(1, 2, []float64{1, -1}), nil)),
+		must(network.NewActivationLayer(network.ActivationTypeLinear)),
+	}
+	n, err := network.NewFromLayers(network.Config{InputCount: 2}, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := math.Tanh(1*1+2*2+0.5) - math.Tanh(3*1+4*2-0.5)
+	if got := predictVector(t, n, []float64{1, 2})[0]; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Predict() = %v, want %v", got, want)
+	}
+
+	if _, err := network.NewFromLayers(network.Config{InputCount: 3}, layers); err == nil {
+		t.Errorf("NewFromLayers() expected error for layers that don't accept the input count")
+	}
+	if _, err := network.NewFromLayers(network.Config{InputCount: 2}, nil); err == nil {
+		t.Errorf("NewFromLayers() expected error for an empty stack")
+	}
+}
+
+func TestNetwork_Layers(t *testing.T) {
+	cfg := network.Config{
+		InputCount:    3,
+		LayerCounts:   []int{4, 4, 2},
+		Normalization: []network.NormType{network.NormTypeLayer, network.NormTypeNone},
+		Dropout:       []float64{0, 0.5},
+		Rate:          0.1,
+	}
+	n, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []network.LayerType
+	for _, l := range n.Layers() {
+		got = append(got, l.Type())
+	}
+	want := []network.LayerType{
+		network.LayerTypeDense, network.LayerTypeNormalization, network.LayerTypeActivation,
+		network.LayerTypeDense, network.LayerTypeActivation, network.LayerTypeDropout,
+		network.LayerTypeDense, network.LayerTypeActivation,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Layers() types = %v, want %v", got, want)
+	}
+
+	stacked, err := network.NewFromLayers(n.Config(), n.Layers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := n.Train([]float64{1, 2, 3}, []float64{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stacked.Train([]float64{1, 2, 3}, []float64{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := predictVector(t, stacked, []float64{1, 2, 3}), predictVector(t, n, []float64{1, 2, 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Predict() from the same stack of layers = %v, want %v", got, want)
+	}
+}
+
+func TestRegisterLayer(t *testing.T) {
+	decoder := func(json.RawMessage, []*mat.Dense, []*mat.Dense) (network.Layer, error) { return scaleLayer{}, nil }
+	if err := network.RegisterLayer("test-scale", decoder); err == nil {
+		t.Errorf("RegisterLayer() expected error registering a duplicate type")
+	}
+	if err := network.RegisterLayer(network.LayerTypeDense, decoder); err == nil {
+		t.Errorf("RegisterLayer() expected error replacing a built-in layer")
+	}
+
+	must := newLayer(t)
+	n, err := network.NewFromLayers(network.Config{InputCount: 2, Rate: 0.1}, []network.Layer{
+		must(network.NewDenseLayer(mat.NewDense(2, 2, []float64{0.1, 0.2, 0.3, 0.4}), nil)),
+		scaleLayer{Factor: 2},
+		must(network.NewActivationLayer(network.ActivationTypeSigmoid)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Train([]float64{1, -1}, []float64{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := predictVector(t, restored, []float64{1, -1}), predictVector(t, n, []float64{1, -1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored Predict() = %v, want %v", got, want)
+	}
+}
+
+// checkLayerGradients fails the test if the gradients from a layer's Backward differ from finite differences.
+func checkLayerGradients(t *testing.T, l network.Layer, inputs *mat.Dense, pass network.Pass) {
+	t.Helper()
+	check, err := network.CheckLayerGradients(l, inputs, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.MaxError() > gradTolerance {
+		t.Errorf("CheckLayerGradients() = %v, want errors below %v", check, gradTolerance)
+	}
+}
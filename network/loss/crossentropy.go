@@ -0,0 +1,50 @@
+package loss
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// epsilon clips probabilities away from 0 and 1 so logarithms and divisions stay finite.
+const epsilon = 1e-12
+
+func clip(o float64) float64 {
+	return math.Max(epsilon, math.Min(1-epsilon, o))
+}
+
+// BinaryCrossEntropy loss for independent probability outputs, typically paired with a sigmoid output activation.
+type BinaryCrossEntropy struct{}
+
+// Value computes the mean binary cross-entropy of all columns.
+func (l BinaryCrossEntropy) Value(targets, outputs mat.Matrix) (float64, error) {
+	return meanColumnSum(targets, outputs, func(t, o float64) float64 {
+		o = clip(o)
+		return -(t*math.Log(o) + (1-t)*math.Log(1-o))
+	})
+}
+
+// Derivative computes (outputs - targets) / (outputs * (1 - outputs)).
+func (l BinaryCrossEntropy) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	return derivative(targets, outputs, func(t, o float64) float64 {
+		o = clip(o)
+		return (o - t) / (o * (1 - o))
+	})
+}
+
+// CategoricalCrossEntropy loss for a probability distribution over classes, typically paired with a softmax output activation.
+type CategoricalCrossEntropy struct{}
+
+// Value computes the mean categorical cross-entropy of all columns.
+func (l CategoricalCrossEntropy) Value(targets, outputs mat.Matrix) (float64, error) {
+	return meanColumnSum(targets, outputs, func(t, o float64) float64 {
+		return -t * math.Log(clip(o))
+	})
+}
+
+// Derivative computes -targets / outputs.
+func (l CategoricalCrossEntropy) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	return derivative(targets, outputs, func(t, o float64) float64 {
+		return -t / clip(o)
+	})
+}
@@ -0,0 +1,30 @@
+package loss
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Huber loss is quadratic for errors smaller than Delta and linear beyond it, making it less sensitive to outliers than MSE.
+type Huber struct {
+	Delta float64
+}
+
+// Value computes the mean Huber loss of all columns.
+func (l Huber) Value(targets, outputs mat.Matrix) (float64, error) {
+	return meanColumnSum(targets, outputs, func(t, o float64) float64 {
+		d := math.Abs(o - t)
+		if d <= l.Delta {
+			return 0.5 * d * d
+		}
+		return l.Delta * (d - 0.5*l.Delta)
+	})
+}
+
+// Derivative computes outputs - targets clipped to [-Delta, Delta].
+func (l Huber) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	return derivative(targets, outputs, func(t, o float64) float64 {
+		return math.Max(-l.Delta, math.Min(l.Delta, o-t))
+	})
+}
@@ -0,0 +1,107 @@
+package loss_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network/loss"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+type lossFunc interface {
+	Value(targets, outputs mat.Matrix) (float64, error)
+	Derivative(targets, outputs mat.Matrix) (*mat.Dense, error)
+}
+
+func TestLoss(t *testing.T) {
+	tests := []struct {
+		name           string
+		l              lossFunc
+		targets        *mat.Dense
+		outputs        *mat.Dense
+		wantValue      float64
+		wantDerivative []float64
+	}{
+		{
+			name:           "mse should halve the squared error summed over outputs and averaged over columns",
+			l:              loss.MSE{},
+			targets:        mat.NewDense(2, 2, []float64{1, 0, 0, 1}),
+			outputs:        mat.NewDense(2, 2, []float64{0.5, 0, 0, 0}),
+			wantValue:      (0.5*0.25 + 0.5*1) / 2,
+			wantDerivative: []float64{-0.5, 0, 0, -1},
+		},
+		{
+			name:           "binary cross-entropy should sum over outputs",
+			l:              loss.BinaryCrossEntropy{},
+			targets:        mat.NewDense(2, 1, []float64{1, 0}),
+			outputs:        mat.NewDense(2, 1, []float64{0.5, 0.25}),
+			wantValue:      -math.Log(0.5) - math.Log(0.75),
+			wantDerivative: []float64{-0.5 / 0.25, 0.25 / (0.25 * 0.75)},
+		},
+		{
+			name:           "categorical cross-entropy should only count the target classes",
+			l:              loss.CategoricalCrossEntropy{},
+			targets:        mat.NewDense(3, 1, []float64{0, 1, 0}),
+			outputs:        mat.NewDense(3, 1, []float64{0.2, 0.5, 0.3}),
+			wantValue:      -math.Log(0.5),
+			wantDerivative: []float64{0, -2, 0},
+		},
+		{
+			name:           "categorical cross-entropy should stay finite for zero outputs",
+			l:              loss.CategoricalCrossEntropy{},
+			targets:        mat.NewDense(2, 1, []float64{1, 0}),
+			outputs:        mat.NewDense(2, 1, []float64{0, 1}),
+			wantValue:      -math.Log(1e-12),
+			wantDerivative: []float64{-1e12, 0},
+		},
+		{
+			name:           "huber should be quadratic inside delta and linear outside it",
+			l:              loss.Huber{Delta: 1},
+			targets:        mat.NewDense(2, 1, []float64{0, 0}),
+			outputs:        mat.NewDense(2, 1, []float64{0.5, -3}),
+			wantValue:      0.5*0.25 + (3 - 0.5),
+			wantDerivative: []float64{0.5, -1},
+		},
+		{
+			name:           "mae should sum absolute errors with a sign derivative",
+			l:              loss.MAE{},
+			targets:        mat.NewDense(3, 1, []float64{0, 0, 1}),
+			outputs:        mat.NewDense(3, 1, []float64{0.5, -3, 1}),
+			wantValue:      0.5 + 3,
+			wantDerivative: []float64{1, -1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.l.Value(tt.targets, tt.outputs)
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if math.Abs(value-tt.wantValue) > 1e-9 {
+				t.Errorf("Value() = %v, want %v", value, tt.wantValue)
+			}
+			derivative, err := tt.l.Derivative(tt.targets, tt.outputs)
+			if err != nil {
+				t.Fatalf("Derivative() error = %v", err)
+			}
+			for i, want := range tt.wantDerivative {
+				if got := derivative.RawMatrix().Data[i]; math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+					t.Errorf("Derivative()[%d] = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoss_MismatchedDimensions(t *testing.T) {
+	losses := []lossFunc{loss.MSE{}, loss.BinaryCrossEntropy{}, loss.CategoricalCrossEntropy{}, loss.Huber{Delta: 1}, loss.MAE{}}
+	for _, l := range losses {
+		if _, err := l.Value(mat.NewDense(2, 1, nil), mat.NewDense(3, 1, nil)); err == nil {
+			t.Errorf("%T Value() expected error for mismatched dimensions", l)
+		}
+		if _, err := l.Derivative(mat.NewDense(2, 1, nil), mat.NewDense(3, 1, nil)); err == nil {
+			t.Errorf("%T Derivative() expected error for mismatched dimensions", l)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package loss
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// MAE absolute error loss, the sum of absolute errors of each record, less sensitive to outliers than MSE.
+type MAE struct{}
+
+// Value computes the mean absolute error loss of all columns.
+func (l MAE) Value(targets, outputs mat.Matrix) (float64, error) {
+	return meanColumnSum(targets, outputs, func(t, o float64) float64 {
+		return math.Abs(o - t)
+	})
+}
+
+// Derivative computes the sign of outputs - targets, zero where they are equal.
+func (l MAE) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	return derivative(targets, outputs, func(t, o float64) float64 {
+		switch {
+		case o > t:
+			return 1
+		case o < t:
+			return -1
+		default:
+			return 0
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package loss
+
+import (
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// MSE squared error loss, half the sum of squared errors of each record so its derivative is simply outputs - targets.
+type MSE struct{}
+
+// Value computes the mean squared error loss of all columns.
+func (l MSE) Value(targets, outputs mat.Matrix) (float64, error) {
+	return meanColumnSum(targets, outputs, func(t, o float64) float64 {
+		d := o - t
+		return 0.5 * d * d
+	})
+}
+
+// Derivative computes outputs - targets.
+func (l MSE) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	return matutil.Sub(outputs, targets)
+}
+
+// meanColumnSum sums fn over every element of each column, then averages over the columns.
+func meanColumnSum(targets, outputs mat.Matrix, fn func(t, o float64) float64) (float64, error) {
+	tr, tc := targets.Dims()
+	or, oc := outputs.Dims()
+	if tr != or || tc != oc {
+		return 0, fmt.Errorf("target dimensions %dx%d must equal output dimensions %dx%d", tr, tc, or, oc)
+	}
+	sum := 0.0
+	for i := 0; i < tr; i++ {
+		for j := 0; j < tc; j++ {
+			sum += fn(targets.At(i, j), outputs.At(i, j))
+		}
+	}
+	return sum / float64(tc), nil
+}
+
+// derivative applies fn to every target and output element pair.
+func derivative(targets, outputs mat.Matrix, fn func(t, o float64) float64) (*mat.Dense, error) {
+	tr, tc := targets.Dims()
+	or, oc := outputs.Dims()
+	if tr != or || tc != oc {
+		return nil, fmt.Errorf("target dimensions %dx%d must equal output dimensions %dx%d", tr, tc, or, oc)
+	}
+	return matutil.Apply(func(i, j int, o float64) float64 {
+		return fn(targets.At(i, j), o)
+	}, outputs)
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/network/loss"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// LossType selects the function measuring how far network outputs are from their targets.
+type LossType int
+
+const (
+	LossTypeMSE LossType = iota
+	LossTypeBinaryCrossEntropy
+	LossTypeCategoricalCrossEntropy
+	LossTypeHuber
+	LossTypeMAE
+)
+
+// Loss measures how far network outputs are from their targets.
+type Loss interface {
+	// Value computes the mean loss of all columns.
+	Value(targets, outputs mat.Matrix) (float64, error)
+	// Derivative computes the loss gradient of each output element, without averaging over the columns.
+	Derivative(targets, outputs mat.Matrix) (*mat.Dense, error)
+}
+
+func newLoss(cfg Config) (Loss, error) {
+	if len(cfg.Heads) > 0 {
+		return newHeadsLoss(cfg.Heads)
+	}
+	if cfg.LossName != "" {
+		return registeredLoss(cfg.LossName)
+	}
+	switch cfg.Loss {
+	case LossTypeMSE:
+		return loss.MSE{}, nil
+	case LossTypeBinaryCrossEntropy:
+		return loss.BinaryCrossEntropy{}, nil
+	case LossTypeCategoricalCrossEntropy:
+		return loss.CategoricalCrossEntropy{}, nil
+	case LossTypeMAE:
+		return loss.MAE{}, nil
+	case LossTypeHuber:
+		delta := cfg.HuberDelta
+		if delta == 0 {
+			delta = 1
+		}
+		return loss.Huber{Delta: delta}, nil
+	default:
+		return nil, fmt.Errorf("unknown loss type %v", cfg.Loss)
+	}
+}
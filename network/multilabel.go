@@ -0,0 +1,69 @@
+package network
+
+import "fmt"
+
+// defaultThreshold is the decision threshold of each output of a multi-label network without configured thresholds.
+const defaultThreshold = 0.5
+
+func validateThresholds(thresholds []float64, outputCount int) error {
+	for i, t := range thresholds {
+		if t <= 0 || t >= 1 {
+			return fmt.Errorf("decision threshold %d must be between 0 and 1, got %v", i, t)
+		}
+	}
+	if len(thresholds) > 0 && outputCount > 0 && len(thresholds) != outputCount {
+		return fmt.Errorf("decision threshold count %d must equal output count %d", len(thresholds), outputCount)
+	}
+	return nil
+}
+
+// Thresholds returns the decision threshold of each of a number of outputs, 0.5 for outputs without a configured threshold.
+func Thresholds(configured []float64, outputCount int) []float64 {
+	thresholds := make([]float64, outputCount)
+	for i := range thresholds {
+		thresholds[i] = defaultThreshold
+		if i < len(configured) {
+			thresholds[i] = configured[i]
+		}
+	}
+	return thresholds
+}
+
+// SetThresholds replaces the decision thresholds of a multi-label network, one for each output.
+func (n *Network) SetThresholds(thresholds []float64) error {
+	if n.cfg.Task != TaskTypeMultiLabel {
+		return fmt.Errorf("decision thresholds need a multi-label task")
+	}
+	outputCount := 0
+	if n.cfg.InputCount > 0 {
+		outputs, err := n.Predict(make([]float64, n.cfg.InputCount))
+		if err != nil {
+			return err
+		}
+		outputCount, _ = outputs.Dims()
+	}
+	if err := validateThresholds(thresholds, outputCount); err != nil {
+		return err
+	}
+	n.cfg.Thresholds = append([]float64(nil), thresholds...)
+	return nil
+}
+
+// PredictLabels predicts whether each label of a multi-label network applies to the inputs,
+// when its output reaches the decision threshold of the label.
+func (n Network) PredictLabels(inputData []float64) ([]bool, error) {
+	if n.cfg.Task != TaskTypeMultiLabel {
+		return nil, fmt.Errorf("predicting labels needs a multi-label task")
+	}
+	outputs, err := n.Predict(inputData)
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := outputs.Dims()
+	thresholds := Thresholds(n.cfg.Thresholds, rows)
+	labels := make([]bool, rows)
+	for i := range labels {
+		labels[i] = outputs.At(i, 0) >= thresholds[i]
+	}
+	return labels, nil
+}
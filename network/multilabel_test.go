@@ -0,0 +1,126 @@
+package network_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+// labelRecords returns inputs with labels for a positive first value, a positive second value and a positive sum.
+func labelRecords() ([][]float64, [][]float64) {
+	inputs := sinRecords(16, 2, 6)
+	targets := make([][]float64, len(inputs))
+	for i, in := range inputs {
+		targets[i] = make([]float64, 3)
+		for j, v := range []float64{in[0], in[1], in[0] + in[1]} {
+			if v > 0 {
+				targets[i][j] = 1
+			}
+		}
+	}
+	return inputs, targets
+}
+
+func TestNetwork_PredictLabels(t *testing.T) {
+	inputs, targets := labelRecords()
+	n, err := network.NewRandom(network.Config{
+		InputCount:  2,
+		LayerCounts: []int{3},
+		Activations: []network.ActivationType{network.ActivationTypeSigmoid},
+		Loss:        network.LossTypeBinaryCrossEntropy,
+		Task:        network.TaskTypeMultiLabel,
+		Thresholds:  []float64{0.5, 0.5, 0.5},
+		Rate:        1,
+		RandSeed:    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := n.TrainBatch(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, in := range inputs {
+		labels, err := n.PredictLabels(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, l := range labels {
+			if l != (targets[i][j] == 1) {
+				t.Errorf("PredictLabels(%v) = %v, want labels %v", in, labels, targets[i])
+				break
+			}
+		}
+	}
+
+	high := []float64{0.5, 0.999, 0.999}
+	if err := n.SetThresholds(high); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	flipped := 0
+	for i, in := range inputs {
+		labels, err := restored.PredictLabels(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs := predictVector(t, n, in)
+		for j, l := range labels {
+			if l != (outputs[j] >= high[j]) {
+				t.Errorf("restored PredictLabels(%v) = %v, want outputs %v at thresholds %v", in, labels, outputs, high)
+				break
+			}
+			if l != (targets[i][j] == 1) {
+				flipped++
+			}
+		}
+	}
+	if flipped == 0 {
+		t.Errorf("PredictLabels() with high thresholds = trained labels, want some labels below their threshold")
+	}
+	if err := n.SetThresholds([]float64{0.5}); err == nil {
+		t.Errorf("SetThresholds() with too few thresholds error = nil, want error")
+	}
+}
+
+func TestNewRandom_MultiLabelError(t *testing.T) {
+	sigmoid := []network.ActivationType{network.ActivationTypeSigmoid}
+	bce := network.LossTypeBinaryCrossEntropy
+	tests := []struct {
+		name string
+		cfg  network.Config
+	}{
+		{name: "softmax output", cfg: network.Config{Task: network.TaskTypeMultiLabel, Activations: []network.ActivationType{network.ActivationTypeSoftmax}, Loss: bce}},
+		{name: "mse loss", cfg: network.Config{Task: network.TaskTypeMultiLabel, Activations: sigmoid}},
+		{name: "threshold count", cfg: network.Config{Task: network.TaskTypeMultiLabel, Activations: sigmoid, Loss: bce, Thresholds: []float64{0.5}}},
+		{name: "threshold range", cfg: network.Config{Task: network.TaskTypeMultiLabel, Activations: sigmoid, Loss: bce, Thresholds: []float64{0.5, 1}}},
+		{name: "thresholds without multi-label", cfg: network.Config{Activations: sigmoid, Loss: bce, Thresholds: []float64{0.5, 0.5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.InputCount, tt.cfg.LayerCounts = 2, []int{2}
+			if _, err := network.NewRandom(tt.cfg); err == nil {
+				t.Errorf("NewRandom() error = nil, want error")
+			}
+		})
+	}
+	n, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{2}, Activations: sigmoid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.PredictLabels([]float64{0, 0}); err == nil {
+		t.Errorf("PredictLabels() without multi-label task error = nil, want error")
+	}
+	if err := n.SetThresholds([]float64{0.5, 0.5}); err == nil {
+		t.Errorf("SetThresholds() without multi-label task error = nil, want error")
+	}
+}
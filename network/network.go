@@ -5,55 +5,106 @@ import (
 
 	"github.com/benjohns1/neural-net-go/matutil"
 	"github.com/benjohns1/neural-net-go/network/activation"
+	"github.com/benjohns1/neural-net-go/network/loss"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
 
+	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/mat"
 )
 
 // Config network constructor.
 type Config struct {
-	InputCount  int
-	LayerCounts []int
-	Activation  ActivationType
-	Rate        float64
-	RandSeed    uint64
-	RandState   uint64
-	Trained     uint64
+	InputCount       int
+	InputShape       ImageShape // image shape of the inputs for convolution and pooling layers, defaults to InputCount channels of 1x1
+	LayerCounts      []int
+	LayerSpec        string           // layer spec parsed by ParseLayerSpec for NewRandom, used instead of LayerCounts when set
+	BPTTSteps        int              // time steps backpropagated through by each TrainSequence update, whole sequences when zero
+	Activations      []ActivationType // one for each layer in LayerCounts
+	Activation       ActivationType   // Deprecated: used for all layers when Activations is empty
+	OutputActivation ActivationType   // Deprecated: used for the output layer when Activations is empty, defaults to Activation
+	Loss             LossType
+	LossName         string               // registered custom loss, used instead of Loss when set
+	HuberDelta       float64              // defaults to 1 when zero
+	Heads            []HeadConfig         // named output heads, each with its own activation and loss, replacing the output activation and loss when set
+	Task             TaskType             // classification by default, regression needs a linear output and an MSE, MAE or Huber loss, multi-label a sigmoid output and binary cross-entropy
+	TargetScaling    TargetScaling        // standardizes regression targets for training, with predictions transformed back, none when empty
+	Thresholds       []float64            // decision threshold of each output of a multi-label network, 0.5 when empty
+	Preprocessing    *preprocess.Pipeline // fitted conversion of raw records to inputs and targets, used by PredictRecord, none when nil
+	Rate             float64
+	Schedule         ScheduleConfig
+	Optimizer        OptimizerConfig
+	L1               float64    // L1 weight penalty coefficient
+	L2               float64    // L2 weight penalty coefficient
+	WeightDecay      float64    // decoupled weight decay, the fraction of each weight removed per step scaled by the learning rate
+	Dropout          []float64  // training dropout rate for the outputs of each hidden layer, empty for none
+	Normalization    []NormType // normalization of the weighted inputs of each hidden layer before activation, empty for none
+	NormMomentum     float64    // batch normalization running statistic momentum, commonly 0.9
+	NormEpsilon      float64    // normalization variance stability term, defaults to 1e-5 when zero
+	Init             InitType   // weight initialization for NewRandom, defaults to uniform
+	InitValue        float64    // weight value for constant initialization
+	RandSeed         uint64
+	RandState        uint64
+	ShuffleState     uint64 // values drawn from the StreamShuffle stream of RandSeed to order training records
+	Trained          uint64
 }
 
-type ActivationType int
-
-const (
-	ActivationTypeNone ActivationType = iota
-	ActivationTypeSigmoid
-	ActivationTypeTanh
-)
-
 // Network struct.
 type Network struct {
-	cfg                        Config
-	activation                 activationFunc
-	activationMatrixDerivative activationMatrixDerivativeFunc
-	weights                    []*mat.Dense // hidden and output layers
+	cfg       Config
+	layers    []Layer
+	loss      Loss
+	optimizer Optimizer
+	schedule  Schedule
+	position  ScheduleState
+	source    *CountingSource // draws dropout masks during training, created on first use at cfg.RandState
+	states    [][]*mat.Dense  // hidden state of each recurrent layer carried between PredictStateful calls
 }
 
-// NewRandom constructs a new network with random weights from a config.
+// NewRandom constructs a new network with weights initialized from a config.
+// When the config has a layer spec, each dense and convolution layer is followed by its activation.
 func NewRandom(cfg Config) (*Network, error) {
-	src := Rand{cfg.RandSeed, cfg.RandState}.GetSource()
+	src := Rand{cfg.RandSeed, cfg.RandState}.CountingSource()
+	if cfg.LayerSpec != "" {
+		return newRandomFromSpec(cfg, src)
+	}
 	weights := make([]*mat.Dense, 0, len(cfg.LayerCounts))
 	count := cfg.InputCount
 	for _, nextCount := range cfg.LayerCounts {
-		next, err := matutil.New(nextCount, count, matutil.RandomArray(nextCount*count, float64(count), matutil.OptRandomArraySource(src)))
+		next, err := initWeights(cfg.Init, cfg.InitValue, nextCount, count, src)
 		if err != nil {
-			return nil, fmt.Errorf("creating random matrix with %d rows and %d columns: %v", nextCount, count, err)
+			return nil, fmt.Errorf("initializing weights with %d rows and %d columns: %v", nextCount, count, err)
 		}
 		weights = append(weights, next)
 		count = nextCount
 	}
-	return New(cfg, weights)
+	cfg.RandState = src.state
+	return NewWithBiases(cfg, weights, zeroBiases(cfg.LayerCounts))
+}
+
+func newRandomFromSpec(cfg Config, src *CountingSource) (*Network, error) {
+	if len(cfg.LayerCounts) > 0 || len(cfg.Dropout) > 0 || len(cfg.Normalization) > 0 {
+		return nil, fmt.Errorf("layer counts, dropout and normalization cannot be configured with a layer spec")
+	}
+	specs, err := ParseLayerSpec(cfg.LayerSpec)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := specLayers(cfg, specs, src)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RandState = src.state
+	return NewFromLayers(cfg, layers)
 }
 
-// New constructs a new network with the specified layer weights.
+// New constructs a new network with the specified layer weights and zero biases.
 func New(cfg Config, weights []*mat.Dense) (*Network, error) {
+	return NewWithBiases(cfg, weights, zeroBiases(cfg.LayerCounts))
+}
+
+// NewWithBiases constructs a new network with the specified layer weights and biases.
+// Each configured layer becomes a stack of a dense layer, its normalization if any, its activation and its dropout if any.
+func NewWithBiases(cfg Config, weights, biases []*mat.Dense) (*Network, error) {
 	if len(weights) != len(cfg.LayerCounts) {
 		return nil, fmt.Errorf("layer weight count '%d' must be equal configured layer count '%d'", len(weights), len(cfg.LayerCounts))
 	}
@@ -66,40 +117,118 @@ func New(cfg Config, weights []*mat.Dense) (*Network, error) {
 		}
 		previousCount = currentCount
 	}
-	af, amdf, err := newActivationFuncs(cfg)
+	if len(biases) != len(cfg.LayerCounts) {
+		return nil, fmt.Errorf("layer bias count '%d' must be equal configured layer count '%d'", len(biases), len(cfg.LayerCounts))
+	}
+	for i, bias := range biases {
+		rc, cc := bias.Dims()
+		if rc != cfg.LayerCounts[i] || cc != 1 {
+			return nil, fmt.Errorf("layer %d bias dimensions %dx%d must be %dx1", i, rc, cc, cfg.LayerCounts[i])
+		}
+	}
+	cfg.Activations = layerActivationTypes(cfg)
+	layers, err := configLayers(cfg, weights, biases)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromLayers(cfg, layers)
+}
+
+// NewFromLayers constructs a new network from a stack of layers, the config provides the loss and training settings.
+// Layer counts, activations, dropout and normalization in the config are only used by the other constructors.
+// When the config has an input count, the layers are checked to accept it.
+func NewFromLayers(cfg Config, layers []Layer) (*Network, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("network must have at least one layer")
+	}
+	loss, err := newLoss(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.L1 < 0 || cfg.L2 < 0 || cfg.WeightDecay < 0 {
+		return nil, fmt.Errorf("regularization coefficients cannot be negative, got L1 %v, L2 %v and weight decay %v", cfg.L1, cfg.L2, cfg.WeightDecay)
+	}
+	if cfg.BPTTSteps < 0 {
+		return nil, fmt.Errorf("backpropagation through time steps cannot be negative, got %d", cfg.BPTTSteps)
+	}
+	optimizer, err := NewOptimizer(cfg.Optimizer)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := NewSchedule(cfg.Schedule)
 	if err != nil {
 		return nil, err
 	}
+	outputCount := 0
+	if cfg.InputCount > 0 {
+		outputs, _, err := propagateForwards(mat.NewDense(cfg.InputCount, 1, nil), layers, Pass{})
+		if err != nil {
+			return nil, fmt.Errorf("layers must accept %d inputs: %v", cfg.InputCount, err)
+		}
+		outputCount, _ = outputs.Dims()
+	}
+	if err := validateTask(cfg, layers, outputCount); err != nil {
+		return nil, err
+	}
+	if err := validatePreprocessing(cfg.Preprocessing, cfg.InputCount, outputCount); err != nil {
+		return nil, err
+	}
 	return &Network{
-		cfg:                        cfg,
-		activation:                 af,
-		activationMatrixDerivative: amdf,
-		weights:                    weights,
+		cfg:       cfg,
+		layers:    layers,
+		loss:      loss,
+		optimizer: optimizer,
+		schedule:  schedule,
 	}, nil
 }
 
-type activationFunc func(_, _ int, v float64) float64
-type activationMatrixDerivativeFunc func(outputs mat.Matrix) (*mat.Dense, error)
-
-type Activation interface {
-	Value(float64) float64
-	MatrixDerivative(outputs mat.Matrix) (*mat.Dense, error)
+// configLayers creates the layer stack described by a config from the weights and biases of each dense layer.
+func configLayers(cfg Config, weights, biases []*mat.Dense) ([]Layer, error) {
+	if len(cfg.Activations) != len(cfg.LayerCounts) {
+		return nil, fmt.Errorf("layer activation count '%d' must be equal configured layer count '%d'", len(cfg.Activations), len(cfg.LayerCounts))
+	}
+	if err := validateDropout(cfg.Dropout, len(cfg.LayerCounts)); err != nil {
+		return nil, err
+	}
+	if len(cfg.Normalization) > 0 && len(cfg.Normalization) != len(cfg.LayerCounts)-1 {
+		return nil, fmt.Errorf("normalization count %d must equal hidden layer count %d", len(cfg.Normalization), len(cfg.LayerCounts)-1)
+	}
+	layers := make([]Layer, 0, 2*len(cfg.LayerCounts))
+	for i, count := range cfg.LayerCounts {
+		dense, err := NewDenseLayer(weights[i], biases[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %v", i, err)
+		}
+		layers = append(layers, dense)
+		if i < len(cfg.Normalization) && cfg.Normalization[i] != NormTypeNone {
+			norm, err := NewNormLayer(cfg.Normalization[i], count, cfg.NormEpsilon, cfg.NormMomentum)
+			if err != nil {
+				return nil, fmt.Errorf("layer %d: %v", i, err)
+			}
+			layers = append(layers, norm)
+		}
+		a, err := newActivationLayer(cfg, cfg.Activations[i], i == len(cfg.LayerCounts)-1)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %v", i, err)
+		}
+		layers = append(layers, a)
+		if i < len(cfg.Dropout) && cfg.Dropout[i] > 0 {
+			d, err := NewDropoutLayer(cfg.Dropout[i])
+			if err != nil {
+				return nil, fmt.Errorf("layer %d: %v", i, err)
+			}
+			layers = append(layers, d)
+		}
+	}
+	return layers, nil
 }
 
-func newActivationFuncs(cfg Config) (activationFunc, activationMatrixDerivativeFunc, error) {
-	var a Activation
-	switch cfg.Activation {
-	case ActivationTypeNone:
-		fallthrough
-	case ActivationTypeSigmoid:
-		a = activation.Sigmoid{}
-	case ActivationTypeTanh:
-		a = activation.Tanh{}
-	default:
-		return nil, nil, fmt.Errorf("unknown activation type %v", cfg.Activation)
+func zeroBiases(layerCounts []int) []*mat.Dense {
+	biases := make([]*mat.Dense, 0, len(layerCounts))
+	for _, count := range layerCounts {
+		biases = append(biases, mat.NewDense(count, 1, nil))
 	}
-
-	return func(_, _ int, v float64) float64 { return a.Value(v) }, a.MatrixDerivative, nil
+	return biases
 }
 
 // Config gets the networks configuration.
@@ -107,17 +236,49 @@ func (n Network) Config() Config {
 	return n.cfg
 }
 
+// Layers returns the network's stack of layers.
+func (n Network) Layers() []Layer {
+	return append([]Layer(nil), n.layers...)
+}
+
+// SetLayers replaces the network's stack of layers, like one returned by Layers at an earlier epoch,
+// with layers of the same types and param dimensions. Training progress, the learning rate schedule position
+// and the optimizer state are kept.
+func (n *Network) SetLayers(layers []Layer) error {
+	if len(layers) != len(n.layers) {
+		return fmt.Errorf("layer count %d must equal the network layer count %d", len(layers), len(n.layers))
+	}
+	for i, l := range layers {
+		if l.Type() != n.layers[i].Type() {
+			return fmt.Errorf("layer %d type %s must equal the network layer type %s", i, l.Type(), n.layers[i].Type())
+		}
+		params, current := l.Params(), n.layers[i].Params()
+		if len(params) != len(current) {
+			return fmt.Errorf("layer %d has %d params, the network layer has %d", i, len(params), len(current))
+		}
+		for j, p := range params {
+			r, c := p.Value.Dims()
+			cr, cc := current[j].Value.Dims()
+			if r != cr || c != cc {
+				return fmt.Errorf("layer %d param %d dimensions %dx%d must equal the network dimensions %dx%d", i, j, r, c, cr, cc)
+			}
+		}
+	}
+	n.layers = append([]Layer(nil), layers...)
+	return nil
+}
+
 // Predict outputs from a trained network.
 func (n Network) Predict(inputData []float64) (*mat.Dense, error) {
 	inputs, err := matutil.FromVector(inputData)
 	if err != nil {
 		return nil, fmt.Errorf("creating matrix from input data: %v", err)
 	}
-	outputs, err := propagateForwards(inputs, n.weights, n.activation)
+	outputs, _, err := propagateForwards(inputs, n.layers, Pass{})
 	if err != nil {
 		return nil, err
 	}
-	return outputs[len(outputs)-1], nil
+	return n.unscale(outputs)
 }
 
 // Train the network with a single set of inputs and target outputs.
@@ -130,111 +291,222 @@ func (n *Network) Train(input []float64, target []float64) error {
 	if err != nil {
 		return fmt.Errorf("creating target matrix: %v", err)
 	}
+	_, err = n.train(inputs, targets)
+	return err
+}
 
-	layerOutputs, err := propagateForwards(inputs, n.weights, n.activation)
-	if err != nil {
-		return err
+// TrainBatch trains the network with a batch of inputs and target outputs, applying a single update averaged across the batch.
+// Returns the mean loss of the batch before the update, including any L1 and L2 weight penalties.
+func (n *Network) TrainBatch(inputs, targets [][]float64) (float64, error) {
+	if len(inputs) != len(targets) {
+		return 0, fmt.Errorf("input batch size %d must equal target batch size %d", len(inputs), len(targets))
 	}
-	finalOutputs := layerOutputs[len(layerOutputs)-1]
-	errors, err := findErrors(targets, finalOutputs, n.weights)
+	inputMatrix, err := matutil.FromVectors(inputs)
 	if err != nil {
-		return fmt.Errorf("finding errors: %v", err)
+		return 0, fmt.Errorf("creating input matrix: %v", err)
 	}
-	n.weights, err = propagateBackwards(n.weights, errors, layerOutputs, inputs, n.cfg.Rate, n.activationMatrixDerivative)
+	targetMatrix, err := matutil.FromVectors(targets)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("creating target matrix: %v", err)
 	}
-
-	n.cfg.Trained++
-
-	return nil
-}
-
-// Trained returns the number of training runs.
-func (n Network) Trained() uint64 {
-	return n.cfg.Trained
+	return n.train(inputMatrix, targetMatrix)
 }
 
-func propagateBackwards(weights, errors, outputs []*mat.Dense, inputs mat.Matrix, rate float64, activationDer activationMatrixDerivativeFunc) ([]*mat.Dense, error) {
-	adjustedWeights := make([]*mat.Dense, len(weights))
-
-	var err error
-	for i := len(weights) - 1; i >= 1; i-- {
-		adjustedWeights[i], err = backward(outputs[i], errors[i], weights[i], outputs[i-1], rate, activationDer)
-		if err != nil {
-			return nil, err
+// Evaluate returns the mean loss of a batch of inputs against target outputs without training, excluding weight penalties,
+// and the outputs predicted for each input, one column per record.
+func (n Network) Evaluate(inputs, targets [][]float64) (float64, *mat.Dense, error) {
+	if len(inputs) != len(targets) {
+		return 0, nil, fmt.Errorf("input batch size %d must equal target batch size %d", len(inputs), len(targets))
+	}
+	inputMatrix, err := matutil.FromVectors(inputs)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating input matrix: %v", err)
+	}
+	targetMatrix, err := matutil.FromVectors(targets)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating target matrix: %v", err)
+	}
+	outputs, _, err := propagateForwards(inputMatrix, n.layers, Pass{})
+	if err != nil {
+		return 0, nil, err
+	}
+	if n.cfg.TargetScaling.enabled() {
+		if targetMatrix, err = n.cfg.TargetScaling.Transform(targetMatrix); err != nil {
+			return 0, nil, fmt.Errorf("scaling targets: %v", err)
 		}
 	}
-	adjustedWeights[0], err = backward(outputs[0], errors[0], weights[0], inputs, rate, activationDer)
+	loss, err := n.loss.Value(targetMatrix, outputs)
 	if err != nil {
-		return nil, err
+		return 0, nil, fmt.Errorf("computing loss: %v", err)
 	}
-
-	return adjustedWeights, nil
+	if outputs, err = n.unscale(outputs); err != nil {
+		return 0, nil, err
+	}
+	return loss, outputs, nil
 }
 
-func propagateForwards(inputs mat.Matrix, weights []*mat.Dense, activation activationFunc) ([]*mat.Dense, error) {
-	outputs := make([]*mat.Dense, 0, len(weights))
-	for _, weight := range weights {
-		layerOutput, err := forward(inputs, weight, activation)
-		if err != nil {
-			return nil, err
-		}
-		outputs = append(outputs, layerOutput)
-		inputs = layerOutput
+// train the network with a matrix of inputs and target outputs, one column per record, returning the mean loss.
+func (n *Network) train(inputs, targets *mat.Dense) (float64, error) {
+	loss, _, err := n.step(inputs, targets, 0, nil)
+	if err != nil {
+		return 0, err
 	}
-	return outputs, nil
+	_, batchSize := inputs.Dims()
+	n.cfg.Trained += uint64(batchSize)
+	return loss, nil
 }
 
-func backward(outputs, errors, weights, inputs mat.Matrix, learningRate float64, activationDer activationMatrixDerivativeFunc) (*mat.Dense, error) {
-	actDer, err := activationDer(outputs)
+// step applies a single training update from a matrix of inputs, with a number of time steps for sequence inputs,
+// starting each recurrent layer from its hidden state in states, if any. Targets are for every output column,
+// or for the columns of the last time step only. Returns the mean loss and the hidden state of each recurrent layer after the last step.
+func (n *Network) step(inputs, targets *mat.Dense, steps int, states [][]*mat.Dense) (float64, [][]*mat.Dense, error) {
+	if n.source == nil {
+		n.source = Rand{n.cfg.RandSeed, n.cfg.RandState}.CountingSource()
+	}
+	outputs, caches, finals, err := propagateSequence(inputs, n.layers, Pass{Training: true, Rand: rand.New(n.source), Steps: steps}, states)
+	n.cfg.RandState = n.source.state
+	if err != nil {
+		return 0, nil, err
+	}
+	scored, err := lastColumns(outputs, targets)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n.cfg.TargetScaling.enabled() {
+		if targets, err = n.cfg.TargetScaling.Transform(targets); err != nil {
+			return 0, nil, fmt.Errorf("scaling targets: %v", err)
+		}
+	}
+	loss, err := n.loss.Value(targets, scored)
 	if err != nil {
-		return nil, fmt.Errorf("applying activation derivative: %v", err)
+		return 0, nil, fmt.Errorf("computing loss: %v", err)
 	}
-	multiply, err := matutil.MulElem(errors, actDer)
+	params := n.params()
+	loss += penalty(n.cfg.L1, n.cfg.L2, params)
+	outputGrads, end, err := outputGradient(n.loss, n.layers, targets, scored)
 	if err != nil {
-		return nil, fmt.Errorf("applying errors to activation derivative: %v", err)
+		return 0, nil, fmt.Errorf("computing output gradient: %v", err)
 	}
-	dot, err := matutil.Dot(multiply, inputs.T())
+	grads, err := propagateBackwards(padColumns(outputGrads, outputs), n.layers[:end], caches[:end])
 	if err != nil {
-		return nil, fmt.Errorf("applying activated errors to inputs: %v", err)
+		return 0, nil, err
 	}
-	scale, err := matutil.Scale(learningRate, dot)
+	grads, err = regularize(n.cfg.L1, n.cfg.L2, params, grads)
 	if err != nil {
-		return nil, fmt.Errorf("scaling by learning rate: %v", err)
+		return 0, nil, err
 	}
-	adjusted, err := matutil.Add(weights, scale)
+	rate := n.Rate()
+	values, err := n.optimizer.Update(rate, paramValues(params), grads)
 	if err != nil {
-		return nil, fmt.Errorf("adding scaled corrections to weights: %v", err)
+		return 0, nil, fmt.Errorf("optimizing: %v", err)
 	}
-	return adjusted, nil
+	if values, err = decay(rate*n.cfg.WeightDecay, params, values); err != nil {
+		return 0, nil, err
+	}
+	if err := n.setParams(values); err != nil {
+		return 0, nil, err
+	}
+	if err := n.updateState(caches); err != nil {
+		return 0, nil, err
+	}
+	n.position.Step++
+
+	return loss, finals, nil
 }
 
-func findErrors(targets mat.Matrix, finalOutputs mat.Matrix, weights []*mat.Dense) ([]*mat.Dense, error) {
-	errors := make([]*mat.Dense, len(weights))
-	lastErrors, err := matutil.Sub(targets, finalOutputs)
-	if err != nil {
-		return nil, fmt.Errorf("subtracting target from final outputs: %v", err)
+// params returns the trainable parameters of every layer in stack order.
+func (n Network) params() []Param {
+	var params []Param
+	for _, l := range n.layers {
+		params = append(params, l.Params()...)
 	}
-	for i := len(weights) - 1; i >= 1; i-- {
-		errors[i] = lastErrors
-		lastErrors, err = matutil.Dot(weights[i].T(), lastErrors)
+	return params
+}
+
+// setParams replaces the trainable parameters of every layer in the same order returned by params.
+func (n *Network) setParams(values []*mat.Dense) error {
+	layers := make([]Layer, len(n.layers))
+	for i, l := range n.layers {
+		count := len(l.Params())
+		if count == 0 {
+			layers[i] = l
+			continue
+		}
+		if len(values) < count {
+			return fmt.Errorf("layer %d %s needs %d params, only %d left", i, l.Type(), count, len(values))
+		}
+		updated, err := l.WithParams(values[:count])
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("layer %d %s: %v", i, l.Type(), err)
 		}
+		layers[i] = updated
+		values = values[count:]
 	}
-	errors[0] = lastErrors
-	return errors, nil
+	n.layers = layers
+	return nil
 }
 
-func forward(inputs mat.Matrix, weights mat.Matrix, activation activationFunc) (*mat.Dense, error) {
-	rawOutputs, err := matutil.Dot(weights, inputs)
-	if err != nil {
-		return nil, fmt.Errorf("applying weights: %v", err)
+// updateState updates the non-trainable state of each stateful layer from the caches of a training forward pass.
+func (n *Network) updateState(caches []interface{}) error {
+	for i, l := range n.layers {
+		stateful, ok := l.(StatefulLayer)
+		if !ok {
+			continue
+		}
+		updated, err := stateful.Update(caches[i])
+		if err != nil {
+			return fmt.Errorf("layer %d %s: %v", i, l.Type(), err)
+		}
+		n.layers[i] = updated
 	}
-	outputs, err := matutil.Apply(activation, rawOutputs)
-	if err != nil {
-		return nil, fmt.Errorf("applying activation function: %v", err)
+	return nil
+}
+
+// Rate returns the learning rate for the next training step.
+func (n Network) Rate() float64 {
+	return n.schedule.Rate(n.cfg.Rate, n.position)
+}
+
+// EndEpoch advances the learning rate schedule to the next epoch, given the mean loss of the completed epoch.
+func (n *Network) EndEpoch(loss float64) {
+	n.position = n.schedule.Observe(n.position, loss)
+	n.position.Epoch++
+}
+
+// SetShuffleState records the number of values drawn from the shuffle stream, so training resumes the stream there.
+func (n *Network) SetShuffleState(state uint64) {
+	n.cfg.ShuffleState = state
+}
+
+// Epochs returns the number of completed training epochs.
+func (n Network) Epochs() uint64 {
+	return n.position.Epoch
+}
+
+// Trained returns the number of records the network has been trained on.
+func (n Network) Trained() uint64 {
+	return n.cfg.Trained
+}
+
+// outputGradient computes the loss gradient that backpropagation starts from, and the number of layers to propagate it through.
+// Cross-entropy losses following their matching output activation layer simplify to outputs - targets
+// for the activation inputs, which is computed directly for numerical stability.
+func outputGradient(l Loss, layers []Layer, targets, outputs *mat.Dense) (*mat.Dense, int, error) {
+	last := len(layers) - 1
+	if a, ok := layers[last].(activationLayer); ok {
+		switch l.(type) {
+		case loss.CategoricalCrossEntropy:
+			if _, ok := a.a.(activation.Softmax); ok {
+				grads, err := matutil.Sub(outputs, targets)
+				return grads, last, err
+			}
+		case loss.BinaryCrossEntropy:
+			if _, ok := a.a.(activation.Sigmoid); ok {
+				grads, err := matutil.Sub(outputs, targets)
+				return grads, last, err
+			}
+		}
 	}
-	return outputs, nil
+	grads, err := l.Derivative(targets, outputs)
+	return grads, len(layers), err
 }
@@ -1,6 +1,7 @@
 package network_test
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
@@ -321,7 +322,7 @@ func TestNetwork_PredictFromTrained(t *testing.T) {
 			args: args{
 				inputData: []float64{1, 2, 3},
 			},
-			want: []float64{0.6724294088734996},
+			want: []float64{0.6812244798954212},
 		},
 		{
 			name: "should successfully output an expected value from a 4-layer network trained by a single record",
@@ -343,7 +344,7 @@ func TestNetwork_PredictFromTrained(t *testing.T) {
 			args: args{
 				inputData: []float64{1, 2, 3},
 			},
-			want: []float64{0.45405359950545143},
+			want: []float64{0.48638033147186993},
 		},
 	}
 	for _, tt := range tests {
@@ -370,3 +371,336 @@ func TestNetwork_PredictFromTrained(t *testing.T) {
 		})
 	}
 }
+
+func TestNetwork_TrainBatch(t *testing.T) {
+	type args struct {
+		inputs  [][]float64
+		targets [][]float64
+	}
+	newNetwork := func() *network.Network {
+		n, err := network.NewRandom(network.Config{
+			InputCount:  3,
+			LayerCounts: []int{2, 1},
+			Rate:        0.1,
+			RandSeed:    0,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		name             string
+		n                *network.Network
+		args             args
+		wantErr          bool
+		wantTrainedCount uint64
+	}{
+		{
+			name:    "should error due to empty batch",
+			n:       newNetwork(),
+			args:    args{},
+			wantErr: true,
+		},
+		{
+			name: "should error due to mismatched input and target batch sizes",
+			n:    newNetwork(),
+			args: args{
+				inputs:  [][]float64{{1, 2, 3}, {3, 2, 1}},
+				targets: [][]float64{{1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error due to inconsistent input lengths",
+			n:    newNetwork(),
+			args: args{
+				inputs:  [][]float64{{1, 2, 3}, {3, 2}},
+				targets: [][]float64{{1}, {0}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error due to input data length not matching input count",
+			n:    newNetwork(),
+			args: args{
+				inputs:  [][]float64{{1, 2}, {3, 2}},
+				targets: [][]float64{{1}, {0}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should successfully train a batch of 3 records",
+			n:    newNetwork(),
+			args: args{
+				inputs:  [][]float64{{1, 2, 3}, {3, 2, 1}, {0, 0, 1}},
+				targets: [][]float64{{1}, {0}, {1}},
+			},
+			wantTrainedCount: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.n.TrainBatch(tt.args.inputs, tt.args.targets); (err != nil) != tt.wantErr {
+				t.Errorf("TrainBatch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.n.Trained() != tt.wantTrainedCount {
+				t.Errorf("TrainBatch() trained count = %v, wantTrainedCount %v", tt.n.Trained(), tt.wantTrainedCount)
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainBatchMatchesTrain(t *testing.T) {
+	cfg := network.Config{
+		InputCount:  3,
+		LayerCounts: []int{2, 2, 1},
+		Rate:        1,
+		RandSeed:    0,
+	}
+	single, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batched, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := single.Train([]float64{1, 2, 3}, []float64{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batched.TrainBatch([][]float64{{1, 2, 3}}, [][]float64{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := single.Predict([]float64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := batched.Predict([]float64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TrainBatch() with 1 record predicts %v, Train() predicts %v", got, want)
+	}
+}
+
+func TestNetwork_Evaluate(t *testing.T) {
+	inputs := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	targets := [][]float64{{1, 0}, {0, 1}, {1, 1}}
+	cfg := network.Config{InputCount: 3, LayerCounts: []int{4, 2}, Rate: 1, RandSeed: 2}
+	n, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trained, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loss, outputs, err := n.Evaluate(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := trained.TrainBatch(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(loss-want) > 1e-12 {
+		t.Errorf("Evaluate() loss = %v, want TrainBatch() loss %v", loss, want)
+	}
+	for j, in := range inputs {
+		predicted, err := n.Predict(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if outputs.At(i, j) != predicted.At(i, 0) {
+				t.Errorf("Evaluate() output %d of record %d = %v, want %v", i, j, outputs.At(i, j), predicted.At(i, 0))
+			}
+		}
+	}
+	if n.Trained() != 0 {
+		t.Errorf("Evaluate() trained %d records, want 0", n.Trained())
+	}
+	if _, _, err := n.Evaluate(inputs, targets[:2]); err == nil {
+		t.Errorf("Evaluate() with mismatched batch sizes error = nil, want error")
+	}
+}
+
+func TestNetwork_SetLayers(t *testing.T) {
+	inputs := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	targets := [][]float64{{1, 0}, {0, 1}, {1, 1}}
+	n, err := network.NewRandom(network.Config{InputCount: 3, LayerCounts: []int{4, 2}, Rate: 1, RandSeed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.TrainBatch(inputs, targets); err != nil {
+		t.Fatal(err)
+	}
+	n.EndEpoch(0)
+	best := n.Layers()
+	want, _, err := n.Evaluate(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.TrainBatch(inputs, targets); err != nil {
+		t.Fatal(err)
+	}
+	n.EndEpoch(0)
+	if err := n.SetLayers(best); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := n.Evaluate(inputs, targets); err != nil || got != want {
+		t.Errorf("Evaluate() after SetLayers() = %v, %v, want the loss %v of the earlier layers", got, err, want)
+	}
+	if n.Trained() != 6 || n.Epochs() != 2 {
+		t.Errorf("SetLayers() trained %d records over %d epochs, want 6 over 2", n.Trained(), n.Epochs())
+	}
+
+	other, err := network.NewRandom(network.Config{InputCount: 3, LayerCounts: []int{5, 2}, RandSeed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := append([]network.Layer(nil), best...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	tests := []struct {
+		name   string
+		layers []network.Layer
+	}{
+		{name: "fewer layers", layers: best[:1]},
+		{name: "different param dimensions", layers: other.Layers()},
+		{name: "different layer types", layers: swapped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := n.SetLayers(tt.layers); err == nil {
+				t.Errorf("SetLayers() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainBatchLoss(t *testing.T) {
+	inputs := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	targets := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	tests := []struct {
+		name string
+		cfg  network.Config
+	}{
+		{
+			name: "should reduce mse loss",
+			cfg:  network.Config{InputCount: 3, LayerCounts: []int{4, 3}, Rate: 1},
+		},
+		{
+			name: "should reduce binary cross-entropy loss with a sigmoid output",
+			cfg:  network.Config{InputCount: 3, LayerCounts: []int{4, 3}, Rate: 1, Loss: network.LossTypeBinaryCrossEntropy},
+		},
+		{
+			name: "should reduce categorical cross-entropy loss with a softmax output",
+			cfg:  network.Config{InputCount: 3, LayerCounts: []int{4, 3}, Rate: 1, Activations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSoftmax}, Loss: network.LossTypeCategoricalCrossEntropy},
+		},
+		{
+			name: "should reduce mse loss with a softmax output",
+			cfg:  network.Config{InputCount: 3, LayerCounts: []int{4, 3}, Rate: 1, OutputActivation: network.ActivationTypeSoftmax},
+		},
+		{
+			name: "should reduce huber loss",
+			cfg:  network.Config{InputCount: 3, LayerCounts: []int{4, 3}, Rate: 1, Activations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSigmoid}, Loss: network.LossTypeHuber},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := network.NewRandom(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := n.TrainBatch(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := first
+			for i := 0; i < 100; i++ {
+				if last, err = n.TrainBatch(inputs, targets); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last >= first {
+				t.Errorf("TrainBatch() loss after training = %v, want less than initial loss %v", last, first)
+			}
+		})
+	}
+}
+
+func TestNetwork_PredictSoftmax(t *testing.T) {
+	n, err := network.NewRandom(network.Config{
+		InputCount:  3,
+		LayerCounts: []int{4, 3},
+		Activations: []network.ActivationType{network.ActivationTypeSigmoid, network.ActivationTypeSoftmax},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := predictVector(t, n, []float64{1, 2, 3})
+	sum := 0.0
+	for _, v := range got {
+		sum += v
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("Predict() = %v sums to %v, want 1", got, sum)
+	}
+}
+
+func TestNewRandom_Activations(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             network.Config
+		wantActivations []network.ActivationType
+		wantErr         bool
+	}{
+		{
+			name:            "should default every layer to the legacy sigmoid activation",
+			cfg:             network.Config{InputCount: 3, LayerCounts: []int{2, 1}},
+			wantActivations: []network.ActivationType{network.ActivationTypeNone, network.ActivationTypeNone},
+		},
+		{
+			name:            "should apply a single legacy activation to every layer",
+			cfg:             network.Config{InputCount: 3, LayerCounts: []int{2, 2, 1}, Activation: network.ActivationTypeTanh},
+			wantActivations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeTanh, network.ActivationTypeTanh},
+		},
+		{
+			name:            "should apply a legacy output activation to the last layer",
+			cfg:             network.Config{InputCount: 3, LayerCounts: []int{2, 3}, Activation: network.ActivationTypeTanh, OutputActivation: network.ActivationTypeSoftmax},
+			wantActivations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSoftmax},
+		},
+		{
+			name:            "should use per-layer activations",
+			cfg:             network.Config{InputCount: 3, LayerCounts: []int{2, 3}, Activations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSoftmax}},
+			wantActivations: []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeSoftmax},
+		},
+		{
+			name:    "should error when per-layer activations don't match the layer count",
+			cfg:     network.Config{InputCount: 3, LayerCounts: []int{2, 3}, Activations: []network.ActivationType{network.ActivationTypeTanh}},
+			wantErr: true,
+		},
+		{
+			name:    "should error on an unknown activation",
+			cfg:     network.Config{InputCount: 3, LayerCounts: []int{2, 3}, Activations: []network.ActivationType{network.ActivationTypeTanh, "unknown"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := network.NewRandom(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRandom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := n.Config().Activations; !reflect.DeepEqual(got, tt.wantActivations) {
+				t.Errorf("NewRandom() activations = %v, want %v", got, tt.wantActivations)
+			}
+		})
+	}
+}
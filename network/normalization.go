@@ -0,0 +1,283 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// NormType selects how a hidden layer normalizes its weighted inputs before activation.
+type NormType string
+
+const (
+	NormTypeNone  NormType = ""
+	NormTypeBatch NormType = "batch"
+	NormTypeLayer NormType = "layer"
+)
+
+// NormTypes lists all normalization types.
+var NormTypes = []NormType{
+	NormTypeBatch,
+	NormTypeLayer,
+}
+
+// normLayer standardizes its inputs, then applies a learnable scale and shift for each unit.
+// Batch normalization standardizes each unit over the records in a training batch and keeps running statistics for inference,
+// layer normalization standardizes each record over the units in the layer.
+type normLayer struct {
+	t        NormType
+	epsilon  float64
+	momentum float64
+	gamma    *mat.Dense // scale for each unit
+	beta     *mat.Dense // shift for each unit
+	mean     *mat.Dense // running mean of each unit for batch normalization inference
+	variance *mat.Dense // running variance of each unit for batch normalization inference
+}
+
+type normLayerConfig struct {
+	Type     NormType
+	Epsilon  float64
+	Momentum float64
+}
+
+// normCache holds the values from a normalization forward pass needed for backpropagation.
+type normCache struct {
+	normalized *mat.Dense // standardized inputs before scale and shift
+	invStd     []float64  // inverse standard deviation of each unit for batch normalization, or each record for layer normalization
+	mean       []float64  // batch mean of each unit, batch normalization only
+	variance   []float64  // batch variance of each unit, batch normalization only
+}
+
+// NewNormLayer creates an identity normalization layer for a number of units.
+// Epsilon defaults to 1e-5 when zero. The batch normalization running statistics keep a momentum fraction of their value
+// at each training step, so a zero momentum replaces them with the statistics of the last batch.
+func NewNormLayer(t NormType, units int, epsilon, momentum float64) (Layer, error) {
+	if epsilon == 0 {
+		epsilon = 1e-5
+	}
+	if epsilon < 0 || momentum < 0 || momentum >= 1 {
+		return nil, fmt.Errorf("normalization epsilon %v must be positive and momentum %v must be at least 0 and less than 1", epsilon, momentum)
+	}
+	if t != NormTypeBatch && t != NormTypeLayer {
+		return nil, fmt.Errorf("unknown normalization type '%s'", t)
+	}
+	if units < 1 {
+		return nil, fmt.Errorf("normalization unit count %d must be at least 1", units)
+	}
+	return &normLayer{
+		t:        t,
+		epsilon:  epsilon,
+		momentum: momentum,
+		gamma:    mat.NewDense(units, 1, matutil.FillArray(units, 1)),
+		beta:     mat.NewDense(units, 1, nil),
+		mean:     mat.NewDense(units, 1, nil),
+		variance: mat.NewDense(units, 1, matutil.FillArray(units, 1)),
+	}, nil
+}
+
+func decodeNormLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	var cfg normLayerConfig
+	if err := unmarshalLayerConfig(LayerTypeNormalization, config, &cfg, params, state, 2, 2); err != nil {
+		return nil, err
+	}
+	units, _ := params[0].Dims()
+	l, err := NewNormLayer(cfg.Type, units, cfg.Epsilon, cfg.Momentum)
+	if err != nil {
+		return nil, err
+	}
+	return l.(*normLayer).restore(params[0], params[1], state[0], state[1])
+}
+
+// restore returns a copy of the layer with stored params and running statistics.
+func (l *normLayer) restore(gamma, beta, mean, variance *mat.Dense) (*normLayer, error) {
+	units, _ := l.gamma.Dims()
+	for _, m := range []*mat.Dense{gamma, beta, mean, variance} {
+		if m == nil {
+			return nil, fmt.Errorf("normalization params and statistics cannot be nil")
+		}
+		if rc, cc := m.Dims(); rc != units || cc != 1 {
+			return nil, fmt.Errorf("normalization dimensions %dx%d must be %dx1", rc, cc, units)
+		}
+	}
+	restored := *l
+	restored.gamma, restored.beta, restored.mean, restored.variance = gamma, beta, mean, variance
+	return &restored, nil
+}
+
+func (l *normLayer) Type() LayerType {
+	return LayerTypeNormalization
+}
+
+func (l *normLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	return l.forward(inputs, pass.Training)
+}
+
+func (l *normLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	c, ok := cache.(*normCache)
+	if !ok {
+		return nil, nil, fmt.Errorf("normalization layer cache must be from a forward pass")
+	}
+	_, batchSize := grads.Dims()
+	inputGrads, gammaGrad, betaGrad, err := l.backward(grads, c, batchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inputGrads, []*mat.Dense{gammaGrad, betaGrad}, nil
+}
+
+func (l *normLayer) Params() []Param {
+	return []Param{{Value: l.gamma}, {Value: l.beta}}
+}
+
+func (l *normLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 2 {
+		return nil, fmt.Errorf("normalization layer must have 2 params, got %d", len(params))
+	}
+	return l.restore(params[0], params[1], l.mean, l.variance)
+}
+
+// Update returns a copy of the layer with its running statistics updated from a training forward pass.
+func (l *normLayer) Update(cache interface{}) (Layer, error) {
+	c, ok := cache.(*normCache)
+	if !ok {
+		return nil, fmt.Errorf("normalization layer cache must be from a forward pass")
+	}
+	return l.withStatistics(c), nil
+}
+
+func (l *normLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := marshalLayerConfig(normLayerConfig{Type: l.t, Epsilon: l.epsilon, Momentum: l.momentum})
+	return config, []*mat.Dense{l.mean, l.variance}, err
+}
+
+// forward normalizes weighted inputs, using batch statistics while training and running statistics otherwise for batch normalization.
+func (l *normLayer) forward(weighted *mat.Dense, training bool) (*mat.Dense, *normCache, error) {
+	rows, cols := weighted.Dims()
+	if gr, _ := l.gamma.Dims(); gr != rows {
+		return nil, nil, fmt.Errorf("normalization unit count %d must equal weighted input rows %d", gr, rows)
+	}
+	normalized := mat.NewDense(rows, cols, nil)
+	cache := &normCache{normalized: normalized}
+	switch {
+	case l.t == NormTypeLayer:
+		cache.invStd = make([]float64, cols)
+		for j := 0; j < cols; j++ {
+			col := mat.Col(nil, j, weighted)
+			mean, variance := meanVariance(col)
+			cache.invStd[j] = 1 / math.Sqrt(variance+l.epsilon)
+			for i, v := range col {
+				normalized.Set(i, j, (v-mean)*cache.invStd[j])
+			}
+		}
+	case training:
+		if cols < 2 {
+			return nil, nil, fmt.Errorf("batch normalization needs a training batch of at least 2 records, got %d", cols)
+		}
+		cache.invStd = make([]float64, rows)
+		cache.mean = make([]float64, rows)
+		cache.variance = make([]float64, rows)
+		for i := 0; i < rows; i++ {
+			row := weighted.RawRowView(i)
+			cache.mean[i], cache.variance[i] = meanVariance(row)
+			cache.invStd[i] = 1 / math.Sqrt(cache.variance[i]+l.epsilon)
+			for j, v := range row {
+				normalized.Set(i, j, (v-cache.mean[i])*cache.invStd[i])
+			}
+		}
+	default:
+		normalized.Apply(func(i, _ int, v float64) float64 {
+			return (v - l.mean.At(i, 0)) / math.Sqrt(l.variance.At(i, 0)+l.epsilon)
+		}, weighted)
+	}
+	outputs := mat.NewDense(rows, cols, nil)
+	outputs.Apply(func(i, _ int, v float64) float64 {
+		return l.gamma.At(i, 0)*v + l.beta.At(i, 0)
+	}, normalized)
+	return outputs, cache, nil
+}
+
+// backward propagates the loss gradient of a training forward pass's outputs back to its weighted inputs,
+// and returns the scale and shift gradients averaged over the batch.
+func (l *normLayer) backward(grads *mat.Dense, cache *normCache, batchSize int) (*mat.Dense, *mat.Dense, *mat.Dense, error) {
+	rows, cols := grads.Dims()
+	if nr, nc := cache.normalized.Dims(); nr != rows || nc != cols {
+		return nil, nil, nil, fmt.Errorf("normalization gradient dimensions %dx%d must equal forward pass dimensions %dx%d", rows, cols, nr, nc)
+	}
+	scale := 1 / float64(batchSize)
+	gammaGrad := mat.NewDense(rows, 1, nil)
+	betaGrad := mat.NewDense(rows, 1, nil)
+	normalizedGrads := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		var gammaSum, betaSum float64
+		for j := 0; j < cols; j++ {
+			g := grads.At(i, j)
+			gammaSum += g * cache.normalized.At(i, j)
+			betaSum += g
+			normalizedGrads.Set(i, j, g*l.gamma.At(i, 0))
+		}
+		gammaGrad.Set(i, 0, gammaSum*scale)
+		betaGrad.Set(i, 0, betaSum*scale)
+	}
+
+	// standardizing couples the values in each group of n units or records:
+	// dx = invStd / n * (n * dxhat - sum(dxhat) - xhat * sum(dxhat * xhat))
+	inputGrads := mat.NewDense(rows, cols, nil)
+	if l.t == NormTypeLayer {
+		for j := 0; j < cols; j++ {
+			inputGrads.SetCol(j, standardizeGradient(mat.Col(nil, j, normalizedGrads), mat.Col(nil, j, cache.normalized), cache.invStd[j]))
+		}
+	} else {
+		for i := 0; i < rows; i++ {
+			inputGrads.SetRow(i, standardizeGradient(normalizedGrads.RawRowView(i), cache.normalized.RawRowView(i), cache.invStd[i]))
+		}
+	}
+	return inputGrads, gammaGrad, betaGrad, nil
+}
+
+// standardizeGradient returns the input gradients of one standardized group from the gradients of its standardized values.
+func standardizeGradient(grads, normalized []float64, invStd float64) []float64 {
+	var sum, dotSum float64
+	for k, g := range grads {
+		sum += g
+		dotSum += g * normalized[k]
+	}
+	n := float64(len(grads))
+	inputGrads := make([]float64, len(grads))
+	for k, g := range grads {
+		inputGrads[k] = invStd / n * (n*g - sum - normalized[k]*dotSum)
+	}
+	return inputGrads
+}
+
+// withStatistics returns the normalization with its running statistics updated from a training forward pass.
+func (l *normLayer) withStatistics(cache *normCache) *normLayer {
+	if l.t != NormTypeBatch || cache.mean == nil {
+		return l
+	}
+	updated := *l
+	updated.mean = mat.NewDense(len(cache.mean), 1, nil)
+	updated.variance = mat.NewDense(len(cache.variance), 1, nil)
+	for i := range cache.mean {
+		updated.mean.Set(i, 0, l.momentum*l.mean.At(i, 0)+(1-l.momentum)*cache.mean[i])
+		updated.variance.Set(i, 0, l.momentum*l.variance.At(i, 0)+(1-l.momentum)*cache.variance[i])
+	}
+	return &updated
+}
+
+// meanVariance returns the mean and biased variance of values.
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values))
+}
@@ -0,0 +1,164 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func TestNewRandom_Normalization(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     network.Config
+		wantErr bool
+	}{
+		{name: "should allow no normalization"},
+		{name: "should allow a type for each hidden layer", cfg: network.Config{Normalization: []network.NormType{network.NormTypeBatch, network.NormTypeNone}}},
+		{name: "should error on a missing hidden layer type", cfg: network.Config{Normalization: []network.NormType{network.NormTypeLayer}}, wantErr: true},
+		{name: "should error on an unknown type", cfg: network.Config{Normalization: []network.NormType{"unknown", network.NormTypeNone}}, wantErr: true},
+		{name: "should error on a momentum of 1", cfg: network.Config{Normalization: []network.NormType{network.NormTypeBatch, network.NormTypeBatch}, NormMomentum: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.InputCount = 3
+			cfg.LayerCounts = []int{4, 4, 2}
+			_, err := network.NewRandom(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRandom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainBatchNormalization(t *testing.T) {
+	const epsilon = 1e-5
+	tests := []struct {
+		name     string
+		momentum float64
+		want     float64
+	}{
+		{
+			name:     "should move running statistics 10% of the way toward the batch statistics with a momentum of 0.9",
+			momentum: 0.9,
+			want:     0.5 * (0.5 - 0.1) / math.Sqrt(0.925+epsilon),
+		},
+		{
+			name:     "should replace running statistics with the batch statistics with a momentum of 0",
+			momentum: 0,
+			want:     0.5 * (0.5 - 1) / math.Sqrt(0.25+epsilon),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := network.NewRandom(network.Config{
+				InputCount:    1,
+				LayerCounts:   []int{1, 1},
+				Activations:   []network.ActivationType{network.ActivationTypeLinear, network.ActivationTypeLinear},
+				Normalization: []network.NormType{network.NormTypeBatch},
+				NormMomentum:  tt.momentum,
+				Init:          network.InitTypeConstant,
+				InitValue:     0.5,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := predictVector(t, n, []float64{1})[0], 0.5*0.5/math.Sqrt(1+epsilon); math.Abs(got-want) > 1e-12 {
+				t.Errorf("Predict() with initial running statistics = %v, want %v", got, want)
+			}
+
+			// hidden weighted inputs 0.5 and 1.5 have batch mean 1 and variance 0.25
+			loss, err := n.TrainBatch([][]float64{{1}, {3}}, [][]float64{{0}, {0}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalized := 0.5 / math.Sqrt(0.25+epsilon)
+			if want := 0.5 * 0.25 * normalized * normalized; math.Abs(loss-want) > 1e-12 {
+				t.Errorf("TrainBatch() loss = %v, want %v from batch statistics", loss, want)
+			}
+
+			// other parameters are unchanged at a zero rate
+			if got := predictVector(t, n, []float64{1})[0]; math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Predict() with updated running statistics = %v, want %v", got, tt.want)
+			}
+
+			// a single record has no batch statistics to normalize by
+			if _, err := n.TrainBatch([][]float64{{1}}, [][]float64{{0}}); err == nil {
+				t.Errorf("TrainBatch() of a single record error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNetwork_PredictLayerNormalization(t *testing.T) {
+	n, err := network.NewRandom(network.Config{
+		InputCount:    3,
+		LayerCounts:   []int{4, 2},
+		Normalization: []network.NormType{network.NormTypeLayer},
+		RandSeed:      3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with zero biases, scaling the inputs scales every hidden weighted input, which layer normalization removes
+	got := predictVector(t, n, []float64{2, -4, 6})
+	want := predictVector(t, n, []float64{1, -2, 3})
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-6 {
+			t.Errorf("Predict() of scaled inputs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNetwork_TrainNormalization(t *testing.T) {
+	inputs := [][]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}}
+	targets := [][]float64{{0}, {1}, {1}, {0}}
+	for _, normType := range network.NormTypes {
+		t.Run(string(normType), func(t *testing.T) {
+			n, err := network.NewRandom(network.Config{
+				InputCount:    2,
+				LayerCounts:   []int{8, 8, 1},
+				Activations:   []network.ActivationType{network.ActivationTypeTanh, network.ActivationTypeTanh, network.ActivationTypeSigmoid},
+				Normalization: []network.NormType{normType, normType},
+				Loss:          network.LossTypeBinaryCrossEntropy,
+				Rate:          0.5,
+				Optimizer:     network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+				Init:          network.InitTypeXavierUniform,
+				RandSeed:      1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := n.TrainBatch(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last float64
+			for i := 0; i < 200; i++ {
+				if last, err = n.TrainBatch(inputs, targets); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last > first/4 {
+				t.Errorf("TrainBatch() loss after training = %v, want below a quarter of the first loss %v", last, first)
+			}
+
+			data, err := json.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := &network.Network{}
+			if err := json.Unmarshal(data, restored); err != nil {
+				t.Fatal(err)
+			}
+			for _, input := range inputs {
+				if got, want := predictVector(t, restored, input), predictVector(t, n, input); !reflect.DeepEqual(got, want) {
+					t.Errorf("restored Predict(%v) = %v, want %v", input, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package network
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// OptimizerType selects the algorithm used to apply gradients to the network parameters.
+type OptimizerType int
+
+const (
+	OptimizerTypeSGD OptimizerType = iota
+	OptimizerTypeMomentum
+	OptimizerTypeRMSProp
+	OptimizerTypeAdagrad
+	OptimizerTypeAdam
+)
+
+// OptimizerConfig optimizer constructor, every value is used as given, so a zero momentum or decay disables it.
+type OptimizerConfig struct {
+	Type     OptimizerType
+	Momentum float64 // momentum coefficient, commonly 0.9
+	Nesterov bool    // use Nesterov accelerated gradient with momentum
+	Decay    float64 // RMSProp squared gradient moving average decay, commonly 0.9
+	Beta1    float64 // Adam first moment decay, commonly 0.9
+	Beta2    float64 // Adam second moment decay, commonly 0.999
+	Epsilon  float64 // numerical stability term for RMSProp, Adagrad and Adam, commonly 1e-8
+}
+
+// Optimizer applies loss gradients to network parameters and owns any per-parameter state it needs between updates.
+type Optimizer interface {
+	// Update returns the adjusted parameters, params and grads are always given in the same order and dimensions.
+	Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error)
+	// State returns the optimizer state needed to resume training.
+	State() OptimizerState
+	// SetState restores a previously saved optimizer state.
+	SetState(OptimizerState) error
+}
+
+// OptimizerState per-parameter optimizer state, Slots are indexed by slot and then by parameter.
+type OptimizerState struct {
+	Step  uint64
+	Slots [][]*mat.Dense
+}
+
+// NewOptimizer constructs a new optimizer with empty state from a config.
+func NewOptimizer(cfg OptimizerConfig) (Optimizer, error) {
+	if cfg.Type != OptimizerTypeSGD && cfg.Type != OptimizerTypeMomentum && cfg.Epsilon <= 0 {
+		return nil, fmt.Errorf("optimizer epsilon %v must be positive", cfg.Epsilon)
+	}
+	switch cfg.Type {
+	case OptimizerTypeSGD:
+		return &sgd{}, nil
+	case OptimizerTypeMomentum:
+		return &momentum{cfg: cfg, slots: newSlots(1)}, nil
+	case OptimizerTypeRMSProp:
+		return &rmsProp{cfg: cfg, slots: newSlots(1)}, nil
+	case OptimizerTypeAdagrad:
+		return &adagrad{cfg: cfg, slots: newSlots(1)}, nil
+	case OptimizerTypeAdam:
+		return &adam{cfg: cfg, slots: newSlots(2)}, nil
+	default:
+		return nil, fmt.Errorf("unknown optimizer type %v", cfg.Type)
+	}
+}
+
+// slots holds optimizer state matrices, indexed by slot and then by parameter.
+type slots [][]*mat.Dense
+
+func newSlots(count int) slots {
+	return make(slots, count)
+}
+
+// get returns the slot matrix for a parameter, creating it filled with zeros if it doesn't exist yet.
+func (s slots) get(slot, param int, like *mat.Dense) (*mat.Dense, error) {
+	for len(s[slot]) <= param {
+		s[slot] = append(s[slot], nil)
+	}
+	r, c := like.Dims()
+	if s[slot][param] == nil {
+		s[slot][param] = mat.NewDense(r, c, nil)
+	}
+	sr, sc := s[slot][param].Dims()
+	if sr != r || sc != c {
+		return nil, fmt.Errorf("optimizer state %d for parameter %d has dimensions %dx%d, expected %dx%d", slot, param, sr, sc, r, c)
+	}
+	return s[slot][param], nil
+}
+
+// params returns the slot matrices for all parameters.
+func (s slots) params(slot int, params []*mat.Dense) ([]*mat.Dense, error) {
+	ms := make([]*mat.Dense, len(params))
+	for p, param := range params {
+		m, err := s.get(slot, p, param)
+		if err != nil {
+			return nil, err
+		}
+		ms[p] = m
+	}
+	return ms, nil
+}
+
+func (s slots) state(step uint64) OptimizerState {
+	return OptimizerState{Step: step, Slots: s}
+}
+
+func (s slots) restore(state OptimizerState) (slots, error) {
+	if len(state.Slots) == 0 {
+		return newSlots(len(s)), nil
+	}
+	if len(state.Slots) != len(s) {
+		return nil, fmt.Errorf("optimizer state has %d slots, expected %d", len(state.Slots), len(s))
+	}
+	return state.Slots, nil
+}
+
+// updateEach computes each adjusted parameter element from its index, current value and gradient.
+func updateEach(params, grads []*mat.Dense, fn func(p, i, j int, v, g float64) float64) ([]*mat.Dense, error) {
+	if len(params) != len(grads) {
+		return nil, fmt.Errorf("parameter count %d must equal gradient count %d", len(params), len(grads))
+	}
+	adjusted := make([]*mat.Dense, len(params))
+	for p, param := range params {
+		pr, pc := param.Dims()
+		gr, gc := grads[p].Dims()
+		if pr != gr || pc != gc {
+			return nil, fmt.Errorf("parameter %d dimensions %dx%d must equal gradient dimensions %dx%d", p, pr, pc, gr, gc)
+		}
+		grad := grads[p]
+		adjusted[p] = mat.NewDense(pr, pc, nil)
+		adjusted[p].Apply(func(i, j int, v float64) float64 {
+			return fn(p, i, j, v, grad.At(i, j))
+		}, param)
+	}
+	return adjusted, nil
+}
+
+// sgd plain stochastic gradient descent.
+type sgd struct{}
+
+func (o *sgd) Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error) {
+	return updateEach(params, grads, func(_, _, _ int, v, g float64) float64 {
+		return v - rate*g
+	})
+}
+
+func (o *sgd) State() OptimizerState {
+	return OptimizerState{}
+}
+
+func (o *sgd) SetState(OptimizerState) error {
+	return nil
+}
+
+// momentum stochastic gradient descent with classical or Nesterov momentum.
+type momentum struct {
+	cfg   OptimizerConfig
+	step  uint64
+	slots slots
+}
+
+func (o *momentum) Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error) {
+	velocities, err := o.slots.params(0, params)
+	if err != nil {
+		return nil, err
+	}
+	mu := o.cfg.Momentum
+	adjusted, err := updateEach(params, grads, func(p, i, j int, v, g float64) float64 {
+		velocity := mu*velocities[p].At(i, j) - rate*g
+		velocities[p].Set(i, j, velocity)
+		if o.cfg.Nesterov {
+			return v + mu*velocity - rate*g
+		}
+		return v + velocity
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.step++
+	return adjusted, nil
+}
+
+func (o *momentum) State() OptimizerState {
+	return o.slots.state(o.step)
+}
+
+func (o *momentum) SetState(state OptimizerState) (err error) {
+	o.step = state.Step
+	o.slots, err = o.slots.restore(state)
+	return err
+}
+
+// rmsProp scales each gradient by a moving average of its recent magnitude.
+type rmsProp struct {
+	cfg   OptimizerConfig
+	step  uint64
+	slots slots
+}
+
+func (o *rmsProp) Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error) {
+	caches, err := o.slots.params(0, params)
+	if err != nil {
+		return nil, err
+	}
+	decay, eps := o.cfg.Decay, o.cfg.Epsilon
+	adjusted, err := updateEach(params, grads, func(p, i, j int, v, g float64) float64 {
+		cache := decay*caches[p].At(i, j) + (1-decay)*g*g
+		caches[p].Set(i, j, cache)
+		return v - rate*g/(math.Sqrt(cache)+eps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.step++
+	return adjusted, nil
+}
+
+func (o *rmsProp) State() OptimizerState {
+	return o.slots.state(o.step)
+}
+
+func (o *rmsProp) SetState(state OptimizerState) (err error) {
+	o.step = state.Step
+	o.slots, err = o.slots.restore(state)
+	return err
+}
+
+// adagrad scales each gradient by the accumulated magnitude of all its previous gradients.
+type adagrad struct {
+	cfg   OptimizerConfig
+	step  uint64
+	slots slots
+}
+
+func (o *adagrad) Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error) {
+	caches, err := o.slots.params(0, params)
+	if err != nil {
+		return nil, err
+	}
+	eps := o.cfg.Epsilon
+	adjusted, err := updateEach(params, grads, func(p, i, j int, v, g float64) float64 {
+		cache := caches[p].At(i, j) + g*g
+		caches[p].Set(i, j, cache)
+		return v - rate*g/(math.Sqrt(cache)+eps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.step++
+	return adjusted, nil
+}
+
+func (o *adagrad) State() OptimizerState {
+	return o.slots.state(o.step)
+}
+
+func (o *adagrad) SetState(state OptimizerState) (err error) {
+	o.step = state.Step
+	o.slots, err = o.slots.restore(state)
+	return err
+}
+
+// adam adaptive moment estimation, keeping bias-corrected moving averages of each gradient and its square.
+type adam struct {
+	cfg   OptimizerConfig
+	step  uint64
+	slots slots
+}
+
+func (o *adam) Update(rate float64, params, grads []*mat.Dense) ([]*mat.Dense, error) {
+	firsts, err := o.slots.params(0, params)
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := o.slots.params(1, params)
+	if err != nil {
+		return nil, err
+	}
+	step := o.step + 1
+	b1, b2, eps := o.cfg.Beta1, o.cfg.Beta2, o.cfg.Epsilon
+	correction1 := 1 - math.Pow(b1, float64(step))
+	correction2 := 1 - math.Pow(b2, float64(step))
+	adjusted, err := updateEach(params, grads, func(p, i, j int, v, g float64) float64 {
+		first := b1*firsts[p].At(i, j) + (1-b1)*g
+		second := b2*seconds[p].At(i, j) + (1-b2)*g*g
+		firsts[p].Set(i, j, first)
+		seconds[p].Set(i, j, second)
+		return v - rate*(first/correction1)/(math.Sqrt(second/correction2)+eps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.step = step
+	return adjusted, nil
+}
+
+func (o *adam) State() OptimizerState {
+	return o.slots.state(o.step)
+}
+
+func (o *adam) SetState(state OptimizerState) (err error) {
+	o.step = state.Step
+	o.slots, err = o.slots.restore(state)
+	return err
+}
@@ -0,0 +1,192 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewOptimizer_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     network.OptimizerConfig
+		steps   int
+		rate    float64
+		param   float64
+		grad    float64
+		want    float64
+		wantErr bool
+	}{
+		{
+			name:  "sgd should step against the gradient",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeSGD},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.2 - 0.2,
+		},
+		{
+			name:  "momentum should accumulate velocity",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeMomentum, Momentum: 0.5},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.2 - (0.5*0.2 + 0.2),
+		},
+		{
+			name:  "nesterov momentum should look ahead along the velocity",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeMomentum, Momentum: 0.5, Nesterov: true},
+			steps: 1,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.5*0.2 - 0.2,
+		},
+		{
+			name:  "rmsprop should normalize by the root mean square gradient",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeRMSProp, Decay: 0.5, Epsilon: 1e-8},
+			steps: 1,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.1*2/(math.Sqrt(0.5*4)+1e-8),
+		},
+		{
+			name:  "adagrad should normalize by the accumulated gradient",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeAdagrad, Epsilon: 1e-8},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.1*2/(2+1e-8) - 0.1*2/(math.Sqrt(8)+1e-8),
+		},
+		{
+			name:  "adam should take bias-corrected steps of the learning rate",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 2*0.1*2/(2+1e-8),
+		},
+		{
+			name:  "momentum of 0 should step like sgd",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeMomentum},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.2 - 0.2,
+		},
+		{
+			name:  "rmsprop decay of 0 should normalize by the last gradient",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeRMSProp, Epsilon: 1e-8},
+			steps: 2,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 2*0.1*2/(2+1e-8),
+		},
+		{
+			name:  "adam beta1 of 0 should step by the current gradient",
+			cfg:   network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta2: 0.5, Epsilon: 1e-8},
+			steps: 1,
+			rate:  0.1,
+			param: 1,
+			grad:  2,
+			want:  1 - 0.1*2/(2+1e-8),
+		},
+		{
+			name:    "should error on a zero epsilon for adam",
+			cfg:     network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999},
+			wantErr: true,
+		},
+		{
+			name:    "should error on an unknown optimizer type",
+			cfg:     network.OptimizerConfig{Type: 99},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := network.NewOptimizer(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewOptimizer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			params := []*mat.Dense{mat.NewDense(1, 1, []float64{tt.param})}
+			grads := []*mat.Dense{mat.NewDense(1, 1, []float64{tt.grad})}
+			for i := 0; i < tt.steps; i++ {
+				params, err = o.Update(tt.rate, params, grads)
+				if err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+			if got := params[0].At(0, 0); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetwork_ResumeOptimizer(t *testing.T) {
+	records := [][2][]float64{
+		{{1, 2, 3}, {1}},
+		{{3, 2, 1}, {0}},
+		{{0, 1, 0}, {1}},
+	}
+	optimizers := []network.OptimizerConfig{
+		{Type: network.OptimizerTypeSGD},
+		{Type: network.OptimizerTypeMomentum, Momentum: 0.9},
+		{Type: network.OptimizerTypeMomentum, Momentum: 0.9, Nesterov: true},
+		{Type: network.OptimizerTypeRMSProp, Decay: 0.9, Epsilon: 1e-8},
+		{Type: network.OptimizerTypeAdagrad, Epsilon: 1e-8},
+		{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+	}
+	for _, optimizer := range optimizers {
+		cfg := network.Config{
+			InputCount:  3,
+			LayerCounts: []int{2, 1},
+			Rate:        0.1,
+			Optimizer:   optimizer,
+		}
+		continuous, err := network.NewRandom(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumed, err := network.NewRandom(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range records {
+			if err := continuous.Train(r[0], r[1]); err != nil {
+				t.Fatal(err)
+			}
+			if err := resumed.Train(r[0], r[1]); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(resumed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resumed = &network.Network{}
+			if err := json.Unmarshal(data, resumed); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := predictVector(t, continuous, []float64{1, 2, 3})
+		got := predictVector(t, resumed, []float64{1, 2, 3})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("optimizer %+v resumed Predict() = %v, want %v", optimizer, got, want)
+		}
+	}
+}
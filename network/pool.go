@@ -0,0 +1,213 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// pool2DLayer downsamples each channel of an image by the maximum or average of each window.
+type pool2DLayer struct {
+	t      LayerType
+	in     ImageShape
+	window window
+	out    ImageShape
+}
+
+type pool2DLayerConfig struct {
+	Input  ImageShape
+	Size   int
+	Stride int
+}
+
+// NewMaxPool2DLayer creates a layer taking the maximum of each square window of a size in each channel of images of an input shape.
+// A zero stride defaults to the window size.
+func NewMaxPool2DLayer(in ImageShape, size, stride int) (Layer, error) {
+	return newPool2DLayer(LayerTypeMaxPool2D, in, size, stride)
+}
+
+// NewAvgPool2DLayer creates a layer taking the average of each square window of a size in each channel of images of an input shape.
+// A zero stride defaults to the window size.
+func NewAvgPool2DLayer(in ImageShape, size, stride int) (Layer, error) {
+	return newPool2DLayer(LayerTypeAvgPool2D, in, size, stride)
+}
+
+func newPool2DLayer(t LayerType, in ImageShape, size, stride int) (Layer, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	if stride == 0 {
+		stride = size
+	}
+	w := window{Height: size, Width: size, Stride: stride}
+	height, width, err := w.outputShape(in)
+	if err != nil {
+		return nil, err
+	}
+	return pool2DLayer{
+		t:      t,
+		in:     in,
+		window: w,
+		out:    ImageShape{Channels: in.Channels, Height: height, Width: width},
+	}, nil
+}
+
+func decodePool2DLayer(t LayerType) LayerDecoder {
+	return func(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+		var cfg pool2DLayerConfig
+		if err := unmarshalLayerConfig(t, config, &cfg, params, state, 0, 0); err != nil {
+			return nil, err
+		}
+		return newPool2DLayer(t, cfg.Input, cfg.Size, cfg.Stride)
+	}
+}
+
+func (l pool2DLayer) Type() LayerType {
+	return l.t
+}
+
+func (l pool2DLayer) Forward(inputs *mat.Dense, _ Pass) (*mat.Dense, interface{}, error) {
+	rows, cols := inputs.Dims()
+	if rows != l.in.Size() {
+		return nil, nil, fmt.Errorf("pooling input rows %d must equal image size %d of shape %s", rows, l.in.Size(), l.in)
+	}
+	outputs := mat.NewDense(l.out.Size(), cols, nil)
+	// the input index of each maximum, for max pooling
+	var selected [][]int
+	if l.t == LayerTypeMaxPool2D {
+		selected = make([][]int, cols)
+	}
+	area := float64(l.window.Height * l.window.Width)
+	for j := 0; j < cols; j++ {
+		image := mat.Col(nil, j, inputs)
+		if selected != nil {
+			selected[j] = make([]int, l.out.Size())
+		}
+		l.eachWindow(func(out int, indexes []int) {
+			if selected == nil {
+				var sum float64
+				for _, index := range indexes {
+					sum += image[index]
+				}
+				outputs.Set(out, j, sum/area)
+				return
+			}
+			max, maxIndex := math.Inf(-1), indexes[0]
+			for _, index := range indexes {
+				if image[index] > max {
+					max, maxIndex = image[index], index
+				}
+			}
+			outputs.Set(out, j, max)
+			selected[j][out] = maxIndex
+		})
+	}
+	return outputs, selected, nil
+}
+
+func (l pool2DLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	selected, ok := cache.([][]int)
+	if !ok {
+		return nil, nil, fmt.Errorf("pooling layer cache must be from a forward pass")
+	}
+	_, cols := grads.Dims()
+	if l.t == LayerTypeMaxPool2D && len(selected) != cols {
+		return nil, nil, fmt.Errorf("pooling gradient batch size %d must equal forward pass batch size %d", cols, len(selected))
+	}
+	inputGrads := mat.NewDense(l.in.Size(), cols, nil)
+	area := float64(l.window.Height * l.window.Width)
+	for j := 0; j < cols; j++ {
+		l.eachWindow(func(out int, indexes []int) {
+			g := grads.At(out, j)
+			if l.t == LayerTypeMaxPool2D {
+				index := selected[j][out]
+				inputGrads.Set(index, j, inputGrads.At(index, j)+g)
+				return
+			}
+			for _, index := range indexes {
+				inputGrads.Set(index, j, inputGrads.At(index, j)+g/area)
+			}
+		})
+	}
+	return inputGrads, nil, nil
+}
+
+// eachWindow calls fn with the output index of every window position and the input indexes of the values in the window.
+func (l pool2DLayer) eachWindow(fn func(out int, indexes []int)) {
+	indexes := make([]int, 0, l.window.Height*l.window.Width)
+	for c := 0; c < l.in.Channels; c++ {
+		for oy := 0; oy < l.out.Height; oy++ {
+			for ox := 0; ox < l.out.Width; ox++ {
+				indexes = indexes[:0]
+				for ky := 0; ky < l.window.Height; ky++ {
+					for kx := 0; kx < l.window.Width; kx++ {
+						y, x := oy*l.window.Stride+ky, ox*l.window.Stride+kx
+						indexes = append(indexes, (c*l.in.Height+y)*l.in.Width+x)
+					}
+				}
+				fn((c*l.out.Height+oy)*l.out.Width+ox, indexes)
+			}
+		}
+	}
+}
+
+func (l pool2DLayer) Params() []Param {
+	return nil
+}
+
+func (l pool2DLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("pooling layer has no params, got %d", len(params))
+	}
+	return l, nil
+}
+
+func (l pool2DLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	config, err := marshalLayerConfig(pool2DLayerConfig{Input: l.in, Size: l.window.Height, Stride: l.window.Stride})
+	return config, nil, err
+}
+
+// flattenLayer marks the end of image layers. Images are already stored flattened in each matrix column,
+// so it passes values through unchanged.
+type flattenLayer struct{}
+
+// NewFlattenLayer creates a layer that treats image inputs as a flat list of values.
+func NewFlattenLayer() Layer {
+	return flattenLayer{}
+}
+
+func decodeFlattenLayer(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+	if err := unmarshalLayerConfig(LayerTypeFlatten, config, nil, params, state, 0, 0); err != nil {
+		return nil, err
+	}
+	return flattenLayer{}, nil
+}
+
+func (l flattenLayer) Type() LayerType {
+	return LayerTypeFlatten
+}
+
+func (l flattenLayer) Forward(inputs *mat.Dense, _ Pass) (*mat.Dense, interface{}, error) {
+	return inputs, nil, nil
+}
+
+func (l flattenLayer) Backward(grads *mat.Dense, _ interface{}) (*mat.Dense, []*mat.Dense, error) {
+	return grads, nil, nil
+}
+
+func (l flattenLayer) Params() []Param {
+	return nil
+}
+
+func (l flattenLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("flatten layer has no params, got %d", len(params))
+	}
+	return l, nil
+}
+
+func (l flattenLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	return nil, nil, nil
+}
@@ -0,0 +1,437 @@
+// Package preprocess converts raw dataset records to network inputs and targets with a pipeline of steps for each column,
+// fitted to training records and serialized along with a model so raw records can be predicted directly.
+package preprocess
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StepType names a preprocessing step.
+type StepType string
+
+const (
+	StepTypeMinMax  StepType = "minmax"  // scales by the fitted minimum and maximum to [0, 1], or [Low, High]
+	StepTypeZScore  StepType = "zscore"  // standardizes by the fitted mean and standard deviation
+	StepTypeRobust  StepType = "robust"  // centers on the fitted median and scales by the interquartile range
+	StepTypeLog     StepType = "log"     // transforms values above -1 by log(1+x)
+	StepTypeOneHot  StepType = "onehot"  // encodes a category, or a delimited set of categories, as 1, or High, for each matching fitted category
+	StepTypeLabel   StepType = "label"   // encodes a category as its index in the fitted categories
+	StepTypeBoolean StepType = "boolean" // encodes 1, true, yes, y or t as 1 and 0, false, no, n or f as 0
+)
+
+// Step is a preprocessing step of a column with its fitted parameters. Fitting skips steps that are already fitted,
+// so a step can be declared with fixed parameters. A zero range, deviation or interquartile range scales by 1.
+// Low and High set the range a minmax step scales to and the values a onehot step encodes absent and present categories as,
+// 0 and 1 when both are zero.
+type Step struct {
+	Type       StepType
+	Fitted     bool
+	Min        float64
+	Max        float64
+	Mean       float64
+	Std        float64
+	Median     float64
+	IQR        float64
+	Categories []string
+	Delimiter  string // separates a set of categories in one value for onehot steps, none when empty
+	Low        float64
+	High       float64
+}
+
+// Column applies its steps in order to a column of raw records. Categorical and boolean steps come first and one-hot encoding last,
+// a column without steps is read as a number.
+type Column struct {
+	Index int
+	Steps []Step
+}
+
+// Pipeline converts the columns of raw records to network inputs and targets.
+type Pipeline struct {
+	Inputs  []Column
+	Targets []Column
+}
+
+func (s Step) categorical() bool {
+	return s.Type == StepTypeOneHot || s.Type == StepTypeLabel
+}
+
+// bounds returns the range of a minmax step or the absent and present values of a onehot step.
+func (s Step) bounds() (float64, float64) {
+	if s.Low == 0 && s.High == 0 {
+		return 0, 1
+	}
+	return s.Low, s.High
+}
+
+// leading reports whether the step reads the raw value, so it must be the first step of a column.
+func (s Step) leading() bool {
+	return s.categorical() || s.Type == StepTypeBoolean
+}
+
+// Validate checks the step types and their order, and the parameters of fitted steps.
+func (p Pipeline) Validate() error {
+	if len(p.Inputs) == 0 {
+		return fmt.Errorf("pipeline must have at least one input column")
+	}
+	for i, c := range p.Inputs {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("input column %d: %v", i, err)
+		}
+	}
+	for i, c := range p.Targets {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("target column %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (c Column) validate() error {
+	if c.Index < 0 {
+		return fmt.Errorf("record index %d cannot be negative", c.Index)
+	}
+	for i, s := range c.Steps {
+		switch s.Type {
+		case StepTypeMinMax, StepTypeZScore, StepTypeRobust, StepTypeLog, StepTypeOneHot, StepTypeLabel, StepTypeBoolean:
+		default:
+			return fmt.Errorf("unknown step type '%s'", s.Type)
+		}
+		if s.leading() && i > 0 {
+			return fmt.Errorf("%s step must be the first step", s.Type)
+		}
+		if s.Type == StepTypeOneHot && i < len(c.Steps)-1 {
+			return fmt.Errorf("onehot step must be the last step")
+		}
+		if s.Delimiter != "" && s.Type != StepTypeOneHot {
+			return fmt.Errorf("only onehot steps can have a delimiter")
+		}
+		if s.Low != 0 || s.High != 0 {
+			if s.Type != StepTypeMinMax && s.Type != StepTypeOneHot {
+				return fmt.Errorf("only minmax and onehot steps can have a low and high value")
+			}
+			if s.High <= s.Low {
+				return fmt.Errorf("%s step high %v must be greater than low %v", s.Type, s.High, s.Low)
+			}
+		}
+		if !s.Fitted {
+			continue
+		}
+		switch {
+		case s.categorical() && len(s.Categories) == 0:
+			return fmt.Errorf("fitted %s step must have categories", s.Type)
+		case s.Type == StepTypeMinMax && s.Max < s.Min:
+			return fmt.Errorf("minmax step max %v cannot be less than min %v", s.Max, s.Min)
+		case s.Type == StepTypeZScore && s.Std <= 0:
+			return fmt.Errorf("zscore step std %v must be positive", s.Std)
+		case s.Type == StepTypeRobust && s.IQR <= 0:
+			return fmt.Errorf("robust step interquartile range %v must be positive", s.IQR)
+		}
+	}
+	return nil
+}
+
+// Fitted reports whether every step of the pipeline is fitted.
+func (p Pipeline) Fitted() bool {
+	for _, columns := range [][]Column{p.Inputs, p.Targets} {
+		for _, c := range columns {
+			for _, s := range c.Steps {
+				if !s.Fitted {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Fit returns a copy of the pipeline with each unfitted step fitted to the records,
+// each step fitted to the column values transformed by the steps before it.
+func (p Pipeline) Fit(records [][]string) (Pipeline, error) {
+	if err := p.Validate(); err != nil {
+		return Pipeline{}, err
+	}
+	if len(records) == 0 {
+		return Pipeline{}, fmt.Errorf("cannot fit a pipeline without records")
+	}
+	inputs, err := fitColumns(p.Inputs, records)
+	if err != nil {
+		return Pipeline{}, fmt.Errorf("input %v", err)
+	}
+	targets, err := fitColumns(p.Targets, records)
+	if err != nil {
+		return Pipeline{}, fmt.Errorf("target %v", err)
+	}
+	return Pipeline{Inputs: inputs, Targets: targets}, nil
+}
+
+func fitColumns(columns []Column, records [][]string) ([]Column, error) {
+	fitted := make([]Column, len(columns))
+	for i, c := range columns {
+		var err error
+		if fitted[i], err = c.fit(records); err != nil {
+			return nil, fmt.Errorf("column %d: %v", i, err)
+		}
+	}
+	return fitted, nil
+}
+
+func (c Column) fit(records [][]string) (Column, error) {
+	fitted := Column{Index: c.Index, Steps: append([]Step(nil), c.Steps...)}
+	raw := make([]string, len(records))
+	for i, r := range records {
+		if c.Index >= len(r) {
+			return Column{}, fmt.Errorf("record %d has no column %d", i, c.Index)
+		}
+		raw[i] = strings.TrimSpace(r[c.Index])
+	}
+	first := 0
+	if len(fitted.Steps) > 0 && fitted.Steps[0].leading() {
+		s := &fitted.Steps[0]
+		if !s.Fitted && s.categorical() {
+			s.Categories = fitCategories(raw, s.Delimiter)
+		}
+		s.Fitted = true
+		if s.Type == StepTypeOneHot {
+			return fitted, nil
+		}
+		first = 1
+	}
+	values := make([]float64, len(raw))
+	for i, v := range raw {
+		var err error
+		if values[i], err = parseValue(fitted.Steps[:first], v); err != nil {
+			return Column{}, fmt.Errorf("record %d: %v", i, err)
+		}
+	}
+	for i := first; i < len(fitted.Steps); i++ {
+		s := &fitted.Steps[i]
+		if !s.Fitted {
+			s.fit(values)
+		}
+		for j, v := range values {
+			var err error
+			if values[j], err = s.apply(v); err != nil {
+				return Column{}, fmt.Errorf("record %d: %v", j, err)
+			}
+		}
+	}
+	return fitted, nil
+}
+
+// fitCategories returns the sorted unique categories of the values, splitting each value by a delimiter if set.
+func fitCategories(values []string, delimiter string) []string {
+	seen := map[string]bool{}
+	var categories []string
+	for _, v := range values {
+		parts := []string{v}
+		if delimiter != "" {
+			parts = strings.Split(v, delimiter)
+		}
+		for _, c := range parts {
+			c = strings.TrimSpace(c)
+			if c == "" && delimiter != "" || seen[c] {
+				continue
+			}
+			seen[c] = true
+			categories = append(categories, c)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// fit sets the parameters of a numeric step from the values.
+func (s *Step) fit(values []float64) {
+	s.Fitted = true
+	switch s.Type {
+	case StepTypeMinMax:
+		s.Min, s.Max = math.Inf(1), math.Inf(-1)
+		for _, v := range values {
+			s.Min, s.Max = math.Min(s.Min, v), math.Max(s.Max, v)
+		}
+	case StepTypeZScore:
+		s.Mean, s.Std = 0, 0
+		for _, v := range values {
+			s.Mean += v
+		}
+		s.Mean /= float64(len(values))
+		for _, v := range values {
+			s.Std += (v - s.Mean) * (v - s.Mean)
+		}
+		s.Std = math.Sqrt(s.Std / float64(len(values)))
+		if s.Std == 0 {
+			s.Std = 1
+		}
+	case StepTypeRobust:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		s.Median = quantile(sorted, 0.5)
+		s.IQR = quantile(sorted, 0.75) - quantile(sorted, 0.25)
+		if s.IQR == 0 {
+			s.IQR = 1
+		}
+	}
+}
+
+// quantile linearly interpolates the q quantile of sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// apply transforms a value by a fitted numeric step.
+func (s Step) apply(v float64) (float64, error) {
+	switch s.Type {
+	case StepTypeMinMax:
+		low, high := s.bounds()
+		if s.Max == s.Min {
+			return low + v - s.Min, nil
+		}
+		return low + (v-s.Min)/(s.Max-s.Min)*(high-low), nil
+	case StepTypeZScore:
+		return (v - s.Mean) / s.Std, nil
+	case StepTypeRobust:
+		return (v - s.Median) / s.IQR, nil
+	case StepTypeLog:
+		if v <= -1 {
+			return 0, fmt.Errorf("log step value %v must be greater than -1", v)
+		}
+		return math.Log1p(v), nil
+	}
+	return 0, fmt.Errorf("%s step cannot transform a number", s.Type)
+}
+
+// parseValue reads a raw value as a number, as a boolean for a leading boolean step,
+// or as the index of its category for a leading label step.
+func parseValue(leading []Step, raw string) (float64, error) {
+	if len(leading) == 0 {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number '%s'", raw)
+		}
+		return v, nil
+	}
+	if leading[0].Type == StepTypeBoolean {
+		switch strings.ToLower(raw) {
+		case "1", "true", "yes", "y", "t":
+			return 1, nil
+		case "0", "false", "no", "n", "f":
+			return 0, nil
+		}
+		return 0, fmt.Errorf("invalid boolean '%s'", raw)
+	}
+	for i, c := range leading[0].Categories {
+		if c == raw {
+			return float64(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown category '%s'", raw)
+}
+
+// Width returns the number of values the columns produce, known once any one-hot steps are fitted.
+func Width(columns []Column) int {
+	width := 0
+	for _, c := range columns {
+		if n := len(c.Steps); n > 0 && c.Steps[n-1].Type == StepTypeOneHot {
+			width += len(c.Steps[n-1].Categories)
+			continue
+		}
+		width++
+	}
+	return width
+}
+
+// Transform converts a raw record to its network inputs and targets with a fitted pipeline.
+func (p Pipeline) Transform(record []string) (inputs, targets []float64, err error) {
+	if inputs, err = transform(p.Inputs, record); err != nil {
+		return nil, nil, fmt.Errorf("inputs: %v", err)
+	}
+	if targets, err = transform(p.Targets, record); err != nil {
+		return nil, nil, fmt.Errorf("targets: %v", err)
+	}
+	return inputs, targets, nil
+}
+
+// TransformInputs converts a raw record to its network inputs with a fitted pipeline, ignoring any target columns.
+func (p Pipeline) TransformInputs(record []string) ([]float64, error) {
+	return transform(p.Inputs, record)
+}
+
+func transform(columns []Column, record []string) ([]float64, error) {
+	values := make([]float64, 0, Width(columns))
+	for _, c := range columns {
+		if c.Index >= len(record) {
+			return nil, fmt.Errorf("record has %d columns, missing column %d", len(record), c.Index)
+		}
+		encoded, err := c.transform(strings.TrimSpace(record[c.Index]))
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", c.Index, err)
+		}
+		values = append(values, encoded...)
+	}
+	return values, nil
+}
+
+func (c Column) transform(raw string) ([]float64, error) {
+	for _, s := range c.Steps {
+		if !s.Fitted {
+			return nil, fmt.Errorf("%s step is not fitted", s.Type)
+		}
+	}
+	if len(c.Steps) > 0 && c.Steps[0].Type == StepTypeOneHot {
+		return oneHot(c.Steps[0], raw)
+	}
+	first := 0
+	if len(c.Steps) > 0 && c.Steps[0].leading() {
+		first = 1
+	}
+	v, err := parseValue(c.Steps[:first], raw)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range c.Steps[first:] {
+		if v, err = s.apply(v); err != nil {
+			return nil, err
+		}
+	}
+	return []float64{v}, nil
+}
+
+// oneHot encodes a category, or each category of a delimited set, as 1, or High, in the position of the category
+// and the other positions as 0, or Low.
+func oneHot(s Step, raw string) ([]float64, error) {
+	low, high := s.bounds()
+	encoded := make([]float64, len(s.Categories))
+	for i := range encoded {
+		encoded[i] = low
+	}
+	parts := []string{raw}
+	if s.Delimiter != "" {
+		parts = strings.Split(raw, s.Delimiter)
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" && s.Delimiter != "" {
+			continue
+		}
+		found := false
+		for i, c := range s.Categories {
+			if c == part {
+				encoded[i], found = high, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown category '%s'", part)
+		}
+	}
+	return encoded, nil
+}
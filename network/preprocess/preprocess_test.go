@@ -0,0 +1,195 @@
+package preprocess_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network/preprocess"
+)
+
+var records = [][]string{
+	{"1", "red", "0", "a|b", "yes"},
+	{"2", "green", "9", "", "no"},
+	{"3", "red", "99", "c", "no"},
+	{"10", "blue", "999", "b", "yes"},
+}
+
+func TestPipeline_Fit(t *testing.T) {
+	tests := []struct {
+		name   string
+		column preprocess.Column
+		want   [][]float64
+	}{
+		{
+			name:   "no steps should read numbers",
+			column: preprocess.Column{Index: 0},
+			want:   [][]float64{{1}, {2}, {3}, {10}},
+		},
+		{
+			name:   "minmax should scale to the unit range",
+			column: preprocess.Column{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax}}},
+			want:   [][]float64{{0}, {1.0 / 9}, {2.0 / 9}, {1}},
+		},
+		{
+			name:   "zscore should standardize",
+			column: preprocess.Column{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeZScore}}},
+			want:   [][]float64{{-3 / math.Sqrt(12.5)}, {-2 / math.Sqrt(12.5)}, {-1 / math.Sqrt(12.5)}, {6 / math.Sqrt(12.5)}},
+		},
+		{
+			name:   "robust should center on the median and scale by the interquartile range",
+			column: preprocess.Column{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeRobust}}},
+			want:   [][]float64{{-1.5 / 3}, {-0.5 / 3}, {0.5 / 3}, {7.5 / 3}},
+		},
+		{
+			name:   "log then minmax should fit minmax to the log values",
+			column: preprocess.Column{Index: 2, Steps: []preprocess.Step{{Type: preprocess.StepTypeLog}, {Type: preprocess.StepTypeMinMax}}},
+			want:   [][]float64{{0}, {1.0 / 3}, {2.0 / 3}, {1}},
+		},
+		{
+			name:   "fixed minmax should not be refitted",
+			column: preprocess.Column{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: true, Min: 0, Max: 20}}},
+			want:   [][]float64{{0.05}, {0.1}, {0.15}, {0.5}},
+		},
+		{
+			name:   "fixed minmax with a range should scale to low and high",
+			column: preprocess.Column{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: true, Min: 0, Max: 10, Low: 0.01, High: 1}}},
+			want:   [][]float64{{0.01 + 0.099}, {0.01 + 0.198}, {0.01 + 0.297}, {1}},
+		},
+		{
+			name:   "onehot should encode sorted categories",
+			column: preprocess.Column{Index: 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot}}},
+			want:   [][]float64{{0, 0, 1}, {0, 1, 0}, {0, 0, 1}, {1, 0, 0}},
+		},
+		{
+			name:   "onehot with a delimiter should encode category sets",
+			column: preprocess.Column{Index: 3, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Delimiter: "|"}}},
+			want:   [][]float64{{1, 1, 0}, {0, 0, 0}, {0, 0, 1}, {0, 1, 0}},
+		},
+		{
+			name:   "onehot with low and high should encode absent and present categories",
+			column: preprocess.Column{Index: 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Low: 0.01, High: 0.99}}},
+			want:   [][]float64{{0.01, 0.01, 0.99}, {0.01, 0.99, 0.01}, {0.01, 0.01, 0.99}, {0.99, 0.01, 0.01}},
+		},
+		{
+			name:   "label should encode the category index",
+			column: preprocess.Column{Index: 4, Steps: []preprocess.Step{{Type: preprocess.StepTypeLabel}}},
+			want:   [][]float64{{1}, {0}, {0}, {1}},
+		},
+		{
+			name:   "boolean should encode yes as 1",
+			column: preprocess.Column{Index: 4, Steps: []preprocess.Step{{Type: preprocess.StepTypeBoolean}}},
+			want:   [][]float64{{1}, {0}, {0}, {1}},
+		},
+		{
+			name:   "label then minmax should scale the category index",
+			column: preprocess.Column{Index: 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeLabel}, {Type: preprocess.StepTypeMinMax}}},
+			want:   [][]float64{{1}, {0.5}, {1}, {0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := preprocess.Pipeline{Inputs: []preprocess.Column{tt.column}}.Fit(records)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Fitted() {
+				t.Errorf("Fit() pipeline is not fitted")
+			}
+			if got := preprocess.Width(p.Inputs); got != len(tt.want[0]) {
+				t.Errorf("Width() = %d, want %d", got, len(tt.want[0]))
+			}
+			for i, r := range records {
+				got, err := p.TransformInputs(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for j := range got {
+					if math.Abs(got[j]-tt.want[i][j]) > 1e-9 {
+						t.Errorf("TransformInputs(%v) = %v, want %v", r, got, tt.want[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPipeline_Transform(t *testing.T) {
+	p, err := preprocess.Pipeline{
+		Inputs:  []preprocess.Column{{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax}}}, {Index: 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot}}}},
+		Targets: []preprocess.Column{{Index: 4, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot}}}},
+	}.Fit(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, targets, err := p.Transform(records[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{1, 1, 0, 0}; !reflect.DeepEqual(inputs, want) {
+		t.Errorf("Transform() inputs = %v, want %v", inputs, want)
+	}
+	if want := []float64{0, 1}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("Transform() targets = %v, want %v", targets, want)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored preprocess.Pipeline
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, p) {
+		t.Errorf("restored pipeline = %+v, want %+v", restored, p)
+	}
+
+	for name, record := range map[string][]string{
+		"unknown category": {"1", "purple", "0", "", "yes"},
+		"invalid number":   {"x", "red", "0", "", "yes"},
+		"missing column":   {"1", "red"},
+	} {
+		if _, _, err := p.Transform(record); err == nil {
+			t.Errorf("Transform() with %s error = nil, want error", name)
+		}
+	}
+	unfitted := preprocess.Pipeline{Inputs: []preprocess.Column{{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeZScore}}}}}
+	if _, err := unfitted.TransformInputs(records[0]); err == nil {
+		t.Errorf("TransformInputs() with an unfitted step error = nil, want error")
+	}
+}
+
+func TestPipeline_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []preprocess.Step
+	}{
+		{name: "unknown step", steps: []preprocess.Step{{Type: "sqrt"}}},
+		{name: "label after numeric step", steps: []preprocess.Step{{Type: preprocess.StepTypeLog}, {Type: preprocess.StepTypeLabel}}},
+		{name: "step after onehot", steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot}, {Type: preprocess.StepTypeMinMax}}},
+		{name: "boolean after numeric step", steps: []preprocess.Step{{Type: preprocess.StepTypeZScore}, {Type: preprocess.StepTypeBoolean}}},
+		{name: "delimiter on label", steps: []preprocess.Step{{Type: preprocess.StepTypeLabel, Delimiter: "|"}}},
+		{name: "low and high on zscore", steps: []preprocess.Step{{Type: preprocess.StepTypeZScore, Low: -1, High: 1}}},
+		{name: "high not above low", steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Low: 1, High: 0.5}}},
+		{name: "fitted zscore without std", steps: []preprocess.Step{{Type: preprocess.StepTypeZScore, Fitted: true}}},
+		{name: "fitted onehot without categories", steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Fitted: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := preprocess.Pipeline{Inputs: []preprocess.Column{{Index: 0, Steps: tt.steps}}}
+			if err := p.Validate(); err == nil {
+				t.Errorf("Validate() error = nil, want error")
+			}
+		})
+	}
+	if err := (preprocess.Pipeline{}).Validate(); err == nil {
+		t.Errorf("Validate() without inputs error = nil, want error")
+	}
+	log := preprocess.Pipeline{Inputs: []preprocess.Column{{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeLog}}}}}
+	if _, err := log.Fit([][]string{{"-1"}}); err == nil {
+		t.Errorf("Fit() log of -1 error = nil, want error")
+	}
+}
@@ -0,0 +1,42 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/benjohns1/neural-net-go/network/preprocess"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// validatePreprocessing checks a preprocessing pipeline is fitted and produces the network inputs,
+// and any target columns produce the network outputs.
+func validatePreprocessing(p *preprocess.Pipeline, inputCount, outputCount int) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("preprocessing: %v", err)
+	}
+	if !p.Fitted() {
+		return fmt.Errorf("preprocessing pipeline must be fitted")
+	}
+	if width := preprocess.Width(p.Inputs); inputCount > 0 && width != inputCount {
+		return fmt.Errorf("preprocessing input width %d must equal input count %d", width, inputCount)
+	}
+	if width := preprocess.Width(p.Targets); len(p.Targets) > 0 && outputCount > 0 && width != outputCount {
+		return fmt.Errorf("preprocessing target width %d must equal output count %d", width, outputCount)
+	}
+	return nil
+}
+
+// PredictRecord predicts the outputs of a raw record converted to inputs by the preprocessing pipeline of the network.
+func (n Network) PredictRecord(record []string) (*mat.Dense, error) {
+	if n.cfg.Preprocessing == nil {
+		return nil, fmt.Errorf("predicting raw records needs a preprocessing pipeline")
+	}
+	inputs, err := n.cfg.Preprocessing.TransformInputs(record)
+	if err != nil {
+		return nil, fmt.Errorf("preprocessing record: %v", err)
+	}
+	return n.Predict(inputs)
+}
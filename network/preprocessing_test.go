@@ -0,0 +1,112 @@
+package network_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
+)
+
+var flowerRecords = [][]string{
+	{"5.1", "0.2", "setosa"},
+	{"7.0", "1.4", "versicolor"},
+	{"6.3", "2.5", "virginica"},
+	{"4.9", "0.1", "setosa"},
+}
+
+func fitFlowerPipeline(t *testing.T) *preprocess.Pipeline {
+	p, err := preprocess.Pipeline{
+		Inputs: []preprocess.Column{
+			{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax}}},
+			{Index: 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeLog}, {Type: preprocess.StepTypeZScore}}},
+		},
+		Targets: []preprocess.Column{{Index: 2, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot}}}},
+	}.Fit(flowerRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+func TestNetwork_PredictRecord(t *testing.T) {
+	p := fitFlowerPipeline(t)
+	n, err := network.NewRandom(network.Config{
+		InputCount:    2,
+		LayerCounts:   []int{4, 3},
+		Rate:          0.5,
+		RandSeed:      1,
+		Preprocessing: p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for e := 0; e < 200; e++ {
+		for _, r := range flowerRecords {
+			inputs, targets, err := p.Transform(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.Train(inputs, targets); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range flowerRecords {
+		inputs, err := p.TransformInputs(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := n.Predict(inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := restored.PredictRecord(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if got.At(i, 0) != want.At(i, 0) {
+				t.Errorf("PredictRecord(%v) output %d = %v, want %v", r, i, got.At(i, 0), want.At(i, 0))
+			}
+		}
+	}
+	if _, err := restored.PredictRecord([]string{"5.1", "-2", "setosa"}); err == nil {
+		t.Errorf("PredictRecord() with an invalid log value error = nil, want error")
+	}
+}
+
+func TestNewRandom_PreprocessingError(t *testing.T) {
+	fitted := fitFlowerPipeline(t)
+	tests := []struct {
+		name string
+		p    *preprocess.Pipeline
+	}{
+		{name: "unfitted", p: &preprocess.Pipeline{Inputs: []preprocess.Column{{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeZScore}}}, {Index: 1}}}},
+		{name: "input width", p: &preprocess.Pipeline{Inputs: fitted.Inputs[:1], Targets: fitted.Targets}},
+		{name: "target width", p: &preprocess.Pipeline{Inputs: fitted.Inputs, Targets: append(fitted.Targets, preprocess.Column{Index: 0})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{3}, Preprocessing: tt.p}); err == nil {
+				t.Errorf("NewRandom() error = nil, want error")
+			}
+		})
+	}
+	n, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.PredictRecord(flowerRecords[0]); err == nil {
+		t.Errorf("PredictRecord() without a pipeline error = nil, want error")
+	}
+}
@@ -16,3 +16,45 @@ func (r Rand) GetSource() rand.Source {
 	}
 	return src
 }
+
+// Streams of random values derived from a seed by Stream, independent of the stream that initializes weights and draws dropout masks.
+const (
+	StreamShuffle uint64 = iota + 1 // orders training records each epoch
+	StreamSplit                     // holds out validation records
+)
+
+// Stream returns a generator at state 0 for a stream of random values derived from the seed,
+// so drawing from one stream does not shift the values drawn from the seed or from other streams.
+func (r Rand) Stream(stream uint64) Rand {
+	// splitmix64 finalizer, so nearby seeds and streams give unrelated seeds
+	z := r.Seed + stream*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return Rand{Seed: z ^ (z >> 31)}
+}
+
+// CountingSource wraps a source, counting the values drawn so generation can later resume from the same state.
+type CountingSource struct {
+	src   rand.Source
+	state uint64
+}
+
+// CountingSource returns a new seeded source at the current state that tracks its state as values are drawn.
+func (r Rand) CountingSource() *CountingSource {
+	return &CountingSource{src: r.GetSource(), state: r.State}
+}
+
+func (s *CountingSource) Uint64() uint64 {
+	s.state++
+	return s.src.Uint64()
+}
+
+func (s *CountingSource) Seed(seed uint64) {
+	s.src.Seed(seed)
+	s.state = 0
+}
+
+// State returns the state of the source, the number of values drawn from its seed.
+func (s *CountingSource) State() uint64 {
+	return s.state
+}
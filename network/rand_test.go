@@ -60,3 +60,74 @@ func TestRand_GetSource(t *testing.T) {
 		})
 	}
 }
+
+func TestRand_Stream(t *testing.T) {
+	tests := []struct {
+		name      string
+		r1        network.Rand
+		r2        network.Rand
+		wantEqual bool
+	}{
+		{
+			name:      "should derive the same stream from the same seed at any state",
+			r1:        network.Rand{Seed: 1}.Stream(network.StreamShuffle),
+			r2:        network.Rand{Seed: 1, State: 50}.Stream(network.StreamShuffle),
+			wantEqual: true,
+		},
+		{
+			name:      "should derive a stream different from its seed",
+			r1:        network.Rand{Seed: 1}.Stream(network.StreamShuffle),
+			r2:        network.Rand{Seed: 1},
+			wantEqual: false,
+		},
+		{
+			name:      "should derive different streams from the same seed",
+			r1:        network.Rand{Seed: 1}.Stream(network.StreamShuffle),
+			r2:        network.Rand{Seed: 1}.Stream(network.StreamSplit),
+			wantEqual: false,
+		},
+		{
+			name:      "should derive different streams from different seeds",
+			r1:        network.Rand{Seed: 1}.Stream(network.StreamShuffle),
+			r2:        network.Rand{Seed: 2}.Stream(network.StreamShuffle),
+			wantEqual: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got1 := tt.r1.GetSource().Uint64()
+			got2 := tt.r2.GetSource().Uint64()
+			if (got1 == got2) != tt.wantEqual {
+				t.Errorf("Stream() got1 = %v got2 = %v, wantEqual %v", got1, got2, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestRand_CountingSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		r         network.Rand
+		draws     int
+		wantState uint64
+	}{
+		{name: "should start at state 0", r: network.Rand{Seed: 1}, wantState: 0},
+		{name: "should count each value drawn", r: network.Rand{Seed: 1}, draws: 3, wantState: 3},
+		{name: "should count on from the state of the generator", r: network.Rand{Seed: 1, State: 5}, draws: 2, wantState: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.r.CountingSource()
+			for i := 0; i < tt.draws; i++ {
+				src.Uint64()
+			}
+			if got := src.State(); got != tt.wantState {
+				t.Fatalf("State() = %d, want %d", got, tt.wantState)
+			}
+			resumed := network.Rand{Seed: tt.r.Seed, State: src.State()}.GetSource().Uint64()
+			if got := src.Uint64(); got != resumed {
+				t.Errorf("Uint64() after %d draws = %v, want %v from a source resumed at State()", tt.draws, got, resumed)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	LayerTypeRNN  LayerType = "rnn"
+	LayerTypeLSTM LayerType = "lstm"
+	LayerTypeGRU  LayerType = "gru"
+)
+
+// RecurrentLayer is a Layer over sequence inputs, carrying a hidden state from each time step to the next.
+// Sequence inputs store each time step in turn, with one column for each sequence in every step, see Pass.Steps.
+// Forward starts each sequence from a zero hidden state.
+type RecurrentLayer interface {
+	Layer
+	// ForwardSequence is Forward starting from a hidden state returned by an earlier call, nil for zeros,
+	// also returning the hidden state after the last time step.
+	ForwardSequence(inputs *mat.Dense, pass Pass, state []*mat.Dense) (*mat.Dense, interface{}, []*mat.Dense, error)
+}
+
+// recurrentLayer is an Elman RNN, LSTM or GRU layer outputting its hidden state at every time step.
+// The weights and biases of each gate are stacked in rows:
+// RNN has a single tanh gate, LSTM has input, forget, cell and output gates and GRU has update, reset and candidate gates.
+type recurrentLayer struct {
+	t             LayerType
+	units         int
+	inputWeights  *mat.Dense // one row for each gate unit, one column for each input
+	hiddenWeights *mat.Dense // one row for each gate unit, one column for each unit
+	biases        *mat.Dense // single-column bias for each gate unit
+}
+
+// recurrentCache holds the values of each time step of a forward pass needed for backpropagation through time.
+type recurrentCache struct {
+	sequences int
+	inputs    []*mat.Dense // inputs of each step
+	hidden    []*mat.Dense // hidden state before each step, and after the last
+	cells     []*mat.Dense // LSTM cell state before each step, and after the last
+	gates     []*mat.Dense // activated gates of each step
+}
+
+// NewRNNLayer creates an Elman recurrent layer computing tanh(inputWeights · inputs + hiddenWeights · hidden + biases) at each step.
+// Weights have one row for each unit, and biases are a single column with a bias for each unit. Nil biases are zero.
+func NewRNNLayer(inputWeights, hiddenWeights, biases *mat.Dense) (Layer, error) {
+	return newRecurrentLayer(LayerTypeRNN, inputWeights, hiddenWeights, biases)
+}
+
+// NewLSTMLayer creates a long short-term memory layer. Weights and biases have the rows of the input, forget,
+// cell and output gates stacked in that order, with one row for each unit in each gate. Nil biases are zero.
+func NewLSTMLayer(inputWeights, hiddenWeights, biases *mat.Dense) (Layer, error) {
+	return newRecurrentLayer(LayerTypeLSTM, inputWeights, hiddenWeights, biases)
+}
+
+// NewGRULayer creates a gated recurrent unit layer. Weights and biases have the rows of the update, reset
+// and candidate gates stacked in that order, with one row for each unit in each gate. Nil biases are zero.
+// The reset gate applies to the hidden state before its weights: candidate = tanh(W · inputs + U · (reset * hidden) + b).
+func NewGRULayer(inputWeights, hiddenWeights, biases *mat.Dense) (Layer, error) {
+	return newRecurrentLayer(LayerTypeGRU, inputWeights, hiddenWeights, biases)
+}
+
+// recurrentGates returns the number of gates of a recurrent layer type.
+func recurrentGates(t LayerType) int {
+	switch t {
+	case LayerTypeLSTM:
+		return 4
+	case LayerTypeGRU:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func newRecurrentLayer(t LayerType, inputWeights, hiddenWeights, biases *mat.Dense) (Layer, error) {
+	if inputWeights == nil || hiddenWeights == nil {
+		return nil, fmt.Errorf("%s layer weights cannot be nil", t)
+	}
+	rows, units := hiddenWeights.Dims()
+	gates := recurrentGates(t)
+	if units < 1 || rows != gates*units {
+		return nil, fmt.Errorf("%s layer hidden weight dimensions %dx%d must be %d gates times the units by the units", t, rows, units, gates)
+	}
+	if ir, _ := inputWeights.Dims(); ir != rows {
+		return nil, fmt.Errorf("%s layer input weight rows %d must equal hidden weight rows %d", t, ir, rows)
+	}
+	if biases == nil {
+		biases = mat.NewDense(rows, 1, nil)
+	}
+	if rc, cc := biases.Dims(); rc != rows || cc != 1 {
+		return nil, fmt.Errorf("%s layer bias dimensions %dx%d must be %dx1", t, rc, cc, rows)
+	}
+	return recurrentLayer{t: t, units: units, inputWeights: inputWeights, hiddenWeights: hiddenWeights, biases: biases}, nil
+}
+
+func decodeRecurrentLayer(t LayerType) LayerDecoder {
+	return func(config json.RawMessage, params, state []*mat.Dense) (Layer, error) {
+		if err := unmarshalLayerConfig(t, config, nil, params, state, 3, 0); err != nil {
+			return nil, err
+		}
+		return newRecurrentLayer(t, params[0], params[1], params[2])
+	}
+}
+
+func (l recurrentLayer) Type() LayerType {
+	return l.t
+}
+
+func (l recurrentLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	outputs, cache, _, err := l.ForwardSequence(inputs, pass, nil)
+	return outputs, cache, err
+}
+
+func (l recurrentLayer) ForwardSequence(inputs *mat.Dense, pass Pass, state []*mat.Dense) (*mat.Dense, interface{}, []*mat.Dense, error) {
+	rows, cols := inputs.Dims()
+	if _, ic := l.inputWeights.Dims(); rows != ic {
+		return nil, nil, nil, fmt.Errorf("%s layer input rows %d must equal input weight columns %d", l.t, rows, ic)
+	}
+	steps, sequences, err := sequenceShape(cols, pass)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hidden, cell, err := l.initialState(state, sequences)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cache := recurrentCache{
+		sequences: sequences,
+		inputs:    make([]*mat.Dense, steps),
+		hidden:    []*mat.Dense{hidden},
+		gates:     make([]*mat.Dense, steps),
+	}
+	if cell != nil {
+		cache.cells = []*mat.Dense{cell}
+	}
+	outputs := mat.NewDense(l.units, cols, nil)
+	for t := 0; t < steps; t++ {
+		x := stepColumns(inputs, t, sequences)
+		gates, nextHidden, nextCell := l.step(x, hidden, cell)
+		outputs.Slice(0, l.units, t*sequences, (t+1)*sequences).(*mat.Dense).Copy(nextHidden)
+		cache.inputs[t] = x
+		cache.gates[t] = gates
+		cache.hidden = append(cache.hidden, nextHidden)
+		if nextCell != nil {
+			cache.cells = append(cache.cells, nextCell)
+		}
+		hidden, cell = nextHidden, nextCell
+	}
+	final := []*mat.Dense{hidden}
+	if cell != nil {
+		final = append(final, cell)
+	}
+	return outputs, cache, final, nil
+}
+
+// initialState returns the hidden state, and the cell state for LSTM layers, at the start of a batch of sequences.
+func (l recurrentLayer) initialState(state []*mat.Dense, sequences int) (*mat.Dense, *mat.Dense, error) {
+	count := 1
+	if l.t == LayerTypeLSTM {
+		count = 2
+	}
+	if state == nil {
+		state = make([]*mat.Dense, count)
+		for i := range state {
+			state[i] = mat.NewDense(l.units, sequences, nil)
+		}
+	}
+	if len(state) != count {
+		return nil, nil, fmt.Errorf("%s layer state must have %d matrices, got %d", l.t, count, len(state))
+	}
+	for _, s := range state {
+		if r, c := s.Dims(); r != l.units || c != sequences {
+			return nil, nil, fmt.Errorf("%s layer state dimensions %dx%d must be %dx%d for %d sequences", l.t, r, c, l.units, sequences, sequences)
+		}
+	}
+	if count == 2 {
+		return state[0], state[1], nil
+	}
+	return state[0], nil, nil
+}
+
+// step computes the activated gates, hidden state and LSTM cell state after one time step.
+func (l recurrentLayer) step(x, hidden, cell *mat.Dense) (*mat.Dense, *mat.Dense, *mat.Dense) {
+	units := l.units
+	_, sequences := x.Dims()
+	gates := mat.NewDense(recurrentGates(l.t)*units, sequences, nil)
+	gates.Mul(l.inputWeights, x)
+	var recurrent mat.Dense
+	if l.t == LayerTypeGRU {
+		recurrent.Mul(l.hiddenWeights.Slice(0, 2*units, 0, units), hidden)
+	} else {
+		recurrent.Mul(l.hiddenWeights, hidden)
+	}
+	rr, _ := recurrent.Dims()
+	gates.Apply(func(i, j int, v float64) float64 {
+		v += l.biases.At(i, 0)
+		if i < rr {
+			v += recurrent.At(i, j)
+		}
+		return v
+	}, gates)
+
+	next := mat.NewDense(units, sequences, nil)
+	switch l.t {
+	case LayerTypeLSTM:
+		nextCell := mat.NewDense(units, sequences, nil)
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				in, forget := logistic(gates.At(k, j)), logistic(gates.At(units+k, j))
+				candidate, out := math.Tanh(gates.At(2*units+k, j)), logistic(gates.At(3*units+k, j))
+				gates.Set(k, j, in)
+				gates.Set(units+k, j, forget)
+				gates.Set(2*units+k, j, candidate)
+				gates.Set(3*units+k, j, out)
+				c := forget*cell.At(k, j) + in*candidate
+				nextCell.Set(k, j, c)
+				next.Set(k, j, out*math.Tanh(c))
+			}
+		}
+		return gates, next, nextCell
+	case LayerTypeGRU:
+		reset := mat.NewDense(units, sequences, nil)
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				gates.Set(k, j, logistic(gates.At(k, j)))
+				gates.Set(units+k, j, logistic(gates.At(units+k, j)))
+				reset.Set(k, j, gates.At(units+k, j)*hidden.At(k, j))
+			}
+		}
+		var candidate mat.Dense
+		candidate.Mul(l.hiddenWeights.Slice(2*units, 3*units, 0, units), reset)
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				n := math.Tanh(gates.At(2*units+k, j) + candidate.At(k, j))
+				gates.Set(2*units+k, j, n)
+				update := gates.At(k, j)
+				next.Set(k, j, (1-update)*n+update*hidden.At(k, j))
+			}
+		}
+		return gates, next, nil
+	default:
+		gates.Apply(func(_, _ int, v float64) float64 { return math.Tanh(v) }, gates)
+		next.Copy(gates)
+		return gates, next, nil
+	}
+}
+
+func (l recurrentLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	c, ok := cache.(recurrentCache)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s layer cache must be from a forward pass", l.t)
+	}
+	rows, cols := grads.Dims()
+	steps := len(c.inputs)
+	if rows != l.units || cols != steps*c.sequences {
+		return nil, nil, fmt.Errorf("%s layer gradient dimensions %dx%d must be %dx%d", l.t, rows, cols, l.units, steps*c.sequences)
+	}
+	inputRows, _ := c.inputs[0].Dims()
+	inputGrads := mat.NewDense(inputRows, cols, nil)
+	inputWeightGrad := mat.NewDense(l.inputWeights.RawMatrix().Rows, inputRows, nil)
+	hiddenWeightGrad := mat.NewDense(l.hiddenWeights.RawMatrix().Rows, l.units, nil)
+	biasGrad := mat.NewDense(l.biases.RawMatrix().Rows, 1, nil)
+	// gradients of the hidden and cell state flowing back from later steps, truncated at the first step
+	hiddenGrad := mat.NewDense(l.units, c.sequences, nil)
+	cellGrad := mat.NewDense(l.units, c.sequences, nil)
+	for t := steps - 1; t >= 0; t-- {
+		hiddenGrad.Add(hiddenGrad, stepColumns(grads, t, c.sequences))
+		gateGrads, prevHiddenGrad := l.stepBackward(c, t, hiddenGrad, cellGrad, hiddenWeightGrad)
+		var w mat.Dense
+		w.Mul(gateGrads, c.inputs[t].T())
+		inputWeightGrad.Add(inputWeightGrad, &w)
+		for i := 0; i < l.biases.RawMatrix().Rows; i++ {
+			biasGrad.Set(i, 0, biasGrad.At(i, 0)+mat.Sum(gateGrads.RowView(i)))
+		}
+		var x mat.Dense
+		x.Mul(l.inputWeights.T(), gateGrads)
+		inputGrads.Slice(0, inputRows, t*c.sequences, (t+1)*c.sequences).(*mat.Dense).Copy(&x)
+		hiddenGrad = prevHiddenGrad
+	}
+	scale := 1 / float64(cols)
+	inputWeightGrad.Scale(scale, inputWeightGrad)
+	hiddenWeightGrad.Scale(scale, hiddenWeightGrad)
+	biasGrad.Scale(scale, biasGrad)
+	return inputGrads, []*mat.Dense{inputWeightGrad, hiddenWeightGrad, biasGrad}, nil
+}
+
+// stepBackward computes the loss gradient of the gates before activation at a time step from the gradient of its hidden state,
+// adding to the hidden weight gradient and returning the gradient of the previous hidden state.
+// For LSTM layers it replaces the cell state gradient with the gradient of the previous cell state.
+func (l recurrentLayer) stepBackward(c recurrentCache, t int, hiddenGrad, cellGrad, hiddenWeightGrad *mat.Dense) (*mat.Dense, *mat.Dense) {
+	units, sequences := l.units, c.sequences
+	gates, prevHidden := c.gates[t], c.hidden[t]
+	gateGrads := mat.NewDense(recurrentGates(l.t)*units, sequences, nil)
+	prevHiddenGrad := mat.NewDense(units, sequences, nil)
+	switch l.t {
+	case LayerTypeLSTM:
+		cell, prevCell := c.cells[t+1], c.cells[t]
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				in, forget, candidate, out := gates.At(k, j), gates.At(units+k, j), gates.At(2*units+k, j), gates.At(3*units+k, j)
+				tc := math.Tanh(cell.At(k, j))
+				dh := hiddenGrad.At(k, j)
+				dc := cellGrad.At(k, j) + dh*out*(1-tc*tc)
+				gateGrads.Set(k, j, dc*candidate*in*(1-in))
+				gateGrads.Set(units+k, j, dc*prevCell.At(k, j)*forget*(1-forget))
+				gateGrads.Set(2*units+k, j, dc*in*(1-candidate*candidate))
+				gateGrads.Set(3*units+k, j, dh*tc*out*(1-out))
+				cellGrad.Set(k, j, dc*forget)
+			}
+		}
+	case LayerTypeGRU:
+		resetHidden := mat.NewDense(units, sequences, nil)
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				update, n, h := gates.At(k, j), gates.At(2*units+k, j), prevHidden.At(k, j)
+				dh := hiddenGrad.At(k, j)
+				gateGrads.Set(k, j, dh*(h-n)*update*(1-update))
+				gateGrads.Set(2*units+k, j, dh*(1-update)*(1-n*n))
+				prevHiddenGrad.Set(k, j, dh*update)
+				resetHidden.Set(k, j, gates.At(units+k, j)*h)
+			}
+		}
+		candidateGrads := gateGrads.Slice(2*units, 3*units, 0, sequences)
+		candidateWeights := l.hiddenWeights.Slice(2*units, 3*units, 0, units)
+		var w, resetHiddenGrad mat.Dense
+		w.Mul(candidateGrads, resetHidden.T())
+		candidateWeightGrad := hiddenWeightGrad.Slice(2*units, 3*units, 0, units).(*mat.Dense)
+		candidateWeightGrad.Add(candidateWeightGrad, &w)
+		resetHiddenGrad.Mul(candidateWeights.T(), candidateGrads)
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				reset, h, d := gates.At(units+k, j), prevHidden.At(k, j), resetHiddenGrad.At(k, j)
+				gateGrads.Set(units+k, j, d*h*reset*(1-reset))
+				prevHiddenGrad.Set(k, j, prevHiddenGrad.At(k, j)+d*reset)
+			}
+		}
+		updateResetGrads := gateGrads.Slice(0, 2*units, 0, sequences)
+		var u, h mat.Dense
+		u.Mul(updateResetGrads, prevHidden.T())
+		updateResetWeightGrad := hiddenWeightGrad.Slice(0, 2*units, 0, units).(*mat.Dense)
+		updateResetWeightGrad.Add(updateResetWeightGrad, &u)
+		h.Mul(l.hiddenWeights.Slice(0, 2*units, 0, units).T(), updateResetGrads)
+		prevHiddenGrad.Add(prevHiddenGrad, &h)
+		return gateGrads, prevHiddenGrad
+	default:
+		for k := 0; k < units; k++ {
+			for j := 0; j < sequences; j++ {
+				v := gates.At(k, j)
+				gateGrads.Set(k, j, hiddenGrad.At(k, j)*(1-v*v))
+			}
+		}
+	}
+	var w mat.Dense
+	w.Mul(gateGrads, prevHidden.T())
+	hiddenWeightGrad.Add(hiddenWeightGrad, &w)
+	prevHiddenGrad.Mul(l.hiddenWeights.T(), gateGrads)
+	return gateGrads, prevHiddenGrad
+}
+
+func (l recurrentLayer) Params() []Param {
+	return []Param{{Value: l.inputWeights, Regularized: true}, {Value: l.hiddenWeights, Regularized: true}, {Value: l.biases}}
+}
+
+func (l recurrentLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != 3 {
+		return nil, fmt.Errorf("%s layer must have 3 params, got %d", l.t, len(params))
+	}
+	return newRecurrentLayer(l.t, params[0], params[1], params[2])
+}
+
+func (l recurrentLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	return nil, nil, nil
+}
+
+// sequenceShape returns the number of time steps and sequences of a matrix of sequence inputs with a number of columns.
+func sequenceShape(cols int, pass Pass) (int, int, error) {
+	steps := pass.Steps
+	if steps < 1 {
+		steps = 1
+	}
+	if cols%steps != 0 {
+		return 0, 0, fmt.Errorf("sequence column count %d must be a multiple of the step count %d", cols, steps)
+	}
+	return steps, cols / steps, nil
+}
+
+// stepColumns returns a copy of the columns of a time step of sequence values.
+func stepColumns(m *mat.Dense, step, sequences int) *mat.Dense {
+	rows, _ := m.Dims()
+	return mat.DenseCopyOf(m.Slice(0, rows, step*sequences, (step+1)*sequences))
+}
+
+func logistic(v float64) float64 {
+	return 1 / (1 + math.Exp(-v))
+}
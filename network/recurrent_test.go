@@ -0,0 +1,245 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// sinMatrix creates a matrix of distinct values in (-1, 1) from a seed.
+func sinMatrix(rows, cols int, seed float64) *mat.Dense {
+	m := mat.NewDense(rows, cols, nil)
+	m.Apply(func(i, j int, _ float64) float64 { return 0.8 * math.Sin(seed+float64(i*cols+j)*1.7) }, m)
+	return m
+}
+
+func TestRecurrentLayer_Backward(t *testing.T) {
+	const inputs, units, steps, sequences = 3, 2, 4, 2
+	tests := []struct {
+		name  string
+		gates int
+		new   func(inputWeights, hiddenWeights, biases *mat.Dense) (network.Layer, error)
+	}{
+		{name: "rnn", gates: 1, new: network.NewRNNLayer},
+		{name: "lstm", gates: 4, new: network.NewLSTMLayer},
+		{name: "gru", gates: 3, new: network.NewGRULayer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := tt.new(sinMatrix(tt.gates*units, inputs, 1), sinMatrix(tt.gates*units, units, 2), sinMatrix(tt.gates*units, 1, 3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLayerGradients(t, l, sinMatrix(inputs, steps*sequences, 4), network.Pass{Steps: steps})
+		})
+	}
+}
+
+func TestNewRecurrentLayer_Error(t *testing.T) {
+	tests := []struct {
+		name                                string
+		inputWeights, hiddenWeights, biases *mat.Dense
+	}{
+		{name: "should error on hidden weights without a row for each gate unit", inputWeights: mat.NewDense(2, 3, nil), hiddenWeights: mat.NewDense(2, 2, nil)},
+		{name: "should error on input weights not matching hidden weight rows", inputWeights: mat.NewDense(4, 3, nil), hiddenWeights: mat.NewDense(8, 2, nil)},
+		{name: "should error on biases not matching hidden weight rows", inputWeights: mat.NewDense(8, 3, nil), hiddenWeights: mat.NewDense(8, 2, nil), biases: mat.NewDense(2, 1, nil)},
+		{name: "should error on nil weights", hiddenWeights: mat.NewDense(8, 2, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := network.NewLSTMLayer(tt.inputWeights, tt.hiddenWeights, tt.biases); err == nil {
+				t.Errorf("NewLSTMLayer() error = nil, want error")
+			}
+		})
+	}
+}
+
+// echoSequences returns sequences of random bits, with targets echoing each bit one step later.
+func echoSequences(count, steps int) ([][][]float64, [][][]float64) {
+	inputs := make([][][]float64, count)
+	targets := make([][][]float64, count)
+	for i := range inputs {
+		inputs[i] = make([][]float64, steps)
+		targets[i] = make([][]float64, steps)
+		for s := range inputs[i] {
+			bit := float64((i*7 + s*s*3 + s) % 2)
+			inputs[i][s] = []float64{bit}
+			targets[i][s] = []float64{0}
+			if s > 0 {
+				targets[i][s] = inputs[i][s-1]
+			}
+		}
+	}
+	return inputs, targets
+}
+
+func newRecurrent(t *testing.T, spec string, bpttSteps int) *network.Network {
+	t.Helper()
+	n, err := network.NewRandom(network.Config{
+		InputCount:  1,
+		LayerSpec:   spec + ",dense:1",
+		Activations: []network.ActivationType{network.ActivationTypeSigmoid},
+		Loss:        network.LossTypeBinaryCrossEntropy,
+		Rate:        0.05,
+		Optimizer:   network.OptimizerConfig{Type: network.OptimizerTypeAdam, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+		Init:        network.InitTypeXavierUniform,
+		BPTTSteps:   bpttSteps,
+		RandSeed:    5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNetwork_TrainSequence(t *testing.T) {
+	inputs, targets := echoSequences(8, 6)
+	tests := []struct {
+		name      string
+		spec      string
+		bpttSteps int
+	}{
+		{name: "should learn to echo with an rnn layer", spec: "rnn:8"},
+		{name: "should learn to echo with an lstm layer", spec: "lstm:8"},
+		{name: "should learn to echo with a gru layer", spec: "gru:8"},
+		{name: "should learn to echo with truncated backpropagation", spec: "lstm:8", bpttSteps: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newRecurrent(t, tt.spec, tt.bpttSteps)
+			first, err := n.TrainSequence(inputs, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last float64
+			for i := 0; i < 200; i++ {
+				if last, err = n.TrainSequence(inputs, targets); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last > first/4 {
+				t.Errorf("TrainSequence() loss after training = %v, want below a quarter of the first loss %v", last, first)
+			}
+			if got, want := n.Trained(), uint64(201*len(inputs)); got != want {
+				t.Errorf("Trained() = %v, want %v", got, want)
+			}
+
+			data, err := json.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := &network.Network{}
+			if err := json.Unmarshal(data, restored); err != nil {
+				t.Fatal(err)
+			}
+			got, err := restored.PredictSequence(inputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := n.PredictSequence(inputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !mat.Equal(got, want) {
+				t.Errorf("restored PredictSequence() = %v, want %v", mat.Formatted(got), mat.Formatted(want))
+			}
+		})
+	}
+}
+
+func TestNetwork_TrainSequenceLastStep(t *testing.T) {
+	// the target is the first bit of each sequence, remembered until the last step
+	inputs := [][][]float64{{{1}, {0}, {0}}, {{0}, {0}, {0}}, {{1}, {1}, {0}}, {{0}, {1}, {0}}}
+	targets := [][][]float64{{{1}}, {{0}}, {{1}}, {{0}}}
+	n := newRecurrent(t, "gru:4", 0)
+	first, err := n.TrainSequence(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last float64
+	for i := 0; i < 200; i++ {
+		if last, err = n.TrainSequence(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last > first/4 {
+		t.Errorf("TrainSequence() loss after training = %v, want below a quarter of the first loss %v", last, first)
+	}
+
+	if _, err := n.TrainSequence(inputs, [][][]float64{{{1}, {0}}, {{0}}, {{1}}, {{0}}}); err == nil {
+		t.Errorf("TrainSequence() with a target step count matching neither the steps nor 1 error = nil, want error")
+	}
+}
+
+func TestNetwork_PredictStateful(t *testing.T) {
+	n := newRecurrent(t, "lstm:3,gru:3", 0)
+	sequence := [][]float64{{1}, {0}, {0.5}, {1}, {0}}
+	whole, err := n.PredictSequence(sequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, split := range [][]int{{2, 5}, {1, 3, 4, 5}} {
+		n.ResetState()
+		var got []float64
+		start := 0
+		for _, end := range split {
+			outputs, err := n.PredictStateful(sequence[start:end])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, outputs.RawRowView(0)...)
+			start = end
+		}
+		if want := whole.RawRowView(0); !floatsClose(got, want, 1e-12) {
+			t.Errorf("PredictStateful() split at %v = %v, want %v", split, got, want)
+		}
+	}
+
+	n.ResetState()
+	first, err := n.PredictStateful(sequence[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := first.At(0, 0), whole.At(0, 0); got != want {
+		t.Errorf("PredictStateful() after ResetState() = %v, want first step %v", got, want)
+	}
+}
+
+func floatsClose(a, b []float64, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewRandom_RecurrentSpec(t *testing.T) {
+	n, err := network.NewRandom(network.Config{InputCount: 2, LayerSpec: "lstm:3,dropout:0.2,dense:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []network.LayerType
+	for _, l := range n.Layers() {
+		got = append(got, l.Type())
+	}
+	want := []network.LayerType{network.LayerTypeLSTM, network.LayerTypeDropout, network.LayerTypeDense, network.LayerTypeActivation}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Layers() types = %v, want %v", got, want)
+	}
+	// forget gate biases start at 1
+	biases := n.Layers()[0].Params()[2].Value
+	for i, want := range []float64{0, 0, 0, 1, 1, 1, 0, 0, 0, 0, 0, 0} {
+		if got := biases.At(i, 0); got != want {
+			t.Errorf("LSTM bias %d = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TargetScaling standardizes each regression target by its mean and standard deviation during training,
+// with predictions transformed back to the scale of the targets.
+type TargetScaling struct {
+	Mean []float64
+	Std  []float64
+}
+
+// FitTargetScaling computes the mean and standard deviation of each target, a constant target has a standard deviation of 1.
+func FitTargetScaling(targets [][]float64) (TargetScaling, error) {
+	if len(targets) == 0 {
+		return TargetScaling{}, fmt.Errorf("cannot fit target scaling without targets")
+	}
+	size := len(targets[0])
+	s := TargetScaling{Mean: make([]float64, size), Std: make([]float64, size)}
+	for i, t := range targets {
+		if len(t) != size {
+			return TargetScaling{}, fmt.Errorf("target %d size %d must equal first target size %d", i, len(t), size)
+		}
+		for j, v := range t {
+			s.Mean[j] += v
+		}
+	}
+	for j := range s.Mean {
+		s.Mean[j] /= float64(len(targets))
+	}
+	for _, t := range targets {
+		for j, v := range t {
+			d := v - s.Mean[j]
+			s.Std[j] += d * d
+		}
+	}
+	for j := range s.Std {
+		s.Std[j] = math.Sqrt(s.Std[j] / float64(len(targets)))
+		if s.Std[j] == 0 {
+			s.Std[j] = 1
+		}
+	}
+	return s, nil
+}
+
+func (s TargetScaling) enabled() bool {
+	return len(s.Mean) > 0
+}
+
+func (s TargetScaling) validate(outputCount int) error {
+	if len(s.Mean) != len(s.Std) {
+		return fmt.Errorf("target scaling mean count %d must equal standard deviation count %d", len(s.Mean), len(s.Std))
+	}
+	for i, std := range s.Std {
+		if std <= 0 {
+			return fmt.Errorf("target scaling standard deviation %d must be positive, got %v", i, std)
+		}
+	}
+	if outputCount > 0 && len(s.Mean) != outputCount {
+		return fmt.Errorf("target scaling size %d must equal output count %d", len(s.Mean), outputCount)
+	}
+	return nil
+}
+
+// Transform standardizes a matrix of targets, one column per record.
+func (s TargetScaling) Transform(targets mat.Matrix) (*mat.Dense, error) {
+	return s.apply(targets, func(i int, v float64) float64 {
+		return (v - s.Mean[i]) / s.Std[i]
+	})
+}
+
+// Inverse transforms a matrix of standardized outputs back to the scale of the targets.
+func (s TargetScaling) Inverse(outputs mat.Matrix) (*mat.Dense, error) {
+	return s.apply(outputs, func(i int, v float64) float64 {
+		return v*s.Std[i] + s.Mean[i]
+	})
+}
+
+func (s TargetScaling) apply(m mat.Matrix, fn func(i int, v float64) float64) (*mat.Dense, error) {
+	rows, cols := m.Dims()
+	if rows != len(s.Mean) {
+		return nil, fmt.Errorf("row count %d must equal target scaling size %d", rows, len(s.Mean))
+	}
+	scaled := mat.NewDense(rows, cols, nil)
+	scaled.Apply(func(i, _ int, v float64) float64 { return fn(i, v) }, m)
+	return scaled, nil
+}
+
+// unscale transforms network outputs back to the scale of the targets, if the network has target scaling.
+func (n Network) unscale(outputs *mat.Dense) (*mat.Dense, error) {
+	if !n.cfg.TargetScaling.enabled() {
+		return outputs, nil
+	}
+	return n.cfg.TargetScaling.Inverse(outputs)
+}
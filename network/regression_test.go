@@ -0,0 +1,125 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFitTargetScaling(t *testing.T) {
+	s, err := network.FitTargetScaling([][]float64{{1, 5}, {3, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (network.TargetScaling{Mean: []float64{2, 5}, Std: []float64{1, 1}}); !reflect.DeepEqual(s, want) {
+		t.Errorf("FitTargetScaling() = %+v, want %+v", s, want)
+	}
+	targets := mat.NewDense(2, 2, []float64{1, 3, 5, 5})
+	scaled, err := s.Transform(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mat.NewDense(2, 2, []float64{-1, 1, 0, 0}); !mat.Equal(scaled, want) {
+		t.Errorf("Transform() = %v, want %v", mat.Formatted(scaled), mat.Formatted(want))
+	}
+	restored, err := s.Inverse(scaled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mat.Equal(restored, targets) {
+		t.Errorf("Inverse() = %v, want %v", mat.Formatted(restored), mat.Formatted(targets))
+	}
+	if _, err := s.Transform(mat.NewDense(3, 1, nil)); err == nil {
+		t.Errorf("Transform() with mismatched rows error = nil, want error")
+	}
+	if _, err := network.FitTargetScaling(nil); err == nil {
+		t.Errorf("FitTargetScaling() without targets error = nil, want error")
+	}
+	if _, err := network.FitTargetScaling([][]float64{{1}, {1, 2}}); err == nil {
+		t.Errorf("FitTargetScaling() with mismatched target sizes error = nil, want error")
+	}
+}
+
+// priceRecords returns inputs with a target far from the unit range, a linear function of the inputs.
+func priceRecords() ([][]float64, [][]float64) {
+	inputs := sinRecords(16, 2, 5)
+	targets := make([][]float64, len(inputs))
+	for i, in := range inputs {
+		targets[i] = []float64{1000 + 300*in[0] - 200*in[1]}
+	}
+	return inputs, targets
+}
+
+func TestNetwork_TrainRegression(t *testing.T) {
+	inputs, targets := priceRecords()
+	scaling, err := network.FitTargetScaling(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []network.LossType{network.LossTypeMSE, network.LossTypeMAE, network.LossTypeHuber} {
+		n, err := network.NewRandom(network.Config{
+			InputCount:    2,
+			LayerCounts:   []int{1},
+			Activations:   []network.ActivationType{network.ActivationTypeLinear},
+			Loss:          l,
+			Task:          network.TaskTypeRegression,
+			TargetScaling: scaling,
+			Rate:          0.1,
+			RandSeed:      2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			if _, err := n.TrainBatch(inputs, targets); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, in := range inputs {
+			if got := predictVector(t, n, in); math.Abs(got[0]-targets[i][0]) > 0.2*scaling.Std[0] {
+				t.Errorf("loss %v Predict() = %v, want within a fifth of the target deviation %v of %v", l, got[0], scaling.Std[0], targets[i][0])
+			}
+		}
+
+		data, err := json.Marshal(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := &network.Network{}
+		if err := json.Unmarshal(data, restored); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := predictVector(t, restored, inputs[0]), predictVector(t, n, inputs[0]); !reflect.DeepEqual(got, want) {
+			t.Errorf("loss %v restored Predict() = %v, want %v", l, got, want)
+		}
+	}
+}
+
+func TestNewRandom_RegressionError(t *testing.T) {
+	linear := []network.ActivationType{network.ActivationTypeLinear}
+	tests := []struct {
+		name string
+		cfg  network.Config
+	}{
+		{name: "unknown task", cfg: network.Config{Task: "ranking", Activations: linear}},
+		{name: "sigmoid output", cfg: network.Config{Task: network.TaskTypeRegression, Activations: []network.ActivationType{network.ActivationTypeSigmoid}}},
+		{name: "cross-entropy loss", cfg: network.Config{Task: network.TaskTypeRegression, Activations: linear, Loss: network.LossTypeBinaryCrossEntropy}},
+		{name: "heads", cfg: network.Config{Task: network.TaskTypeRegression, Activations: linear, Heads: []network.HeadConfig{{Name: "a", Size: 1, Activation: network.ActivationTypeLinear}}}},
+		{name: "scaling without regression", cfg: network.Config{Activations: linear, TargetScaling: network.TargetScaling{Mean: []float64{0}, Std: []float64{1}}}},
+		{name: "scaling size", cfg: network.Config{Task: network.TaskTypeRegression, Activations: linear, TargetScaling: network.TargetScaling{Mean: []float64{0, 0}, Std: []float64{1, 1}}}},
+		{name: "zero scaling", cfg: network.Config{Task: network.TaskTypeRegression, Activations: linear, TargetScaling: network.TargetScaling{Mean: []float64{0}, Std: []float64{0}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.InputCount, tt.cfg.LayerCounts = 2, []int{1}
+			if _, err := network.NewRandom(tt.cfg); err == nil {
+				t.Errorf("NewRandom() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// penalty computes the L1 and L2 regularization loss of the regularized params, biases are not penalized.
+func penalty(l1, l2 float64, params []Param) float64 {
+	if l1 == 0 && l2 == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, p := range params {
+		if !p.Regularized {
+			continue
+		}
+		r, c := p.Value.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				v := p.Value.At(i, j)
+				sum += l1*math.Abs(v) + 0.5*l2*v*v
+			}
+		}
+	}
+	return sum
+}
+
+// regularize adds the L1 and L2 penalty gradients to the gradients of the regularized params.
+func regularize(l1, l2 float64, params []Param, grads []*mat.Dense) ([]*mat.Dense, error) {
+	if l1 == 0 && l2 == 0 {
+		return grads, nil
+	}
+	regularized := make([]*mat.Dense, len(grads))
+	for i, grad := range grads {
+		if !params[i].Regularized {
+			regularized[i] = grad
+			continue
+		}
+		w := params[i].Value
+		r, err := matutil.Apply(func(i, j int, g float64) float64 {
+			v := w.At(i, j)
+			return g + l1*sign(v) + l2*v
+		}, grad)
+		if err != nil {
+			return nil, fmt.Errorf("regularizing param %d gradient: %v", i, err)
+		}
+		regularized[i] = r
+	}
+	return regularized, nil
+}
+
+// decay shrinks the values of the regularized params towards zero by a fraction, independently of their gradients.
+func decay(fraction float64, params []Param, values []*mat.Dense) ([]*mat.Dense, error) {
+	if fraction == 0 {
+		return values, nil
+	}
+	decayed := make([]*mat.Dense, len(values))
+	for i, v := range values {
+		if !params[i].Regularized {
+			decayed[i] = v
+			continue
+		}
+		d, err := matutil.Scale(1-fraction, v)
+		if err != nil {
+			return nil, fmt.Errorf("decaying param %d: %v", i, err)
+		}
+		decayed[i] = d
+	}
+	return decayed, nil
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,78 @@
+package network_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func TestNetwork_TrainRegularization(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        network.Config
+		wantWeight float64
+		wantLoss   float64
+		wantErr    bool
+	}{
+		{
+			name:       "should leave weights unchanged without regularization",
+			cfg:        network.Config{Rate: 0.1},
+			wantWeight: 0.5,
+		},
+		{
+			name:       "should shrink weights by the l1 penalty gradient",
+			cfg:        network.Config{Rate: 0.1, L1: 1},
+			wantWeight: 0.4,
+			wantLoss:   4 * 0.5,
+		},
+		{
+			name:       "should shrink weights by the l2 penalty gradient",
+			cfg:        network.Config{Rate: 0.1, L2: 1},
+			wantWeight: 0.45,
+			wantLoss:   4 * 0.5 * 0.25,
+		},
+		{
+			name:       "should decay weights scaled by the learning rate",
+			cfg:        network.Config{Rate: 0.5, WeightDecay: 0.5},
+			wantWeight: 0.375,
+		},
+		{
+			name:    "should error on a negative coefficient",
+			cfg:     network.Config{Rate: 0.1, L2: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.InputCount = 2
+			cfg.LayerCounts = []int{2}
+			cfg.Activations = []network.ActivationType{network.ActivationTypeLinear}
+			cfg.Init = network.InitTypeConstant
+			cfg.InitValue = 0.5
+			n, err := network.NewRandom(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRandom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// zero inputs and targets have no loss gradient, so only regularization changes the weights
+			loss, err := n.TrainBatch([][]float64{{0, 0}}, [][]float64{{0, 0}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(loss-tt.wantLoss) > 1e-12 {
+				t.Errorf("TrainBatch() loss = %v, want %v", loss, tt.wantLoss)
+			}
+			for _, col := range linearWeights(t, n) {
+				for _, v := range col {
+					if math.Abs(v-tt.wantWeight) > 1e-12 {
+						t.Errorf("weight after training = %v, want %v", v, tt.wantWeight)
+					}
+				}
+			}
+		})
+	}
+}
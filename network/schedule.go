@@ -0,0 +1,195 @@
+package network
+
+import (
+	"fmt"
+	"math"
+)
+
+// ScheduleType selects how the learning rate changes during training.
+type ScheduleType string
+
+const (
+	ScheduleTypeNone        ScheduleType = ""
+	ScheduleTypeConstant    ScheduleType = "constant"
+	ScheduleTypeStep        ScheduleType = "step"
+	ScheduleTypeExponential ScheduleType = "exponential"
+	ScheduleTypeCosine      ScheduleType = "cosine"
+	ScheduleTypePlateau     ScheduleType = "plateau"
+)
+
+// ScheduleTypes lists all learning rate schedule types.
+var ScheduleTypes = []ScheduleType{
+	ScheduleTypeConstant,
+	ScheduleTypeStep,
+	ScheduleTypeExponential,
+	ScheduleTypeCosine,
+	ScheduleTypePlateau,
+}
+
+// ScheduleConfig learning rate schedule constructor, zero values are replaced by common defaults.
+type ScheduleConfig struct {
+	Type        ScheduleType
+	StepSize    uint64  // epochs between step decays, or epochs in the first cosine cycle, default 10
+	Gamma       float64 // rate multiplier for each step or plateau decay default 0.1, or each epoch of exponential decay default 0.95
+	MinRate     float64 // lowest rate reached by cosine annealing and plateau reductions
+	CycleMult   float64 // cosine cycle length multiplier after each warm restart, default 1
+	Patience    uint64  // epochs without improvement before a plateau reduction, default 10
+	Threshold   float64 // minimum loss decrease counted as a plateau improvement
+	WarmupSteps uint64  // training steps to linearly increase the rate from zero, applies to any schedule
+}
+
+// ScheduleState is the position within a learning rate schedule, saved with the model so resumed training continues it.
+type ScheduleState struct {
+	Step     uint64  // training updates completed
+	Epoch    uint64  // epochs completed
+	Observed bool    // whether an epoch loss has been observed yet
+	Best     float64 // best observed epoch loss
+	Wait     uint64  // epochs since the best observed loss
+	Reduced  uint64  // plateau reductions so far
+}
+
+// Schedule computes the learning rate for each training step.
+type Schedule interface {
+	// Rate returns the learning rate at the current schedule position.
+	Rate(base float64, state ScheduleState) float64
+	// Observe returns the schedule state updated with the mean loss of a completed epoch.
+	Observe(state ScheduleState, loss float64) ScheduleState
+}
+
+// NewSchedule constructs a learning rate schedule from a config, an empty type is a constant rate.
+func NewSchedule(cfg ScheduleConfig) (Schedule, error) {
+	if cfg.StepSize == 0 {
+		cfg.StepSize = 10
+	}
+	if cfg.CycleMult == 0 {
+		cfg.CycleMult = 1
+	}
+	if cfg.Patience == 0 {
+		cfg.Patience = 10
+	}
+	var s Schedule
+	switch cfg.Type {
+	case ScheduleTypeNone, ScheduleTypeConstant:
+		s = constantSchedule{}
+	case ScheduleTypeStep:
+		if cfg.Gamma == 0 {
+			cfg.Gamma = 0.1
+		}
+		s = stepSchedule{cfg}
+	case ScheduleTypeExponential:
+		if cfg.Gamma == 0 {
+			cfg.Gamma = 0.95
+		}
+		s = exponentialSchedule{cfg}
+	case ScheduleTypeCosine:
+		if cfg.CycleMult < 1 {
+			return nil, fmt.Errorf("cosine cycle multiplier %v must be at least 1", cfg.CycleMult)
+		}
+		s = cosineSchedule{cfg}
+	case ScheduleTypePlateau:
+		if cfg.Gamma == 0 {
+			cfg.Gamma = 0.1
+		}
+		s = plateauSchedule{cfg}
+	default:
+		return nil, fmt.Errorf("unknown learning rate schedule type '%s'", cfg.Type)
+	}
+	if cfg.WarmupSteps > 0 {
+		s = warmupSchedule{s, cfg.WarmupSteps}
+	}
+	return s, nil
+}
+
+type constantSchedule struct{}
+
+func (s constantSchedule) Rate(base float64, _ ScheduleState) float64 {
+	return base
+}
+
+func (s constantSchedule) Observe(state ScheduleState, _ float64) ScheduleState {
+	return state
+}
+
+// stepSchedule multiplies the rate by gamma every StepSize epochs.
+type stepSchedule struct {
+	cfg ScheduleConfig
+}
+
+func (s stepSchedule) Rate(base float64, state ScheduleState) float64 {
+	return base * math.Pow(s.cfg.Gamma, float64(state.Epoch/s.cfg.StepSize))
+}
+
+func (s stepSchedule) Observe(state ScheduleState, _ float64) ScheduleState {
+	return state
+}
+
+// exponentialSchedule multiplies the rate by gamma every epoch.
+type exponentialSchedule struct {
+	cfg ScheduleConfig
+}
+
+func (s exponentialSchedule) Rate(base float64, state ScheduleState) float64 {
+	return base * math.Pow(s.cfg.Gamma, float64(state.Epoch))
+}
+
+func (s exponentialSchedule) Observe(state ScheduleState, _ float64) ScheduleState {
+	return state
+}
+
+// cosineSchedule anneals the rate from base to MinRate along a cosine curve over each cycle,
+// then restarts at the base rate with the next cycle CycleMult times longer.
+type cosineSchedule struct {
+	cfg ScheduleConfig
+}
+
+func (s cosineSchedule) Rate(base float64, state ScheduleState) float64 {
+	position := float64(state.Epoch)
+	length := float64(s.cfg.StepSize)
+	for position >= length {
+		position -= length
+		length *= s.cfg.CycleMult
+	}
+	return s.cfg.MinRate + (base-s.cfg.MinRate)*(1+math.Cos(math.Pi*position/length))/2
+}
+
+func (s cosineSchedule) Observe(state ScheduleState, _ float64) ScheduleState {
+	return state
+}
+
+// plateauSchedule multiplies the rate by gamma each time the epoch loss hasn't improved for Patience epochs.
+type plateauSchedule struct {
+	cfg ScheduleConfig
+}
+
+func (s plateauSchedule) Rate(base float64, state ScheduleState) float64 {
+	return math.Max(s.cfg.MinRate, base*math.Pow(s.cfg.Gamma, float64(state.Reduced)))
+}
+
+func (s plateauSchedule) Observe(state ScheduleState, loss float64) ScheduleState {
+	if !state.Observed || loss < state.Best-s.cfg.Threshold {
+		state.Observed = true
+		state.Best = loss
+		state.Wait = 0
+		return state
+	}
+	state.Wait++
+	if state.Wait >= s.cfg.Patience {
+		state.Reduced++
+		state.Wait = 0
+	}
+	return state
+}
+
+// warmupSchedule linearly increases the rate of another schedule from zero over the first steps.
+type warmupSchedule struct {
+	Schedule
+	steps uint64
+}
+
+func (s warmupSchedule) Rate(base float64, state ScheduleState) float64 {
+	rate := s.Schedule.Rate(base, state)
+	if state.Step >= s.steps {
+		return rate
+	}
+	return rate * float64(state.Step+1) / float64(s.steps)
+}
@@ -0,0 +1,101 @@
+package network_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func TestNewSchedule_Rate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     network.ScheduleConfig
+		state   network.ScheduleState
+		want    float64
+		wantErr bool
+	}{
+		{name: "should default to a constant rate", state: network.ScheduleState{Step: 100, Epoch: 100}, want: 1},
+		{name: "step should not decay before the first step", cfg: network.ScheduleConfig{Type: network.ScheduleTypeStep, StepSize: 5}, state: network.ScheduleState{Epoch: 4}, want: 1},
+		{name: "step should decay every step size epochs", cfg: network.ScheduleConfig{Type: network.ScheduleTypeStep, StepSize: 5, Gamma: 0.5}, state: network.ScheduleState{Epoch: 10}, want: 0.25},
+		{name: "exponential should decay every epoch", cfg: network.ScheduleConfig{Type: network.ScheduleTypeExponential, Gamma: 0.5}, state: network.ScheduleState{Epoch: 3}, want: 0.125},
+		{name: "cosine should start at the base rate", cfg: network.ScheduleConfig{Type: network.ScheduleTypeCosine, StepSize: 10}, state: network.ScheduleState{Epoch: 0}, want: 1},
+		{name: "cosine should reach halfway in the middle of a cycle", cfg: network.ScheduleConfig{Type: network.ScheduleTypeCosine, StepSize: 10, MinRate: 0.2}, state: network.ScheduleState{Epoch: 5}, want: 0.6},
+		{name: "cosine should restart at the base rate", cfg: network.ScheduleConfig{Type: network.ScheduleTypeCosine, StepSize: 10}, state: network.ScheduleState{Epoch: 10}, want: 1},
+		{name: "cosine should lengthen cycles after restarts", cfg: network.ScheduleConfig{Type: network.ScheduleTypeCosine, StepSize: 10, CycleMult: 2}, state: network.ScheduleState{Epoch: 20}, want: 0.5},
+		{name: "cosine should error on a shrinking cycle multiplier", cfg: network.ScheduleConfig{Type: network.ScheduleTypeCosine, CycleMult: 0.5}, wantErr: true},
+		{name: "plateau should decay by each reduction", cfg: network.ScheduleConfig{Type: network.ScheduleTypePlateau, Gamma: 0.5}, state: network.ScheduleState{Reduced: 2}, want: 0.25},
+		{name: "plateau should not decay below the min rate", cfg: network.ScheduleConfig{Type: network.ScheduleTypePlateau, MinRate: 0.3, Gamma: 0.5}, state: network.ScheduleState{Reduced: 2}, want: 0.3},
+		{name: "warmup should scale the first step", cfg: network.ScheduleConfig{WarmupSteps: 4}, state: network.ScheduleState{Step: 0}, want: 0.25},
+		{name: "warmup should reach the base rate at the last warmup step", cfg: network.ScheduleConfig{WarmupSteps: 4}, state: network.ScheduleState{Step: 3}, want: 1},
+		{name: "warmup should apply to other schedules", cfg: network.ScheduleConfig{Type: network.ScheduleTypeExponential, Gamma: 0.5, WarmupSteps: 4}, state: network.ScheduleState{Step: 1, Epoch: 1}, want: 0.25},
+		{name: "should error on an unknown schedule", cfg: network.ScheduleConfig{Type: "unknown"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := network.NewSchedule(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := s.Rate(1, tt.state); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Rate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSchedule_Plateau(t *testing.T) {
+	s, err := network.NewSchedule(network.ScheduleConfig{Type: network.ScheduleTypePlateau, Patience: 2, Threshold: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	losses := []float64{1, 0.8, 0.75, 0.72, 0.5, 0.5, 0.5, 0.5}
+	wantReduced := []uint64{0, 0, 0, 1, 1, 1, 2, 2}
+	var state network.ScheduleState
+	for i, loss := range losses {
+		state = s.Observe(state, loss)
+		if state.Reduced != wantReduced[i] {
+			t.Errorf("Observe() after loss %d = %v reduced %d times, want %d", i, loss, state.Reduced, wantReduced[i])
+		}
+	}
+}
+
+func TestNetwork_ResumeSchedule(t *testing.T) {
+	n, err := network.NewRandom(network.Config{
+		InputCount:  2,
+		LayerCounts: []int{1},
+		Rate:        1,
+		Schedule:    network.ScheduleConfig{Type: network.ScheduleTypeStep, StepSize: 1, Gamma: 0.5, WarmupSteps: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Rate(); got != 0.5 {
+		t.Errorf("Rate() during warmup = %v, want 0.5", got)
+	}
+	for i := 0; i < 3; i++ {
+		if err := n.Train([]float64{1, 0}, []float64{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n.EndEpoch(0.1)
+	n.EndEpoch(0.1)
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Epochs(); got != 2 {
+		t.Errorf("restored Epochs() = %v, want 2", got)
+	}
+	if got := restored.Rate(); got != 0.25 {
+		t.Errorf("restored Rate() = %v, want 0.25", got)
+	}
+}
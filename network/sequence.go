@@ -0,0 +1,163 @@
+package network
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TrainSequence trains the network with a batch of sequences of equal length, using truncated backpropagation through time.
+// Inputs and targets hold each sequence as a list of time steps. The targets of a sequence are either one for each step,
+// or a single target for the last step only. Each update backpropagates through up to the configured BPTTSteps,
+// carrying the hidden state of recurrent layers forward into the next update.
+// With last-step targets, steps before the last update only advance the hidden state.
+// Returns the mean loss of the batch, including any L1 and L2 weight penalties.
+func (n *Network) TrainSequence(inputs, targets [][][]float64) (float64, error) {
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("sequence batch cannot be empty")
+	}
+	if len(inputs) != len(targets) {
+		return 0, fmt.Errorf("input batch size %d must equal target batch size %d", len(inputs), len(targets))
+	}
+	steps := len(inputs[0])
+	if steps == 0 {
+		return 0, fmt.Errorf("sequences must have at least one step")
+	}
+	lastOnly := len(targets[0]) == 1 && steps > 1
+	for i := range inputs {
+		if len(inputs[i]) != steps {
+			return 0, fmt.Errorf("sequence %d step count %d must equal first sequence step count %d", i, len(inputs[i]), steps)
+		}
+		if lastOnly && len(targets[i]) != 1 || !lastOnly && len(targets[i]) != steps {
+			return 0, fmt.Errorf("sequence %d target step count %d must equal its step count %d, or 1 for the last step", i, len(targets[i]), steps)
+		}
+	}
+	chunk := n.cfg.BPTTSteps
+	if chunk == 0 || chunk > steps {
+		chunk = steps
+	}
+	var states [][]*mat.Dense
+	lossSum, count := 0.0, 0
+	for start := 0; start < steps; start += chunk {
+		end := start + chunk
+		if end > steps {
+			end = steps
+		}
+		chunkInputs, err := sequenceMatrix(inputs, start, end)
+		if err != nil {
+			return 0, fmt.Errorf("creating input matrix: %v", err)
+		}
+		if lastOnly && end < steps {
+			if _, _, states, err = propagateSequence(chunkInputs, n.layers, Pass{Steps: end - start}, states); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		chunkTargets, err := sequenceMatrix(targets, start, end)
+		if lastOnly {
+			chunkTargets, err = sequenceMatrix(targets, 0, 1)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("creating target matrix: %v", err)
+		}
+		var loss float64
+		if loss, states, err = n.step(chunkInputs, chunkTargets, end-start, states); err != nil {
+			return 0, err
+		}
+		_, cols := chunkTargets.Dims()
+		lossSum += loss * float64(cols)
+		count += cols
+	}
+	n.cfg.Trained += uint64(len(inputs))
+	return lossSum / float64(count), nil
+}
+
+// PredictSequence outputs a column for each time step of a sequence of inputs, starting from a zero hidden state.
+func (n Network) PredictSequence(inputs [][]float64) (*mat.Dense, error) {
+	m, err := sequenceMatrix([][][]float64{inputs}, 0, len(inputs))
+	if err != nil {
+		return nil, fmt.Errorf("creating matrix from input data: %v", err)
+	}
+	outputs, _, err := propagateForwards(m, n.layers, Pass{Steps: len(inputs)})
+	if err != nil {
+		return nil, err
+	}
+	return n.unscale(outputs)
+}
+
+// PredictStateful outputs a column for each time step of a sequence of inputs, continuing from the hidden state
+// left by the previous PredictStateful call, so a long sequence can be predicted a few steps at a time.
+func (n *Network) PredictStateful(inputs [][]float64) (*mat.Dense, error) {
+	m, err := sequenceMatrix([][][]float64{inputs}, 0, len(inputs))
+	if err != nil {
+		return nil, fmt.Errorf("creating matrix from input data: %v", err)
+	}
+	outputs, _, states, err := propagateSequence(m, n.layers, Pass{Steps: len(inputs)}, n.states)
+	if err != nil {
+		return nil, err
+	}
+	n.states = states
+	return n.unscale(outputs)
+}
+
+// ResetState clears the hidden state carried between PredictStateful calls, so the next call starts a new sequence.
+func (n *Network) ResetState() {
+	n.states = nil
+}
+
+// sequenceMatrix creates a matrix from the time steps of a batch of sequences in [start, end),
+// storing each step in turn with one column for each sequence.
+func sequenceMatrix(sequences [][][]float64, start, end int) (*mat.Dense, error) {
+	if len(sequences) == 0 || start >= end {
+		return nil, fmt.Errorf("sequence batch cannot be empty")
+	}
+	if len(sequences[0]) < end {
+		return nil, fmt.Errorf("sequence 0 has %d steps, needs %d", len(sequences[0]), end)
+	}
+	rows := len(sequences[0][start])
+	if rows == 0 {
+		return nil, fmt.Errorf("sequence steps cannot be empty")
+	}
+	batch := len(sequences)
+	m := mat.NewDense(rows, (end-start)*batch, nil)
+	for b, sequence := range sequences {
+		if len(sequence) < end {
+			return nil, fmt.Errorf("sequence %d has %d steps, needs %d", b, len(sequence), end)
+		}
+		for t := start; t < end; t++ {
+			if len(sequence[t]) != rows {
+				return nil, fmt.Errorf("sequence %d step %d length %d must equal %d", b, t, len(sequence[t]), rows)
+			}
+			m.SetCol((t-start)*batch+b, sequence[t])
+		}
+	}
+	return m, nil
+}
+
+// lastColumns returns the outputs matching a matrix of targets, which are either for every output column
+// or for the columns of the last time step only.
+func lastColumns(outputs, targets *mat.Dense) (*mat.Dense, error) {
+	rows, cols := outputs.Dims()
+	_, targetCols := targets.Dims()
+	if targetCols == cols {
+		return outputs, nil
+	}
+	if targetCols == 0 || targetCols > cols || cols%targetCols != 0 {
+		return nil, fmt.Errorf("target column count %d must equal output column count %d or one time step of it", targetCols, cols)
+	}
+	return mat.DenseCopyOf(outputs.Slice(0, rows, cols-targetCols, cols)), nil
+}
+
+// padColumns expands the loss gradients of the last time step to all output columns, with zeros for the earlier steps.
+// The gradients are scaled by the step count, since layers average their param gradients over all columns.
+func padColumns(grads, outputs *mat.Dense) *mat.Dense {
+	rows, gradCols := grads.Dims()
+	_, cols := outputs.Dims()
+	if gradCols == cols {
+		return grads
+	}
+	padded := mat.NewDense(rows, cols, nil)
+	last := padded.Slice(0, rows, cols-gradCols, cols).(*mat.Dense)
+	last.Scale(float64(cols/gradCols), grads)
+	return padded
+}
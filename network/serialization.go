@@ -9,15 +9,31 @@ import (
 	"gonum.org/v1/gonum/mat"
 )
 
+// storageVersion is the current model file format version.
+// Version 1 stored dense layer weights only, version 2 added layer biases, version 3 added hidden layer normalization,
+// version 4 stores the network as a generic stack of layers.
+const storageVersion = 4
+
 func (n *Network) MarshalJSON() ([]byte, error) {
-	layers := make([]jsonMatrix, 0, len(n.weights))
-	for _, weight := range n.weights {
-		layers = append(layers, jsonMatrix{M: weight})
+	stack := make([]jsonLayer, 0, len(n.layers))
+	for i, l := range n.layers {
+		config, state, err := l.MarshalLayer()
+		if err != nil {
+			return nil, fmt.Errorf("marshaling layer %d %s: %v", i, l.Type(), err)
+		}
+		stack = append(stack, jsonLayer{
+			Type:   l.Type(),
+			Config: config,
+			Params: newJSONMatrices(paramValues(l.Params())),
+			State:  newJSONMatrices(state),
+		})
 	}
 	s := storage{
-		Version: 1,
-		Config:  n.cfg,
-		Layers:  layers,
+		Version:   storageVersion,
+		Config:    n.cfg,
+		Stack:     stack,
+		Optimizer: newJSONOptimizerState(n.optimizer.State()),
+		Schedule:  n.position,
 	}
 	return json.Marshal(s)
 }
@@ -27,13 +43,38 @@ func (n *Network) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
-	weights := make([]*mat.Dense, 0, len(s.Layers))
-	for _, l := range s.Layers {
-		weights = append(weights, l.M)
+	var newNetwork *Network
+	var err error
+	optimizerState := OptimizerState{}
+	if s.Optimizer != nil {
+		optimizerState = s.Optimizer.state()
 	}
-	newNetwork, err := New(s.Config, weights)
-	if err != nil {
-		return err
+	switch s.Version {
+	case 1, 2, 3:
+		if newNetwork, err = s.legacyNetwork(); err != nil {
+			return err
+		}
+		optimizerState = legacyOptimizerState(newNetwork.layers, optimizerState)
+	case storageVersion:
+		layers := make([]Layer, 0, len(s.Stack))
+		for i, l := range s.Stack {
+			layer, err := decodeLayer(l.Type, l.Config, jsonMatrixValues(l.Params), jsonMatrixValues(l.State))
+			if err != nil {
+				return fmt.Errorf("restoring layer %d: %v", i, err)
+			}
+			layers = append(layers, layer)
+		}
+		if newNetwork, err = NewFromLayers(s.Config, layers); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported model version %d", s.Version)
+	}
+	newNetwork.position = s.Schedule
+	if s.Optimizer != nil {
+		if err := newNetwork.optimizer.SetState(optimizerState); err != nil {
+			return fmt.Errorf("restoring optimizer state: %v", err)
+		}
 	}
 	*n = *newNetwork
 	return nil
@@ -42,7 +83,161 @@ func (n *Network) UnmarshalJSON(data []byte) error {
 type storage struct {
 	Version uint32
 	Config  Config
-	Layers  []jsonMatrix
+	// Layers, Biases and Normalization are the dense layer weights, biases and normalizations of version 1 to 3 models
+	Layers        []jsonMatrix        `json:",omitempty"`
+	Biases        []jsonMatrix        `json:",omitempty"`
+	Normalization []jsonNormalization `json:",omitempty"`
+	// Stack is the layers of version 4 models
+	Stack []jsonLayer `json:",omitempty"`
+	// Optimizer state is optional, models saved without it resume training with fresh optimizer state
+	Optimizer *jsonOptimizerState `json:",omitempty"`
+	// Schedule position is optional, models saved without it start the learning rate schedule from the beginning
+	Schedule ScheduleState
+}
+
+type jsonLayer struct {
+	Type   LayerType
+	Config json.RawMessage `json:",omitempty"`
+	Params []jsonMatrix    `json:",omitempty"`
+	State  []jsonMatrix    `json:",omitempty"`
+}
+
+type jsonNormalization struct {
+	Gamma    *jsonMatrix
+	Beta     *jsonMatrix
+	Mean     *jsonMatrix `json:",omitempty"`
+	Variance *jsonMatrix `json:",omitempty"`
+}
+
+// legacyNetwork restores a version 1 to 3 model from its config and dense layer weights, biases and normalizations.
+func (s storage) legacyNetwork() (*Network, error) {
+	weights := jsonMatrixValues(s.Layers)
+	biases := zeroBiases(s.Config.LayerCounts)
+	if s.Version >= 2 {
+		biases = jsonMatrixValues(s.Biases)
+	}
+	if s.Version < 3 && len(s.Normalization) > 0 {
+		return nil, fmt.Errorf("version %d models cannot store normalization", s.Version)
+	}
+	n, err := NewWithBiases(s.Config, weights, biases)
+	if err != nil {
+		return nil, err
+	}
+	stored := s.Normalization
+	for i, l := range n.layers {
+		norm, ok := l.(*normLayer)
+		if !ok {
+			continue
+		}
+		if len(stored) == 0 {
+			return nil, fmt.Errorf("missing normalization for layer %d", i)
+		}
+		sn := stored[0]
+		stored = stored[1:]
+		if sn.Gamma == nil || sn.Beta == nil {
+			return nil, fmt.Errorf("layer %d normalization must have a scale and shift", i)
+		}
+		mean, variance := norm.mean, norm.variance
+		if sn.Mean != nil && sn.Variance != nil {
+			mean, variance = sn.Mean.M, sn.Variance.M
+		} else if norm.t == NormTypeBatch {
+			return nil, fmt.Errorf("layer %d batch normalization must have running statistics", i)
+		}
+		if n.layers[i], err = norm.restore(sn.Gamma.M, sn.Beta.M, mean, variance); err != nil {
+			return nil, fmt.Errorf("layer %d: %v", i, err)
+		}
+	}
+	if len(stored) != 0 {
+		return nil, fmt.Errorf("%d normalizations stored for layers without normalization", len(stored))
+	}
+	return n, nil
+}
+
+// legacyOptimizerState reorders the optimizer slots of a version 2 or 3 model, which stored the weights of every dense layer,
+// then their biases, then the scale and shift of each normalization, to the stack order of the restored layers.
+func legacyOptimizerState(layers []Layer, state OptimizerState) OptimizerState {
+	var denseCount int
+	for _, l := range layers {
+		if _, ok := l.(denseLayer); ok {
+			denseCount++
+		}
+	}
+	var order []int
+	dense, norm := 0, 0
+	for _, l := range layers {
+		switch l.(type) {
+		case denseLayer:
+			order = append(order, dense, denseCount+dense)
+			dense++
+		case *normLayer:
+			order = append(order, 2*denseCount+2*norm, 2*denseCount+2*norm+1)
+			norm++
+		}
+	}
+	slots := make([][]*mat.Dense, 0, len(state.Slots))
+	for _, slot := range state.Slots {
+		if len(slot) != len(order) {
+			// leave mismatched state for SetState to reject
+			return state
+		}
+		reordered := make([]*mat.Dense, len(order))
+		for i, old := range order {
+			reordered[i] = slot[old]
+		}
+		slots = append(slots, reordered)
+	}
+	return OptimizerState{Step: state.Step, Slots: slots}
+}
+
+func newJSONMatrices(ms []*mat.Dense) []jsonMatrix {
+	if len(ms) == 0 {
+		return nil
+	}
+	jsonMatrices := make([]jsonMatrix, 0, len(ms))
+	for _, m := range ms {
+		jsonMatrices = append(jsonMatrices, jsonMatrix{M: m})
+	}
+	return jsonMatrices
+}
+
+func jsonMatrixValues(jsonMatrices []jsonMatrix) []*mat.Dense {
+	ms := make([]*mat.Dense, 0, len(jsonMatrices))
+	for _, m := range jsonMatrices {
+		ms = append(ms, m.M)
+	}
+	return ms
+}
+
+type jsonOptimizerState struct {
+	Step  uint64
+	Slots [][]jsonMatrix
+}
+
+func newJSONOptimizerState(state OptimizerState) *jsonOptimizerState {
+	if state.Step == 0 && len(state.Slots) == 0 {
+		return nil
+	}
+	slots := make([][]jsonMatrix, 0, len(state.Slots))
+	for _, slot := range state.Slots {
+		params := make([]jsonMatrix, 0, len(slot))
+		for _, m := range slot {
+			params = append(params, jsonMatrix{M: m})
+		}
+		slots = append(slots, params)
+	}
+	return &jsonOptimizerState{Step: state.Step, Slots: slots}
+}
+
+func (s jsonOptimizerState) state() OptimizerState {
+	slots := make([][]*mat.Dense, 0, len(s.Slots))
+	for _, slot := range s.Slots {
+		params := make([]*mat.Dense, 0, len(slot))
+		for _, m := range slot {
+			params = append(params, m.M)
+		}
+		slots = append(slots, params)
+	}
+	return OptimizerState{Step: s.Step, Slots: slots}
 }
 
 type jsonMatrix struct {
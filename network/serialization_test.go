@@ -0,0 +1,195 @@
+package network_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func TestNetwork_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     *network.Network
+		train [][2][]float64
+		input []float64
+	}{
+		{
+			name: "should restore an untrained network",
+			n: func() *network.Network {
+				n, err := network.NewRandom(network.Config{
+					InputCount:  3,
+					LayerCounts: []int{2, 1},
+					Rate:        0.1,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return n
+			}(),
+			input: []float64{1, 2, 3},
+		},
+		{
+			name: "should restore trained weights and biases",
+			n: func() *network.Network {
+				n, err := network.NewRandom(network.Config{
+					InputCount:  3,
+					LayerCounts: []int{2, 2, 1},
+					Rate:        1,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return n
+			}(),
+			train: [][2][]float64{
+				{{1, 2, 3}, {1}},
+				{{3, 2, 1}, {0}},
+			},
+			input: []float64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, datum := range tt.train {
+				if err := tt.n.Train(datum[0], datum[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := json.Marshal(tt.n)
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			restored := &network.Network{}
+			if err := json.Unmarshal(data, restored); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			want := predictVector(t, tt.n, tt.input)
+			got := predictVector(t, restored, tt.input)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("restored Predict() = %v, want %v", got, want)
+			}
+			if restored.Trained() != tt.n.Trained() {
+				t.Errorf("restored Trained() = %v, want %v", restored.Trained(), tt.n.Trained())
+			}
+		})
+	}
+}
+
+func TestNetwork_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		input   []float64
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name:  "should load a version 1 model with zero biases",
+			data:  `{"Version":1,"Config":{"InputCount":1,"LayerCounts":[1],"Activation":1,"Rate":0.1},"Layers":["` + matrixBase64(t, 1, 1, []float64{0}) + `"]}`,
+			input: []float64{1},
+			want:  []float64{0.5},
+		},
+		{
+			name:  "should load a version 2 model with biases",
+			data:  `{"Version":2,"Config":{"InputCount":1,"LayerCounts":[1],"Activation":1,"Rate":0.1},"Layers":["` + matrixBase64(t, 1, 1, []float64{1}) + `"],"Biases":["` + matrixBase64(t, 1, 1, []float64{-1}) + `"]}`,
+			input: []float64{1},
+			want:  []float64{0.5},
+		},
+		{
+			name:    "should error on a version 2 model with missing biases",
+			data:    `{"Version":2,"Config":{"InputCount":1,"LayerCounts":[1],"Activation":1,"Rate":0.1},"Layers":["` + matrixBase64(t, 1, 1, []float64{1}) + `"]}`,
+			wantErr: true,
+		},
+		{
+			name: "should load a version 3 model with layer normalization",
+			data: `{"Version":3,"Config":{"InputCount":1,"LayerCounts":[2,1],"Activations":["linear","linear"],"Normalization":["layer"],"Rate":0.1},` +
+				`"Layers":["` + matrixBase64(t, 2, 1, []float64{1, -1}) + `","` + matrixBase64(t, 1, 2, []float64{1, 1}) + `"],` +
+				`"Biases":["` + matrixBase64(t, 2, 1, []float64{0, 0}) + `","` + matrixBase64(t, 1, 1, []float64{0}) + `"],` +
+				`"Normalization":[{"Gamma":"` + matrixBase64(t, 2, 1, []float64{0, 0}) + `","Beta":"` + matrixBase64(t, 2, 1, []float64{1, 2}) + `"}]}`,
+			input: []float64{1},
+			want:  []float64{3},
+		},
+		{
+			name: "should error on a version 3 model with normalization layers and no normalization state",
+			data: `{"Version":3,"Config":{"InputCount":1,"LayerCounts":[2,1],"Activations":["linear","linear"],"Normalization":["layer"],"Rate":0.1},` +
+				`"Layers":["` + matrixBase64(t, 2, 1, []float64{1, -1}) + `","` + matrixBase64(t, 1, 2, []float64{1, 1}) + `"],` +
+				`"Biases":["` + matrixBase64(t, 2, 1, []float64{0, 0}) + `","` + matrixBase64(t, 1, 1, []float64{0}) + `"]}`,
+			wantErr: true,
+		},
+		{
+			name: "should error on a version 2 model storing normalization",
+			data: `{"Version":2,"Config":{"InputCount":1,"LayerCounts":[1],"Activation":1,"Rate":0.1},"Layers":["` + matrixBase64(t, 1, 1, []float64{1}) + `"],"Biases":["` + matrixBase64(t, 1, 1, []float64{-1}) + `"],` +
+				`"Normalization":[{"Gamma":"` + matrixBase64(t, 1, 1, []float64{1}) + `","Beta":"` + matrixBase64(t, 1, 1, []float64{0}) + `"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "should error on an unknown model version",
+			data:    `{"Version":99,"Config":{"InputCount":1,"LayerCounts":[1],"Activation":1,"Rate":0.1},"Layers":["` + matrixBase64(t, 1, 1, []float64{1}) + `"]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &network.Network{}
+			err := json.Unmarshal([]byte(tt.data), n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := predictVector(t, n, tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Predict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetwork_UnmarshalJSONLegacyOptimizer(t *testing.T) {
+	// version 2 stored optimizer slots for the weights of each layer, then the biases: a velocity of 1 for the first layer bias
+	zero, one := matrixBase64(t, 1, 1, []float64{0}), matrixBase64(t, 1, 1, []float64{1})
+	data := `{"Version":2,"Config":{"InputCount":1,"LayerCounts":[1,1],"Activations":["linear","linear"],"Rate":0.1,"Optimizer":{"Type":1,"Momentum":0.5}},` +
+		`"Layers":["` + one + `","` + one + `"],"Biases":["` + zero + `","` + zero + `"],` +
+		`"Optimizer":{"Step":1,"Slots":[["` + zero + `","` + zero + `","` + one + `","` + zero + `"]]}}`
+	n := &network.Network{}
+	if err := json.Unmarshal([]byte(data), n); err != nil {
+		t.Fatal(err)
+	}
+	// a zero input and matching target have no gradient, so only the restored velocity moves the first layer bias
+	if err := n.Train([]float64{0}, []float64{0}); err != nil {
+		t.Fatal(err)
+	}
+	if got := predictVector(t, n, []float64{0}); !reflect.DeepEqual(got, []float64{0.5}) {
+		t.Errorf("Predict() after resuming = %v, want [0.5]", got)
+	}
+}
+
+func predictVector(t *testing.T, n *network.Network, input []float64) []float64 {
+	t.Helper()
+	got, err := n.Predict(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := matutil.ToVector(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func matrixBase64(t *testing.T, r, c int, data []float64) string {
+	t.Helper()
+	m, err := matutil.New(r, c, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
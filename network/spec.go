@@ -0,0 +1,253 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// LayerSpec describes one layer of a network built by NewRandom from a layer spec string.
+type LayerSpec struct {
+	Type    LayerType
+	Units   int     // dense or recurrent layer units, or convolution filters
+	Height  int     // convolution kernel or pooling window height
+	Width   int     // convolution kernel or pooling window width
+	Stride  int     // convolution or pooling stride, defaults to 1 for convolution and the window size for pooling
+	Padding int     // convolution zero padding
+	Rate    float64 // dropout rate
+}
+
+// weighted returns whether the spec is a layer with weights, followed by an activation layer.
+func (s LayerSpec) weighted() bool {
+	return s.Type == LayerTypeDense || s.Type == LayerTypeConv2D
+}
+
+// ParseLayerSpec parses a comma-separated layer spec like "conv:8x3x3,pool:2,flatten,dense:100,dense:10" or "lstm:32,dense:1".
+// Layers are written as:
+//
+//	conv:FILTERSxHEIGHTxWIDTH[:STRIDE[:PADDING]], or conv:FILTERSxSIZE for a square kernel
+//	pool:SIZE[:STRIDE] or maxpool:SIZE[:STRIDE] for max pooling, avgpool:SIZE[:STRIDE] for average pooling
+//	flatten
+//	dense:UNITS
+//	rnn:UNITS, lstm:UNITS or gru:UNITS
+//	dropout:RATE
+func ParseLayerSpec(spec string) ([]LayerSpec, error) {
+	var specs []LayerSpec
+	for i, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		s, err := parseLayerSpecItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("layer spec item %d '%s': %v", i, item, err)
+		}
+		specs = append(specs, s)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("layer spec '%s' has no layers", spec)
+	}
+	return specs, nil
+}
+
+func parseLayerSpecItem(item string) (LayerSpec, error) {
+	parts := strings.Split(item, ":")
+	name, args := parts[0], parts[1:]
+	switch name {
+	case "conv":
+		if len(args) < 1 || len(args) > 3 {
+			return LayerSpec{}, fmt.Errorf("convolution takes a FILTERSxHEIGHTxWIDTH size and optional stride and padding")
+		}
+		dims, err := parseInts(strings.Split(args[0], "x"))
+		if err != nil {
+			return LayerSpec{}, err
+		}
+		s := LayerSpec{Type: LayerTypeConv2D, Stride: 1}
+		switch len(dims) {
+		case 2:
+			s.Units, s.Height, s.Width = dims[0], dims[1], dims[1]
+		case 3:
+			s.Units, s.Height, s.Width = dims[0], dims[1], dims[2]
+		default:
+			return LayerSpec{}, fmt.Errorf("convolution size '%s' must be FILTERSxHEIGHTxWIDTH or FILTERSxSIZE", args[0])
+		}
+		rest, err := parseInts(args[1:])
+		if err != nil {
+			return LayerSpec{}, err
+		}
+		if len(rest) > 0 {
+			s.Stride = rest[0]
+		}
+		if len(rest) > 1 {
+			s.Padding = rest[1]
+		}
+		return s, nil
+	case "pool", "maxpool", "avgpool":
+		if len(args) < 1 || len(args) > 2 {
+			return LayerSpec{}, fmt.Errorf("pooling takes a window size and optional stride")
+		}
+		values, err := parseInts(args)
+		if err != nil {
+			return LayerSpec{}, err
+		}
+		s := LayerSpec{Type: LayerTypeMaxPool2D, Height: values[0], Width: values[0], Stride: values[0]}
+		if name == "avgpool" {
+			s.Type = LayerTypeAvgPool2D
+		}
+		if len(values) > 1 {
+			s.Stride = values[1]
+		}
+		return s, nil
+	case "flatten":
+		if len(args) > 0 {
+			return LayerSpec{}, fmt.Errorf("flatten takes no arguments")
+		}
+		return LayerSpec{Type: LayerTypeFlatten}, nil
+	case "dense", "rnn", "lstm", "gru":
+		if len(args) != 1 {
+			return LayerSpec{}, fmt.Errorf("%s takes a unit count", name)
+		}
+		values, err := parseInts(args)
+		if err != nil {
+			return LayerSpec{}, err
+		}
+		return LayerSpec{Type: LayerType(name), Units: values[0]}, nil
+	case "dropout":
+		if len(args) != 1 {
+			return LayerSpec{}, fmt.Errorf("dropout takes a rate")
+		}
+		rate, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return LayerSpec{}, fmt.Errorf("invalid rate '%s'", args[0])
+		}
+		return LayerSpec{Type: LayerTypeDropout, Rate: rate}, nil
+	default:
+		return LayerSpec{}, fmt.Errorf("unknown layer '%s', must be 'conv', 'pool', 'maxpool', 'avgpool', 'flatten', 'dense', 'rnn', 'lstm', 'gru' or 'dropout'", name)
+	}
+}
+
+func parseInts(strs []string) ([]int, error) {
+	values := make([]int, 0, len(strs))
+	for _, s := range strs {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer '%s'", s)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// specLayers creates the layer stack of a parsed layer spec with random weights and zero biases,
+// adding an activation layer after each dense and convolution layer.
+func specLayers(cfg Config, specs []LayerSpec, src rand.Source) ([]Layer, error) {
+	weightedCount := 0
+	for _, s := range specs {
+		if s.weighted() {
+			weightedCount++
+		}
+	}
+	activations := activationTypes(cfg, weightedCount)
+	if len(activations) != weightedCount {
+		return nil, fmt.Errorf("layer activation count '%d' must be equal dense and convolution layer count '%d'", len(activations), weightedCount)
+	}
+	shape := cfg.InputShape
+	if shape == (ImageShape{}) {
+		shape = ImageShape{Channels: cfg.InputCount, Height: 1, Width: 1}
+	}
+	if shape.Size() != cfg.InputCount {
+		return nil, fmt.Errorf("input shape %s size %d must equal input count %d", shape, shape.Size(), cfg.InputCount)
+	}
+	layers := make([]Layer, 0, len(specs)+weightedCount)
+	weighted := 0
+	for i, s := range specs {
+		var l Layer
+		var err error
+		switch s.Type {
+		case LayerTypeConv2D:
+			cols := shape.Channels * s.Height * s.Width
+			var weights *mat.Dense
+			if weights, err = initWeights(cfg.Init, cfg.InitValue, s.Units, cols, src); err != nil {
+				return nil, fmt.Errorf("layer spec %d initializing weights with %d rows and %d columns: %v", i, s.Units, cols, err)
+			}
+			l, err = NewConv2DLayer(shape, s.Height, s.Width, s.Stride, s.Padding, weights, nil)
+		case LayerTypeMaxPool2D:
+			l, err = NewMaxPool2DLayer(shape, s.Height, s.Stride)
+		case LayerTypeAvgPool2D:
+			l, err = NewAvgPool2DLayer(shape, s.Height, s.Stride)
+		case LayerTypeFlatten:
+			l = NewFlattenLayer()
+		case LayerTypeDense:
+			var weights *mat.Dense
+			if weights, err = initWeights(cfg.Init, cfg.InitValue, s.Units, shape.Size(), src); err != nil {
+				return nil, fmt.Errorf("layer spec %d initializing weights with %d rows and %d columns: %v", i, s.Units, shape.Size(), err)
+			}
+			l, err = NewDenseLayer(weights, nil)
+		case LayerTypeRNN, LayerTypeLSTM, LayerTypeGRU:
+			l, err = specRecurrentLayer(cfg, s, shape.Size(), src)
+		case LayerTypeDropout:
+			l, err = NewDropoutLayer(s.Rate)
+		default:
+			return nil, fmt.Errorf("layer spec %d has unsupported type '%s'", i, s.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("layer spec %d: %v", i, err)
+		}
+		layers = append(layers, l)
+		shape = specOutputShape(l, shape)
+		if !s.weighted() {
+			continue
+		}
+		a, err := newActivationLayer(cfg, activations[weighted], weighted == weightedCount-1)
+		if err != nil {
+			return nil, fmt.Errorf("layer spec %d: %v", i, err)
+		}
+		layers = append(layers, a)
+		weighted++
+	}
+	return layers, nil
+}
+
+// specRecurrentLayer creates a recurrent layer with random weights and zero biases, except LSTM forget gate biases of 1
+// so the cell state is remembered from the start of training.
+func specRecurrentLayer(cfg Config, s LayerSpec, inputs int, src rand.Source) (Layer, error) {
+	rows := recurrentGates(s.Type) * s.Units
+	inputWeights, err := initWeights(cfg.Init, cfg.InitValue, rows, inputs, src)
+	if err != nil {
+		return nil, fmt.Errorf("initializing input weights with %d rows and %d columns: %v", rows, inputs, err)
+	}
+	hiddenWeights, err := initWeights(cfg.Init, cfg.InitValue, rows, s.Units, src)
+	if err != nil {
+		return nil, fmt.Errorf("initializing hidden weights with %d rows and %d columns: %v", rows, s.Units, err)
+	}
+	biases := mat.NewDense(rows, 1, nil)
+	if s.Type == LayerTypeLSTM {
+		for i := s.Units; i < 2*s.Units; i++ {
+			biases.Set(i, 0, 1)
+		}
+	}
+	return newRecurrentLayer(s.Type, inputWeights, hiddenWeights, biases)
+}
+
+// specOutputShape returns the image shape of a layer's outputs given the shape of its inputs,
+// layers without image outputs produce a flat column of values.
+func specOutputShape(l Layer, in ImageShape) ImageShape {
+	switch l := l.(type) {
+	case conv2DLayer:
+		return l.out
+	case pool2DLayer:
+		return l.out
+	case denseLayer:
+		rows, _ := l.weights.Dims()
+		return ImageShape{Channels: rows, Height: 1, Width: 1}
+	case recurrentLayer:
+		return ImageShape{Channels: l.units, Height: 1, Width: 1}
+	case flattenLayer:
+		return ImageShape{Channels: in.Size(), Height: 1, Width: 1}
+	default:
+		return in
+	}
+}
@@ -0,0 +1,268 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/benjohns1/neural-net-go/matutil"
+	"github.com/benjohns1/neural-net-go/network/autodiff"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TapeForward computes the outputs of a layer on an autodiff tape from its inputs and params,
+// so its gradients are derived automatically.
+type TapeForward func(inputs *autodiff.Node, params []*autodiff.Node, pass Pass) *autodiff.Node
+
+// tapeLayer is a layer defined only by its forward computation.
+type tapeLayer struct {
+	t       LayerType
+	params  []Param
+	forward TapeForward
+}
+
+// tapeCache holds the tape nodes of a forward pass.
+type tapeCache struct {
+	inputs  *autodiff.Node
+	params  []*autodiff.Node
+	outputs *autodiff.Node
+}
+
+// NewTapeLayer creates a layer of a type from its params and forward computation, with gradients derived by autodiff.
+// Register a LayerDecoder calling NewTapeLayer with the restored params so networks containing the layer can be loaded.
+func NewTapeLayer(t LayerType, params []Param, forward TapeForward) (Layer, error) {
+	if t == "" {
+		return nil, fmt.Errorf("layer type cannot be empty")
+	}
+	if forward == nil {
+		return nil, fmt.Errorf("layer '%s' forward computation cannot be nil", t)
+	}
+	for i, p := range params {
+		if p.Value == nil {
+			return nil, fmt.Errorf("layer '%s' param %d cannot be nil", t, i)
+		}
+	}
+	return tapeLayer{t: t, params: params, forward: forward}, nil
+}
+
+func (l tapeLayer) Type() LayerType {
+	return l.t
+}
+
+func (l tapeLayer) Forward(inputs *mat.Dense, pass Pass) (*mat.Dense, interface{}, error) {
+	return forwardTape(inputs, paramValues(l.params), pass, l.forward)
+}
+
+func (l tapeLayer) Backward(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	return backwardTape(grads, cache)
+}
+
+func (l tapeLayer) Params() []Param {
+	return l.params
+}
+
+func (l tapeLayer) WithParams(params []*mat.Dense) (Layer, error) {
+	if len(params) != len(l.params) {
+		return nil, fmt.Errorf("%s layer must have %d params, got %d", l.t, len(l.params), len(params))
+	}
+	updated := make([]Param, len(params))
+	for i, p := range l.params {
+		updated[i] = Param{Value: params[i], Regularized: p.Regularized}
+	}
+	return NewTapeLayer(l.t, updated, l.forward)
+}
+
+func (l tapeLayer) MarshalLayer() (json.RawMessage, []*mat.Dense, error) {
+	return nil, nil, nil
+}
+
+// forwardTape records a forward computation on a new tape, returning its outputs and the tape nodes as the cache.
+func forwardTape(inputs *mat.Dense, params []*mat.Dense, pass Pass, forward TapeForward) (*mat.Dense, interface{}, error) {
+	tape := autodiff.NewTape()
+	c := tapeCache{inputs: tape.Var(inputs), params: make([]*autodiff.Node, len(params))}
+	for i, p := range params {
+		c.params[i] = tape.Var(p)
+	}
+	c.outputs = forward(c.inputs, c.params, pass)
+	if err := tape.Err(); err != nil {
+		return nil, nil, err
+	}
+	return c.outputs.Value(), c, nil
+}
+
+// backwardTape propagates the loss gradient of the outputs of a recorded forward computation,
+// returning the input gradient and the param gradients averaged over the batch.
+func backwardTape(grads *mat.Dense, cache interface{}) (*mat.Dense, []*mat.Dense, error) {
+	c, ok := cache.(tapeCache)
+	if !ok {
+		return nil, nil, fmt.Errorf("layer cache must be its forward tape")
+	}
+	if err := c.outputs.BackwardFrom(grads); err != nil {
+		return nil, nil, err
+	}
+	_, batchSize := grads.Dims()
+	paramGrads := make([]*mat.Dense, len(c.params))
+	for i, p := range c.params {
+		paramGrads[i] = p.Grad()
+		paramGrads[i].Scale(1/float64(batchSize), paramGrads[i])
+	}
+	return c.inputs.Grad(), paramGrads, nil
+}
+
+// TapeActivation computes an activation on an autodiff tape, so its derivative is derived automatically.
+type TapeActivation func(inputs *autodiff.Node) *autodiff.Node
+
+// tapeActivation adapts a TapeActivation to an Activation.
+type tapeActivation struct {
+	forward TapeActivation
+}
+
+// RegisterTapeActivation registers a custom activation defined only by its forward computation under a name,
+// so it can be configured and loaded from model files.
+func RegisterTapeActivation(name string, forward TapeActivation) error {
+	if forward == nil {
+		return fmt.Errorf("activation '%s' forward computation cannot be nil", name)
+	}
+	return RegisterActivation(name, tapeActivation{forward: forward})
+}
+
+// Value computes the activation of a single value, or NaN if the forward computation fails, use MatrixValue for its error.
+func (a tapeActivation) Value(v float64) float64 {
+	outputs, err := a.MatrixValue(mat.NewDense(1, 1, []float64{v}))
+	if err != nil {
+		return math.NaN()
+	}
+	return outputs.At(0, 0)
+}
+
+// MatrixValue computes the activation of each column.
+func (a tapeActivation) MatrixValue(inputs mat.Matrix) (*mat.Dense, error) {
+	tape := autodiff.NewTape()
+	outputs := a.forward(tape.Var(mat.DenseCopyOf(inputs)))
+	if err := tape.Err(); err != nil {
+		return nil, err
+	}
+	return outputs.Value(), nil
+}
+
+// MatrixDerivative can't be computed from the outputs alone, use InputDerivative instead.
+func (a tapeActivation) MatrixDerivative(mat.Matrix) (*mat.Dense, error) {
+	return nil, fmt.Errorf("tape activation derivatives need the activation inputs")
+}
+
+// InputDerivative computes the derivative of each output with respect to its input, for element-wise activations.
+func (a tapeActivation) InputDerivative(inputs mat.Matrix) (*mat.Dense, error) {
+	r, c := inputs.Dims()
+	return a.gradient(inputs, mat.NewDense(r, c, matutil.FillArray(r*c, 1)))
+}
+
+// MatrixGradient can't be computed from the outputs alone, activation layers use gradient with the inputs instead.
+func (a tapeActivation) MatrixGradient(_, _ mat.Matrix) (*mat.Dense, error) {
+	return nil, fmt.Errorf("tape activation gradients need the activation inputs")
+}
+
+// gradient computes the loss gradient of the activation inputs from the inputs and the loss gradient of the outputs.
+func (a tapeActivation) gradient(inputs, grads mat.Matrix) (*mat.Dense, error) {
+	tape := autodiff.NewTape()
+	x := tape.Var(mat.DenseCopyOf(inputs))
+	if err := a.forward(x).BackwardFrom(mat.DenseCopyOf(grads)); err != nil {
+		return nil, err
+	}
+	return x.Grad(), nil
+}
+
+// TapeLoss computes the mean loss of all columns as a 1x1 node on an autodiff tape, so its derivative is derived automatically.
+type TapeLoss func(targets, outputs *autodiff.Node) *autodiff.Node
+
+// tapeLoss adapts a TapeLoss to a Loss.
+type tapeLoss struct {
+	forward TapeLoss
+}
+
+// NewTapeLoss creates a Loss defined only by its forward computation.
+func NewTapeLoss(forward TapeLoss) (Loss, error) {
+	if forward == nil {
+		return nil, fmt.Errorf("loss forward computation cannot be nil")
+	}
+	return tapeLoss{forward: forward}, nil
+}
+
+// Value computes the mean loss of all columns.
+func (l tapeLoss) Value(targets, outputs mat.Matrix) (float64, error) {
+	tape := autodiff.NewTape()
+	loss := l.forward(tape.Var(mat.DenseCopyOf(targets)), tape.Var(mat.DenseCopyOf(outputs)))
+	if err := tape.Err(); err != nil {
+		return 0, err
+	}
+	if r, c := loss.Value().Dims(); r != 1 || c != 1 {
+		return 0, fmt.Errorf("loss dimensions %dx%d must be 1x1", r, c)
+	}
+	return loss.Value().At(0, 0), nil
+}
+
+// Derivative computes the loss gradient of each output element, without averaging over the columns.
+func (l tapeLoss) Derivative(targets, outputs mat.Matrix) (*mat.Dense, error) {
+	tape := autodiff.NewTape()
+	o := tape.Var(mat.DenseCopyOf(outputs))
+	loss := l.forward(tape.Var(mat.DenseCopyOf(targets)), o)
+	if err := loss.Backward(); err != nil {
+		return nil, err
+	}
+	if r, c := loss.Value().Dims(); r != 1 || c != 1 {
+		return nil, fmt.Errorf("loss dimensions %dx%d must be 1x1", r, c)
+	}
+	_, cols := outputs.Dims()
+	grads := o.Grad()
+	grads.Scale(float64(cols), grads)
+	return grads, nil
+}
+
+var lossRegistry = struct {
+	sync.RWMutex
+	losses map[string]Loss
+}{
+	losses: map[string]Loss{},
+}
+
+// RegisterLoss registers a custom loss under a name, so networks configured with the name in Config.LossName
+// can be created and loaded from model files.
+func RegisterLoss(name string, l Loss) error {
+	if name == "" {
+		return fmt.Errorf("loss name cannot be empty")
+	}
+	if l == nil {
+		return fmt.Errorf("loss '%s' cannot be nil", name)
+	}
+	lossRegistry.Lock()
+	defer lossRegistry.Unlock()
+	if _, ok := lossRegistry.losses[name]; ok {
+		return fmt.Errorf("loss '%s' is already registered", name)
+	}
+	lossRegistry.losses[name] = l
+	return nil
+}
+
+// LossNames returns the names of all registered custom losses in alphabetical order.
+func LossNames() []string {
+	lossRegistry.RLock()
+	defer lossRegistry.RUnlock()
+	names := make([]string, 0, len(lossRegistry.losses))
+	for name := range lossRegistry.losses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func registeredLoss(name string) (Loss, error) {
+	lossRegistry.RLock()
+	defer lossRegistry.RUnlock()
+	l, ok := lossRegistry.losses[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown loss '%s'", name)
+	}
+	return l, nil
+}
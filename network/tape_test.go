@@ -0,0 +1,251 @@
+package network_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/autodiff"
+	"github.com/benjohns1/neural-net-go/network/loss"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// gatedForward computes tanh(weights · inputs + biases) * sigmoid(gates · inputs), defined only by its forward computation.
+func gatedForward(inputs *autodiff.Node, params []*autodiff.Node, _ network.Pass) *autodiff.Node {
+	return params[0].Mul(inputs).AddColumn(params[1]).Tanh().MulElem(params[2].Mul(inputs).Sigmoid())
+}
+
+func newGatedLayer(params []*mat.Dense) (network.Layer, error) {
+	if len(params) != 3 {
+		return nil, fmt.Errorf("gated layer must have 3 params, got %d", len(params))
+	}
+	return network.NewTapeLayer("test-gated", []network.Param{
+		{Value: params[0], Regularized: true},
+		{Value: params[1]},
+		{Value: params[2], Regularized: true},
+	}, gatedForward)
+}
+
+// softsign is x / (1 + |x|), defined only by its forward computation.
+func softsign(x *autodiff.Node) *autodiff.Node {
+	return x.DivElem(x.Abs().Map(func(v float64) float64 { return 1 + v }, func(_, _ float64) float64 { return 1 }))
+}
+
+// bounded is the identity for inputs up to 100 and fails its forward computation on larger inputs.
+func bounded(x *autodiff.Node) *autodiff.Node {
+	if mat.Max(x.Value()) > 100 {
+		return x.BroadcastRows(0)
+	}
+	return x.Scale(1)
+}
+
+// halfSquaredError is the MSE loss defined only by its forward computation.
+func halfSquaredError(targets, outputs *autodiff.Node) *autodiff.Node {
+	_, cols := outputs.Value().Dims()
+	return outputs.Sub(targets).Square().Sum().Scale(0.5 / float64(cols))
+}
+
+func init() {
+	err := network.RegisterLayer("test-gated", func(_ json.RawMessage, params, _ []*mat.Dense) (network.Layer, error) {
+		return newGatedLayer(params)
+	})
+	if err != nil {
+		panic(err)
+	}
+	if err := network.RegisterTapeActivation("test-softsign", softsign); err != nil {
+		panic(err)
+	}
+	if err := network.RegisterTapeActivation("test-bounded", bounded); err != nil {
+		panic(err)
+	}
+	l, err := network.NewTapeLoss(halfSquaredError)
+	if err != nil {
+		panic(err)
+	}
+	if err := network.RegisterLoss("test-half-squared-error", l); err != nil {
+		panic(err)
+	}
+}
+
+func TestNewTapeLayer(t *testing.T) {
+	l, err := newGatedLayer([]*mat.Dense{sinMatrix(2, 3, 1), sinMatrix(2, 1, 2), sinMatrix(2, 3, 3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLayerGradients(t, l, sinMatrix(3, 4, 4), network.Pass{})
+
+	updated, err := l.WithParams([]*mat.Dense{sinMatrix(2, 3, 5), sinMatrix(2, 1, 6), sinMatrix(2, 3, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var regularized []bool
+	for _, p := range updated.Params() {
+		regularized = append(regularized, p.Regularized)
+	}
+	if want := []bool{true, false, true}; !reflect.DeepEqual(regularized, want) {
+		t.Errorf("WithParams() regularized params = %v, want %v", regularized, want)
+	}
+	if _, err := l.WithParams(nil); err == nil {
+		t.Errorf("WithParams() with missing params error = nil, want error")
+	}
+
+	must := newLayer(t)
+	n, err := network.NewFromLayers(network.Config{InputCount: 3, Rate: 0.1}, []network.Layer{
+		l,
+		must(network.NewDenseLayer(sinMatrix(1, 2, 8), nil)),
+		must(network.NewActivationLayer(network.ActivationTypeLinear)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []float64{0.5, -0.2, 0.1}
+	before := predictVector(t, n, input)
+	for i := 0; i < 20; i++ {
+		if err := n.Train(input, []float64{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after := predictVector(t, n, input)
+	if math.Abs(after[0]-1) >= math.Abs(before[0]-1) {
+		t.Errorf("Predict() after training = %v, want closer to target 1 than %v", after, before)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := predictVector(t, restored, input); !reflect.DeepEqual(got, after) {
+		t.Errorf("restored Predict() = %v, want %v", got, after)
+	}
+}
+
+func TestRegisterTapeActivation(t *testing.T) {
+	l, err := network.NewActivationLayer("test-softsign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := sinMatrix(3, 2, 1)
+	outputs, _, err := l.Forward(inputs, network.Pass{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 2; j++ {
+			x := inputs.At(i, j)
+			if got, want := outputs.At(i, j), x/(1+math.Abs(x)); got != want {
+				t.Errorf("Forward() at %d,%d = %v, want %v", i, j, got, want)
+			}
+		}
+	}
+	checkLayerGradients(t, l, inputs, network.Pass{})
+
+	a, err := network.NewActivation("test-softsign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.Value(-3), -0.75; got != want {
+		t.Errorf("Value(-3) = %v, want %v", got, want)
+	}
+	boundedActivation, err := network.NewActivation("test-bounded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := boundedActivation.Value(1000); !math.IsNaN(got) {
+		t.Errorf("Value(1000) of a boundedActivation forward computation = %v, want NaN", got)
+	}
+	boundedLayer, err := network.NewActivationLayer("test-bounded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := boundedLayer.Forward(mat.NewDense(1, 1, []float64{1000}), network.Pass{}); err == nil {
+		t.Errorf("Forward() of a boundedActivation forward computation error = nil, want error")
+	}
+	if err := network.RegisterTapeActivation("test-nil-forward", nil); err == nil {
+		t.Errorf("RegisterTapeActivation() with a nil forward computation error = nil, want error")
+	}
+}
+
+func TestNewTapeLoss(t *testing.T) {
+	l, err := network.NewTapeLoss(halfSquaredError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := sinMatrix(3, 4, 1)
+	outputs := sinMatrix(3, 4, 2)
+	got, err := l.Value(targets, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := loss.MSE{}.Value(targets, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-want) > 1e-12 {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+	gotDerivative, err := l.Derivative(targets, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDerivative, err := loss.MSE{}.Derivative(targets, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mat.EqualApprox(gotDerivative, wantDerivative, 1e-12) {
+		t.Errorf("Derivative() = %v, want %v", mat.Formatted(gotDerivative), mat.Formatted(wantDerivative))
+	}
+}
+
+func TestRegisterLoss(t *testing.T) {
+	if err := network.RegisterLoss("test-half-squared-error", loss.MSE{}); err == nil {
+		t.Errorf("RegisterLoss() with a duplicate name error = nil, want error")
+	}
+	if _, err := network.NewRandom(network.Config{InputCount: 2, LayerCounts: []int{1}, LossName: "test-unknown"}); err == nil {
+		t.Errorf("NewRandom() with an unregistered loss name error = nil, want error")
+	}
+
+	newNetwork := func(cfg network.Config) *network.Network {
+		cfg.InputCount, cfg.LayerCounts, cfg.Rate, cfg.RandSeed = 2, []int{3, 1}, 0.5, 2
+		n, err := network.NewRandom(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	custom := newNetwork(network.Config{LossName: "test-half-squared-error"})
+	builtIn := newNetwork(network.Config{Loss: network.LossTypeMSE})
+	inputs := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	targets := [][]float64{{1}, {1}, {0}}
+	for i := 0; i < 5; i++ {
+		got, err := custom.TrainBatch(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := builtIn.TrainBatch(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want) > 1e-12 {
+			t.Errorf("TrainBatch() %d loss with the registered loss = %v, want built-in loss %v", i, got, want)
+		}
+	}
+
+	data, err := json.Marshal(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &network.Network{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Config().LossName; got != "test-half-squared-error" {
+		t.Errorf("restored LossName = %v, want test-half-squared-error", got)
+	}
+}
@@ -0,0 +1,58 @@
+package network
+
+import "fmt"
+
+// TaskType selects what the outputs of a network predict.
+type TaskType string
+
+const (
+	TaskTypeClassification TaskType = ""
+	TaskTypeRegression     TaskType = "regression"
+	TaskTypeMultiLabel     TaskType = "multi-label"
+)
+
+// validateTask checks the output activation and loss suit the task, and only the task using them has target scaling or thresholds.
+func validateTask(cfg Config, layers []Layer, outputCount int) error {
+	switch cfg.Task {
+	case TaskTypeClassification, TaskTypeRegression, TaskTypeMultiLabel:
+	default:
+		return fmt.Errorf("unknown task type '%s'", cfg.Task)
+	}
+	if cfg.Task != TaskTypeRegression && cfg.TargetScaling.enabled() {
+		return fmt.Errorf("target scaling needs a regression task")
+	}
+	if cfg.Task != TaskTypeMultiLabel && len(cfg.Thresholds) > 0 {
+		return fmt.Errorf("decision thresholds need a multi-label task")
+	}
+	if cfg.Task == TaskTypeClassification {
+		return nil
+	}
+	if len(cfg.Heads) > 0 {
+		return fmt.Errorf("%s task cannot have heads, set the activation and loss of each head instead", cfg.Task)
+	}
+	output := ActivationTypeLinear
+	losses := []LossType{LossTypeMSE, LossTypeMAE, LossTypeHuber}
+	if cfg.Task == TaskTypeMultiLabel {
+		output = ActivationTypeSigmoid
+		losses = []LossType{LossTypeBinaryCrossEntropy}
+	}
+	if a, ok := layers[len(layers)-1].(activationLayer); ok && a.t != output && !(a.t == ActivationTypeNone && output == ActivationTypeSigmoid) {
+		return fmt.Errorf("%s task needs a %s output activation, got '%s'", cfg.Task, output, a.t)
+	}
+	if cfg.LossName == "" && !containsLoss(losses, cfg.Loss) {
+		return fmt.Errorf("%s task cannot use loss type %v", cfg.Task, cfg.Loss)
+	}
+	if cfg.Task == TaskTypeRegression {
+		return cfg.TargetScaling.validate(outputCount)
+	}
+	return validateThresholds(cfg.Thresholds, outputCount)
+}
+
+func containsLoss(losses []LossType, l LossType) bool {
+	for _, other := range losses {
+		if other == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+// csvPreset reads every column of a numeric dataset, assigning the columns set by -target-columns, or by -head-columns
+// to the targets of each head, and all other columns to the inputs in order. Without either the last column is the target.
+// With -labels the single target column holds a set of labels for multi-label classification.
+func csvPreset(cfg *runConfig) error {
+	columnCount, err := csvColumnCount(cfg.DataSetFile)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Heads) > 0 && len(cfg.TargetColumns) > 0 {
+		return fmt.Errorf("-target-columns cannot be used with -heads, set -head-columns instead")
+	}
+	if len(cfg.Heads) == 0 && len(cfg.TargetColumns) == 0 {
+		cfg.TargetColumns = []int{columnCount - 1}
+	}
+	layout := csvLayout{
+		columnCount: columnCount,
+		targets:     cfg.TargetColumns,
+		heads:       cfg.Heads,
+		headColumns: cfg.HeadColumns,
+		delimiter:   cfg.LabelDelimiter,
+	}
+	cfg.OutputCount = len(cfg.TargetColumns)
+	if len(cfg.Labels) > 0 {
+		if cfg.Task != network.TaskTypeMultiLabel {
+			return fmt.Errorf("-labels needs -task=multi-label")
+		}
+		if len(cfg.TargetColumns) != 1 {
+			return fmt.Errorf("-labels needs a single target column holding label sets, got %d", len(cfg.TargetColumns))
+		}
+		layout.labels = make(map[string]int, len(cfg.Labels))
+		for i, l := range cfg.Labels {
+			if _, ok := layout.labels[l]; ok {
+				return fmt.Errorf("label '%s' is set more than once", l)
+			}
+			layout.labels[l] = i
+		}
+		cfg.OutputCount = len(cfg.Labels)
+	}
+	targetColumns := map[int]bool{}
+	for _, c := range cfg.TargetColumns {
+		if c >= columnCount {
+			return fmt.Errorf("target column %d is outside the %d dataset columns", c, columnCount)
+		}
+		if targetColumns[c] {
+			return fmt.Errorf("target column %d is set more than once", c)
+		}
+		targetColumns[c] = true
+	}
+	for name, cols := range cfg.HeadColumns {
+		for _, c := range cols {
+			if c >= columnCount {
+				return fmt.Errorf("head '%s' column %d is outside the %d dataset columns", name, c, columnCount)
+			}
+			targetColumns[c] = true
+		}
+	}
+	for c := 0; c < columnCount; c++ {
+		if !targetColumns[c] {
+			layout.inputs = append(layout.inputs, c)
+		}
+	}
+	if len(layout.inputs) == 0 {
+		return fmt.Errorf("dataset has no input columns left after the target columns")
+	}
+	cfg.InputCount = len(layout.inputs)
+	for _, h := range cfg.Heads {
+		cfg.OutputCount += h.Size
+	}
+	cfg.TestLogBatch = 1000
+	cfg.TrainLogBatch = 1000
+	cfg.TestParseRecord = layout.parseRecord
+	cfg.TrainParseRecord = layout.parseRecord
+	return nil
+}
+
+// csvColumnCount returns the number of columns in the first record of a CSV file.
+func csvColumnCount(filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("opening file: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	record, err := csv.NewReader(bufio.NewReader(f)).Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading first record: %v", err)
+	}
+	return len(record), nil
+}
+
+// csvLayout assigns the columns of a CSV dataset to the inputs and targets of a network.
+type csvLayout struct {
+	columnCount int
+	inputs      []int
+	targets     []int
+	labels      map[string]int // output index of each label name when the target column holds label sets
+	delimiter   string
+	heads       []network.HeadConfig
+	headColumns map[string][]int
+}
+
+// parseRecord parses the input columns of a record followed by the target columns, then the target columns of each head,
+// one-hot encoding a single class index column for heads with more than one output.
+func (l csvLayout) parseRecord(record []string) (inputs, targets []float64, err error) {
+	if len(record) != l.columnCount {
+		return nil, nil, fmt.Errorf("mismatched record: %d values, expecting %d", len(record), l.columnCount)
+	}
+	inputs = make([]float64, len(l.inputs))
+	for i, c := range l.inputs {
+		if inputs[i], err = strconv.ParseFloat(record[c], 64); err != nil {
+			return nil, nil, fmt.Errorf("parse input column %d: %v", c, err)
+		}
+	}
+	if l.labels != nil {
+		if targets, err = l.parseLabels(record[l.targets[0]]); err != nil {
+			return nil, nil, fmt.Errorf("parse label column %d: %v", l.targets[0], err)
+		}
+	} else {
+		for _, c := range l.targets {
+			v, err := strconv.ParseFloat(record[c], 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse target column %d: %v", c, err)
+			}
+			targets = append(targets, v)
+		}
+	}
+	for _, h := range l.heads {
+		cols := l.headColumns[h.Name]
+		if len(cols) == 1 && h.Size > 1 {
+			class, err := strconv.Atoi(record[cols[0]])
+			if err != nil || class < 0 || class >= h.Size {
+				return nil, nil, fmt.Errorf("head '%s' column %d class index '%s' must be from 0 to %d", h.Name, cols[0], record[cols[0]], h.Size-1)
+			}
+			oneHot := make([]float64, h.Size)
+			oneHot[class] = 1
+			targets = append(targets, oneHot...)
+			continue
+		}
+		for _, c := range cols {
+			v, err := strconv.ParseFloat(record[c], 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse head '%s' column %d: %v", h.Name, c, err)
+			}
+			targets = append(targets, v)
+		}
+	}
+	return inputs, targets, nil
+}
+
+// parseLabels encodes a delimiter-separated set of label names or zero-based label indices as a target of 1 for each label in the set.
+func (l csvLayout) parseLabels(value string) ([]float64, error) {
+	targets := make([]float64, len(l.labels))
+	for _, s := range strings.Split(value, l.delimiter) {
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			continue
+		}
+		i, ok := l.labels[trimmed]
+		if !ok {
+			var err error
+			if i, err = strconv.Atoi(trimmed); err != nil || i < 0 || i >= len(l.labels) {
+				return nil, fmt.Errorf("unknown label '%s'", trimmed)
+			}
+		}
+		targets[i] = 1
+	}
+	return targets, nil
+}
@@ -1,27 +1,13 @@
 package main
 
 import (
-	"fmt"
-	"strconv"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
 )
 
 const (
-	irisInputCount  = 4
-	irisOutputCount = 3
+	irisInputCount = 4
 )
 
-func irisPreset(cfg *runConfig) error {
-	cfg.InputCount = irisInputCount
-	cfg.HiddenLayerCounts = []int{2}
-	cfg.OutputCount = irisOutputCount
-	cfg.TestLogBatch = 1
-	cfg.TrainLogBatch = 100
-	cfg.TestParseRecord = irisParseRecord
-	cfg.TrainParseRecord = irisParseRecord
-	cfg.Epochs = 200
-	return nil
-}
-
 // irisColMax maps the input column to the maximum value in the training data so it can be normalized between 0 and 1
 var irisColMax = map[int]float64{
 	0: 8,
@@ -30,42 +16,31 @@ var irisColMax = map[int]float64{
 	3: 2.5,
 }
 
-// irisLabels maps the data label to the output neuron index
-var irisLabels = map[string]int{
-	"Iris-setosa":     0,
-	"Iris-versicolor": 1,
-	"Iris-virginica":  2,
-}
+// irisLabels are the data labels in the order of the output neurons
+var irisLabels = []string{"Iris-setosa", "Iris-versicolor", "Iris-virginica"}
 
-func irisParseRecord(record []string) (inputs, targets []float64, err error) {
-	inputs = make([]float64, irisInputCount)
-	for i := 0; i < irisInputCount; i++ { // ignore last column (which is the label)
-		x, err := strconv.ParseFloat(record[i], 64)
-		if err != nil {
-			return nil, nil, fmt.Errorf("parse input: %v", err)
-		}
-		max, ok := irisColMax[i]
-		if !ok {
-			return nil, nil, fmt.Errorf("invalid index %d", i)
-		}
-		inputs[i] = (x / max * 0.99) + 0.01
-	}
-	targets, err = irisTrainingTargets(record[irisInputCount]) // last column is the label
-	if err != nil {
-		return nil, nil, err
+func irisPreset(cfg *runConfig) error {
+	if len(cfg.HiddenLayerCounts) == 0 && cfg.LayerSpec == "" {
+		cfg.HiddenLayerCounts = []int{2}
 	}
-	return inputs, targets, nil
+	cfg.TestLogBatch = 1
+	cfg.TrainLogBatch = 100
+	cfg.Pipeline = irisPipeline()
+	cfg.InputCount = preprocess.Width(cfg.Pipeline.Inputs)
+	cfg.OutputCount = preprocess.Width(cfg.Pipeline.Targets)
+	cfg.Epochs = 200
+	return nil
 }
 
-func irisTrainingTargets(target string) ([]float64, error) {
-	targets := make([]float64, irisOutputCount)
-	for i := range targets {
-		targets[i] = 0.01
+// irisPipeline scales each measurement column by its maximum in irisColMax to between 0.01 and 1,
+// and one-hot encodes the label in the last column as 0.99 for the label and 0.01 for the others.
+// Its steps are fixed, so it also reads datasets for models saved before pipelines were stored.
+func irisPipeline() *preprocess.Pipeline {
+	p := &preprocess.Pipeline{
+		Targets: []preprocess.Column{{Index: irisInputCount, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Fitted: true, Categories: irisLabels, Low: 0.01, High: 0.99}}}},
 	}
-	x, ok := irisLabels[target]
-	if !ok {
-		return nil, fmt.Errorf("unknown target label '%s'", target)
+	for i := 0; i < irisInputCount; i++ {
+		p.Inputs = append(p.Inputs, preprocess.Column{Index: i, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: true, Max: irisColMax[i], Low: 0.01, High: 1}}})
 	}
-	targets[x] = 0.99
-	return targets, nil
+	return p
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"fmt"
 	"strconv"
+
+	"github.com/benjohns1/neural-net-go/network"
+	"github.com/benjohns1/neural-net-go/network/preprocess"
 )
 
 const (
@@ -10,43 +12,38 @@ const (
 	mnistOutputCount = 10
 )
 
+var mnistInputShape = network.ImageShape{Channels: 1, Height: 28, Width: 28}
+
 func mnistPreset(cfg *runConfig) error {
 	cfg.InputCount = mnistInputCount
-	cfg.HiddenLayerCounts = []int{100}
+	if cfg.InputShape == (network.ImageShape{}) {
+		cfg.InputShape = mnistInputShape
+	}
+	if len(cfg.HiddenLayerCounts) == 0 && cfg.LayerSpec == "" {
+		cfg.HiddenLayerCounts = []int{100}
+	}
 	cfg.OutputCount = mnistOutputCount
 	cfg.TestLogBatch = 1000
 	cfg.TrainLogBatch = 10000
-	cfg.TestParseRecord = parseMnistRecord
-	cfg.TrainParseRecord = parseMnistRecord
+	cfg.Pipeline = mnistPipeline()
 	cfg.Epochs = 2
 	return nil
 }
 
-func parseMnistRecord(record []string) (inputs, targets []float64, err error) {
-	inputs = make([]float64, mnistInputCount)
-	for i := range inputs {
-		x, err := strconv.ParseFloat(record[i+1], 64) // ignore first column (which is the label)
-		if err != nil {
-			return nil, nil, fmt.Errorf("parse input: %v", err)
-		}
-		inputs[i] = (x / 255.0 * 0.99) + 0.01
+// mnistPipeline scales each pixel column from 0-255 to 0.01-1, and one-hot encodes the digit label in the first column
+// as 0.99 for the digit and 0.01 for the others. Its steps are fixed, so it also reads datasets for models saved
+// before pipelines were stored.
+func mnistPipeline() *preprocess.Pipeline {
+	digits := make([]string, mnistOutputCount)
+	for i := range digits {
+		digits[i] = strconv.Itoa(i)
 	}
-	targets, err = mnistTrainingTargets(record[0]) // first column is the label
-	if err != nil {
-		return nil, nil, err
-	}
-	return inputs, targets, nil
-}
-
-func mnistTrainingTargets(target string) ([]float64, error) {
-	targets := make([]float64, mnistOutputCount)
-	for i := range targets {
-		targets[i] = 0.01
+	p := &preprocess.Pipeline{
+		Inputs:  make([]preprocess.Column, mnistInputCount),
+		Targets: []preprocess.Column{{Index: 0, Steps: []preprocess.Step{{Type: preprocess.StepTypeOneHot, Fitted: true, Categories: digits, Low: 0.01, High: 0.99}}}},
 	}
-	x, err := strconv.Atoi(target)
-	if err != nil {
-		return nil, fmt.Errorf("target parse: %v", err)
+	for i := range p.Inputs {
+		p.Inputs[i] = preprocess.Column{Index: i + 1, Steps: []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: true, Min: 0, Max: 255, Low: 0.01, High: 1}}}
 	}
-	targets[x] = 0.99
-	return targets, nil
+	return p
 }
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/benjohns1/neural-net-go/network/preprocess"
+	"gopkg.in/yaml.v3"
+)
+
+// Column roles, types, normalizations and categorical encodings of a dataset schema.
+const (
+	roleFeature = "feature"
+	roleLabel   = "label"
+	roleIgnore  = "ignore"
+
+	columnNumeric     = "numeric"
+	columnCategorical = "categorical"
+	columnBoolean     = "boolean"
+
+	normalizeNone   = "none"
+	normalizeMinMax = "minmax"
+	normalizeZScore = "zscore"
+	normalizeRobust = "robust"
+	normalizeLog    = "log"
+
+	encodeOneHot = "onehot"
+	encodeLabel  = "label"
+)
+
+// datasetSchema declares how to read each column of a CSV dataset, in order.
+type datasetSchema struct {
+	Columns []schemaColumn
+}
+
+// schemaColumn declares the role of a dataset column, feature, label or ignore, and for features and labels its type,
+// numeric, categorical or boolean. Numeric features may be normalized, by 'minmax' to [0, 1] from Min and Max,
+// by 'zscore' from Mean and Std, by 'robust' from Median and IQR, or by 'log' to log(1+x). Normalization parameters
+// left at zero are fitted to the training dataset and stored in the model. Categorical columns are one-hot encoded
+// over their Categories, or categorical features with the 'label' Encoding encoded as the category index,
+// and categorical labels with a Delimiter hold a set of categories encoded as a target of 1 for each.
+type schemaColumn struct {
+	Name          string
+	Role          string
+	Type          string
+	Normalization string
+	Min           float64
+	Max           float64
+	Mean          float64
+	Std           float64
+	Median        float64
+	IQR           float64
+	Categories    schemaStrings
+	Encoding      string
+	Delimiter     string
+}
+
+// schemaStrings is a list of strings that also accepts numbers and booleans, like the categories [0, 1],
+// keeping their text as written so the category 1.0 does not become 1.
+type schemaStrings []string
+
+func (s *schemaStrings) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return err
+	}
+	*s = make(schemaStrings, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			(*s)[i] = v
+		case json.Number:
+			(*s)[i] = v.String()
+		case bool:
+			(*s)[i] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("item %d must be a string, number or boolean", i)
+		}
+	}
+	return nil
+}
+
+func (s *schemaStrings) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: must be a list", value.Line)
+	}
+	*s = make(schemaStrings, len(value.Content))
+	for i, item := range value.Content {
+		if item.Kind != yaml.ScalarNode || item.Tag == "!!null" {
+			return fmt.Errorf("line %d: item %d must be a string, number or boolean", item.Line, i)
+		}
+		(*s)[i] = item.Value
+	}
+	return nil
+}
+
+// loadSchema reads a dataset schema from a JSON file, or a YAML file with a .yaml or .yml extension.
+func loadSchema(filename string) (datasetSchema, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return datasetSchema{}, fmt.Errorf("reading schema: %v", err)
+	}
+	var schema datasetSchema
+	if ext := strings.ToLower(filepath.Ext(filename)); ext == ".yaml" || ext == ".yml" {
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		if err := d.Decode(&schema); err != nil {
+			return datasetSchema{}, fmt.Errorf("parsing YAML schema: %v", err)
+		}
+	} else {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&schema); err != nil {
+			return datasetSchema{}, fmt.Errorf("parsing schema: %v", err)
+		}
+	}
+	if err := schema.validate(); err != nil {
+		return datasetSchema{}, err
+	}
+	return schema, nil
+}
+
+func (s datasetSchema) validate() error {
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("schema must declare at least one column")
+	}
+	features, labels := 0, 0
+	for i, c := range s.Columns {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("schema column %d '%s': %v", i, c.Name, err)
+		}
+		switch c.Role {
+		case roleFeature:
+			features++
+		case roleLabel:
+			labels++
+		}
+	}
+	if features == 0 || labels == 0 {
+		return fmt.Errorf("schema must have at least one feature and one label column, got %d features and %d labels", features, labels)
+	}
+	return nil
+}
+
+func (c schemaColumn) validate() error {
+	switch c.Role {
+	case roleIgnore:
+		return nil
+	case roleFeature, roleLabel:
+	default:
+		return fmt.Errorf("unknown role '%s', must be '%s', '%s' or '%s'", c.Role, roleFeature, roleLabel, roleIgnore)
+	}
+	switch c.Type {
+	case columnNumeric:
+	case columnCategorical:
+		if len(c.Categories) == 0 {
+			return fmt.Errorf("categorical column must list its categories")
+		}
+		seen := make(map[string]bool, len(c.Categories))
+		for _, category := range c.Categories {
+			if seen[category] {
+				return fmt.Errorf("category '%s' is listed more than once", category)
+			}
+			seen[category] = true
+		}
+	case columnBoolean:
+	default:
+		return fmt.Errorf("unknown type '%s', must be '%s', '%s' or '%s'", c.Type, columnNumeric, columnCategorical, columnBoolean)
+	}
+	if c.Delimiter != "" && (c.Role != roleLabel || c.Type != columnCategorical) {
+		return fmt.Errorf("only categorical labels can have a label set delimiter")
+	}
+	switch c.Encoding {
+	case "", encodeOneHot:
+	case encodeLabel:
+		if c.Role != roleFeature || c.Type != columnCategorical {
+			return fmt.Errorf("only categorical features can be label encoded")
+		}
+	default:
+		return fmt.Errorf("unknown encoding '%s', must be '%s' or '%s'", c.Encoding, encodeOneHot, encodeLabel)
+	}
+	switch c.Normalization {
+	case "", normalizeNone:
+		return nil
+	case normalizeMinMax, normalizeZScore, normalizeRobust, normalizeLog:
+		if c.Role != roleFeature || c.Type != columnNumeric {
+			return fmt.Errorf("only numeric features can be normalized")
+		}
+	default:
+		return fmt.Errorf("unknown normalization '%s', must be '%s', '%s', '%s', '%s' or '%s'", c.Normalization, normalizeNone, normalizeMinMax, normalizeZScore, normalizeRobust, normalizeLog)
+	}
+	switch {
+	case c.Normalization == normalizeMinMax && c.Max <= c.Min && (c.Min != 0 || c.Max != 0):
+		return fmt.Errorf("minmax normalization max %v must be greater than min %v", c.Max, c.Min)
+	case c.Normalization == normalizeZScore && (c.Std < 0 || c.Std == 0 && c.Mean != 0):
+		return fmt.Errorf("zscore normalization std %v must be positive", c.Std)
+	case c.Normalization == normalizeRobust && (c.IQR < 0 || c.IQR == 0 && c.Median != 0):
+		return fmt.Errorf("robust normalization IQR %v must be positive", c.IQR)
+	}
+	return nil
+}
+
+// pipeline returns the preprocessing pipeline reading the feature columns as inputs and the label columns as targets,
+// with normalization steps fixed by their declared parameters or left to be fitted.
+func (s datasetSchema) pipeline() *preprocess.Pipeline {
+	p := &preprocess.Pipeline{}
+	for i, c := range s.Columns {
+		column := preprocess.Column{Index: i, Steps: c.steps()}
+		switch c.Role {
+		case roleFeature:
+			p.Inputs = append(p.Inputs, column)
+		case roleLabel:
+			p.Targets = append(p.Targets, column)
+		}
+	}
+	return p
+}
+
+// steps returns the preprocessing steps encoding a column value as its network inputs or targets.
+func (c schemaColumn) steps() []preprocess.Step {
+	switch c.Type {
+	case columnBoolean:
+		return []preprocess.Step{{Type: preprocess.StepTypeBoolean, Fitted: true}}
+	case columnCategorical:
+		step := preprocess.Step{Type: preprocess.StepTypeOneHot, Fitted: true, Categories: c.Categories, Delimiter: c.Delimiter}
+		if c.Encoding == encodeLabel {
+			step.Type = preprocess.StepTypeLabel
+		}
+		return []preprocess.Step{step}
+	}
+	switch c.Normalization {
+	case normalizeMinMax:
+		return []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: c.Max > c.Min, Min: c.Min, Max: c.Max}}
+	case normalizeZScore:
+		return []preprocess.Step{{Type: preprocess.StepTypeZScore, Fitted: c.Std > 0, Mean: c.Mean, Std: c.Std}}
+	case normalizeRobust:
+		return []preprocess.Step{{Type: preprocess.StepTypeRobust, Fitted: c.IQR > 0, Median: c.Median, IQR: c.IQR}}
+	case normalizeLog:
+		return []preprocess.Step{{Type: preprocess.StepTypeLog, Fitted: true}}
+	}
+	return nil
+}
+
+// schemaPreset reads a dataset by the schema set with -schema, inferring the input and output counts.
+func schemaPreset(cfg *runConfig) error {
+	schema, err := loadSchema(cfg.SchemaFile)
+	if err != nil {
+		return err
+	}
+	cfg.Pipeline = schema.pipeline()
+	cfg.InputCount = preprocess.Width(cfg.Pipeline.Inputs)
+	cfg.OutputCount = preprocess.Width(cfg.Pipeline.Targets)
+	cfg.TestLogBatch = 1000
+	cfg.TrainLogBatch = 1000
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/network/preprocess"
+)
+
+func writeSchema(t *testing.T, name, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadSchema_YAMLEqualsJSON(t *testing.T) {
+	yamlSchema, err := loadSchema("datasets/iris.schema.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonSchema, err := loadSchema(writeSchema(t, "iris.schema.json", `{"Columns": [
+		{"Name": "sepal_length", "Role": "feature", "Type": "numeric", "Normalization": "minmax", "Min": 4.3, "Max": 7.9},
+		{"Name": "sepal_width", "Role": "feature", "Type": "numeric", "Normalization": "minmax", "Min": 2.0, "Max": 4.4},
+		{"Name": "petal_length", "Role": "feature", "Type": "numeric", "Normalization": "minmax", "Min": 1.0, "Max": 6.9},
+		{"Name": "petal_width", "Role": "feature", "Type": "numeric", "Normalization": "minmax", "Min": 0.1, "Max": 2.5},
+		{"Name": "species", "Role": "label", "Type": "categorical", "Categories": ["Iris-setosa", "Iris-versicolor", "Iris-virginica"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(yamlSchema, jsonSchema) {
+		t.Errorf("loadSchema() YAML = %+v, want the JSON schema %+v", yamlSchema, jsonSchema)
+	}
+}
+
+func TestLoadSchema_Categories(t *testing.T) {
+	const columns = "columns:\n  - role: feature\n    type: numeric\n  - role: label\n    type: categorical\n"
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    schemaStrings
+	}{
+		{
+			name:    "YAML flow sequence",
+			file:    "schema.yaml",
+			content: columns + "    categories: [1.0, 2.0, 007, True, FALSE, 'x']\n",
+			want:    schemaStrings{"1.0", "2.0", "007", "True", "FALSE", "x"},
+		},
+		{
+			name:    "YAML block sequence",
+			file:    "schema.yml",
+			content: columns + "    categories:\n      - 1.0\n      - 007\n      - True\n      - 'null'\n",
+			want:    schemaStrings{"1.0", "007", "True", "null"},
+		},
+		{
+			name:    "JSON",
+			file:    "schema.json",
+			content: `{"columns": [{"role": "feature", "type": "numeric"}, {"role": "label", "type": "categorical", "categories": [1.0, 2e1, "007", false]}]}`,
+			want:    schemaStrings{"1.0", "2e1", "007", "false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := loadSchema(writeSchema(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schema.Columns[1].Categories; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("categories = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSchema_Error(t *testing.T) {
+	const columns = "columns:\n  - role: feature\n    type: numeric\n"
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "invalid YAML", file: "schema.yaml", content: "columns:\n\t- role: feature"},
+		{name: "duplicate YAML key", file: "schema.yaml", content: columns + "    role: label\n"},
+		{name: "unknown YAML field", file: "schema.yaml", content: columns + "    scale: 2\n"},
+		{name: "YAML null category", file: "schema.yaml", content: columns + "  - role: label\n    type: categorical\n    categories: [a, null]\n"},
+		{name: "YAML categories not a list", file: "schema.yaml", content: columns + "  - role: label\n    type: categorical\n    categories: a\n"},
+		{name: "invalid JSON", file: "schema.json", content: `{"columns": [`},
+		{name: "unknown JSON field", file: "schema.json", content: `{"columns": [{"role": "feature", "type": "numeric", "scale": 2}]}`},
+		{name: "JSON null category", file: "schema.json", content: `{"columns": [{"role": "label", "type": "categorical", "categories": [null]}]}`},
+		{name: "invalid schema", file: "schema.yaml", content: columns},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadSchema(writeSchema(t, tt.file, tt.content)); err == nil {
+				t.Errorf("loadSchema() error = nil, want error")
+			}
+		})
+	}
+	if _, err := loadSchema(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("loadSchema() of a missing file error = nil, want error")
+	}
+}
+
+func TestDatasetSchema_Validate(t *testing.T) {
+	feature := schemaColumn{Role: roleFeature, Type: columnNumeric}
+	label := schemaColumn{Role: roleLabel, Type: columnCategorical, Categories: schemaStrings{"a", "b"}}
+	tests := []struct {
+		name    string
+		columns []schemaColumn
+		wantErr bool
+	}{
+		{name: "valid", columns: []schemaColumn{feature, {Role: roleIgnore, Type: "anything"}, label}},
+		{name: "fitted normalizations", columns: []schemaColumn{
+			{Role: roleFeature, Type: columnNumeric, Normalization: normalizeMinMax},
+			{Role: roleFeature, Type: columnNumeric, Normalization: normalizeZScore},
+			{Role: roleFeature, Type: columnNumeric, Normalization: normalizeRobust},
+			{Role: roleFeature, Type: columnNumeric, Normalization: normalizeLog},
+			{Role: roleFeature, Type: columnCategorical, Categories: schemaStrings{"x"}, Encoding: encodeLabel},
+			{Role: roleLabel, Type: columnCategorical, Categories: schemaStrings{"a", "b"}, Delimiter: "|"},
+		}},
+		{name: "no columns", wantErr: true},
+		{name: "no features", columns: []schemaColumn{label}, wantErr: true},
+		{name: "no labels", columns: []schemaColumn{feature}, wantErr: true},
+		{name: "unknown role", columns: []schemaColumn{feature, label, {Role: "target", Type: columnNumeric}}, wantErr: true},
+		{name: "unknown type", columns: []schemaColumn{feature, label, {Role: roleFeature, Type: "text"}}, wantErr: true},
+		{name: "categorical without categories", columns: []schemaColumn{feature, {Role: roleLabel, Type: columnCategorical}}, wantErr: true},
+		{name: "duplicate category", columns: []schemaColumn{feature, {Role: roleLabel, Type: columnCategorical, Categories: schemaStrings{"a", "a"}}}, wantErr: true},
+		{name: "delimiter on a feature", columns: []schemaColumn{{Role: roleFeature, Type: columnCategorical, Categories: schemaStrings{"x"}, Delimiter: "|"}, label}, wantErr: true},
+		{name: "delimiter on a numeric label", columns: []schemaColumn{feature, {Role: roleLabel, Type: columnNumeric, Delimiter: "|"}}, wantErr: true},
+		{name: "label encoded label", columns: []schemaColumn{feature, {Role: roleLabel, Type: columnCategorical, Categories: schemaStrings{"a"}, Encoding: encodeLabel}}, wantErr: true},
+		{name: "unknown encoding", columns: []schemaColumn{{Role: roleFeature, Type: columnCategorical, Categories: schemaStrings{"x"}, Encoding: "binary"}, label}, wantErr: true},
+		{name: "normalized label", columns: []schemaColumn{feature, {Role: roleLabel, Type: columnNumeric, Normalization: normalizeMinMax}}, wantErr: true},
+		{name: "normalized boolean", columns: []schemaColumn{{Role: roleFeature, Type: columnBoolean, Normalization: normalizeZScore}, label}, wantErr: true},
+		{name: "unknown normalization", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: "sqrt"}, label}, wantErr: true},
+		{name: "minmax max below min", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: normalizeMinMax, Min: 2, Max: 1}, label}, wantErr: true},
+		{name: "zscore negative std", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: normalizeZScore, Std: -1}, label}, wantErr: true},
+		{name: "zscore mean without std", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: normalizeZScore, Mean: 1}, label}, wantErr: true},
+		{name: "robust negative IQR", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: normalizeRobust, IQR: -1}, label}, wantErr: true},
+		{name: "robust median without IQR", columns: []schemaColumn{{Role: roleFeature, Type: columnNumeric, Normalization: normalizeRobust, Median: 1}, label}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (datasetSchema{Columns: tt.columns}).validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchemaColumn_Steps(t *testing.T) {
+	tests := []struct {
+		name   string
+		column schemaColumn
+		want   []preprocess.Step
+	}{
+		{name: "numeric", column: schemaColumn{Type: columnNumeric}},
+		{name: "fixed minmax", column: schemaColumn{Type: columnNumeric, Normalization: normalizeMinMax, Min: 1, Max: 3}, want: []preprocess.Step{{Type: preprocess.StepTypeMinMax, Fitted: true, Min: 1, Max: 3}}},
+		{name: "fitted minmax", column: schemaColumn{Type: columnNumeric, Normalization: normalizeMinMax}, want: []preprocess.Step{{Type: preprocess.StepTypeMinMax}}},
+		{name: "fixed zscore", column: schemaColumn{Type: columnNumeric, Normalization: normalizeZScore, Mean: 1, Std: 2}, want: []preprocess.Step{{Type: preprocess.StepTypeZScore, Fitted: true, Mean: 1, Std: 2}}},
+		{name: "fitted robust", column: schemaColumn{Type: columnNumeric, Normalization: normalizeRobust}, want: []preprocess.Step{{Type: preprocess.StepTypeRobust}}},
+		{name: "log", column: schemaColumn{Type: columnNumeric, Normalization: normalizeLog}, want: []preprocess.Step{{Type: preprocess.StepTypeLog, Fitted: true}}},
+		{name: "boolean", column: schemaColumn{Type: columnBoolean}, want: []preprocess.Step{{Type: preprocess.StepTypeBoolean, Fitted: true}}},
+		{
+			name:   "categorical label set",
+			column: schemaColumn{Type: columnCategorical, Categories: schemaStrings{"a", "b"}, Delimiter: "|"},
+			want:   []preprocess.Step{{Type: preprocess.StepTypeOneHot, Fitted: true, Categories: []string{"a", "b"}, Delimiter: "|"}},
+		},
+		{
+			name:   "label encoded categorical",
+			column: schemaColumn{Type: columnCategorical, Categories: schemaStrings{"a", "b"}, Encoding: encodeLabel},
+			want:   []preprocess.Step{{Type: preprocess.StepTypeLabel, Fitted: true, Categories: []string{"a", "b"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.column.steps(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaPreset(t *testing.T) {
+	cfg := &runConfig{SchemaFile: writeSchema(t, "schema.yaml", `columns:
+  - role: ignore
+  - role: feature
+    type: numeric
+    normalization: zscore
+  - role: feature
+    type: categorical
+    categories: [a, b, c]
+  - role: label
+    type: categorical
+    categories: [x, y]
+`)}
+	if err := schemaPreset(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.InputCount != 4 || cfg.OutputCount != 2 {
+		t.Errorf("schemaPreset() counts = %d, %d, want 4, 2", cfg.InputCount, cfg.OutputCount)
+	}
+	if len(cfg.Pipeline.Inputs) != 2 || cfg.Pipeline.Inputs[0].Index != 1 || cfg.Pipeline.Inputs[1].Index != 2 ||
+		len(cfg.Pipeline.Targets) != 1 || cfg.Pipeline.Targets[0].Index != 3 {
+		t.Errorf("schemaPreset() pipeline = %+v, want inputs from columns 1 and 2 and targets from column 3", cfg.Pipeline)
+	}
+	if cfg.Pipeline.Fitted() {
+		t.Errorf("schemaPreset() pipeline is fitted, want the zscore step left to be fitted")
+	}
+}
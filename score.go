@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/benjohns1/neural-net-go/network"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// scorer accumulates how well predicted outputs match their targets over a test dataset.
+type scorer interface {
+	add(outputs *mat.Dense, targets []float64) error
+	report(name string)
+	// accuracy returns the fraction of correct predictions, or NaN when the outputs are not scored by accuracy.
+	accuracy() float64
+}
+
+// newScorer returns a scorer for the outputs of a network, scoring each head on its own for multi-task networks.
+func newScorer(cfg network.Config) scorer {
+	if len(cfg.Heads) > 0 {
+		return newHeadsScorer(cfg.Heads)
+	}
+	switch cfg.Task {
+	case network.TaskTypeRegression:
+		return &regressionScorer{}
+	case network.TaskTypeMultiLabel:
+		return &multiLabelScorer{thresholds: cfg.Thresholds}
+	}
+	return &classScorer{}
+}
+
+// classScorer counts predictions whose highest output matches the highest target.
+type classScorer struct {
+	score int
+	total int
+}
+
+func (s *classScorer) add(outputs *mat.Dense, targets []float64) error {
+	if getPrediction(outputs) == getTarget(targets) {
+		s.score++
+	}
+	s.total++
+	return nil
+}
+
+func (s *classScorer) accuracy() float64 {
+	return float64(s.score) / float64(s.total)
+}
+
+func (s *classScorer) report(name string) {
+	log.Printf("%sScored %d/%d correct predictions: %0.2f%%", name, s.score, s.total, float32(s.score)*100/float32(s.total))
+}
+
+// regressionScorer accumulates the errors of continuous predictions, reporting R² as the mean over the outputs.
+type regressionScorer struct {
+	total         int
+	sumSquared    float64
+	sumAbsolute   float64
+	sumPercent    float64
+	percentCount  int
+	targetSums    []float64
+	targetSquares []float64
+	squaredErrors []float64
+}
+
+func (s *regressionScorer) add(outputs *mat.Dense, targets []float64) error {
+	if rows, _ := outputs.Dims(); rows != len(targets) {
+		return fmt.Errorf("output count %d must equal target count %d", rows, len(targets))
+	}
+	if s.targetSums == nil {
+		s.targetSums = make([]float64, len(targets))
+		s.targetSquares = make([]float64, len(targets))
+		s.squaredErrors = make([]float64, len(targets))
+	}
+	for i, t := range targets {
+		d := outputs.At(i, 0) - t
+		s.sumSquared += d * d
+		s.sumAbsolute += math.Abs(d)
+		if t != 0 {
+			s.sumPercent += math.Abs(d / t)
+			s.percentCount++
+		}
+		s.targetSums[i] += t
+		s.targetSquares[i] += t * t
+		s.squaredErrors[i] += d * d
+	}
+	s.total++
+	return nil
+}
+
+func (s *regressionScorer) accuracy() float64 {
+	return math.NaN()
+}
+
+func (s *regressionScorer) report(name string) {
+	values := float64(s.total * len(s.targetSums))
+	r2 := 0.0
+	for i, sum := range s.targetSums {
+		variance := s.targetSquares[i] - sum*sum/float64(s.total)
+		if variance > 0 {
+			r2 += 1 - s.squaredErrors[i]/variance
+		}
+	}
+	r2 /= float64(len(s.targetSums))
+	mape := math.NaN()
+	if s.percentCount > 0 {
+		mape = 100 * s.sumPercent / float64(s.percentCount)
+	}
+	log.Printf("%sRMSE %f, MAE %f, R² %f, MAPE %0.2f%% over %d predictions", name, math.Sqrt(s.sumSquared/values), s.sumAbsolute/values, r2, mape, s.total)
+}
+
+// multiLabelScorer compares the labels predicted by the decision threshold of each output with the target labels.
+type multiLabelScorer struct {
+	thresholds []float64
+	total      int
+	exact      int
+	wrong      int
+	truePos    []int
+	falsePos   []int
+	falseNeg   []int
+}
+
+func (s *multiLabelScorer) add(outputs *mat.Dense, targets []float64) error {
+	if rows, _ := outputs.Dims(); rows != len(targets) {
+		return fmt.Errorf("output count %d must equal target count %d", rows, len(targets))
+	}
+	if s.truePos == nil {
+		s.thresholds = network.Thresholds(s.thresholds, len(targets))
+		s.truePos, s.falsePos, s.falseNeg = make([]int, len(targets)), make([]int, len(targets)), make([]int, len(targets))
+	}
+	wrong := 0
+	for i, t := range targets {
+		predicted, actual := outputs.At(i, 0) >= s.thresholds[i], t >= 0.5
+		switch {
+		case predicted && actual:
+			s.truePos[i]++
+		case predicted:
+			s.falsePos[i]++
+			wrong++
+		case actual:
+			s.falseNeg[i]++
+			wrong++
+		}
+	}
+	if wrong == 0 {
+		s.exact++
+	}
+	s.wrong += wrong
+	s.total++
+	return nil
+}
+
+// accuracy returns the subset accuracy, the fraction of predictions with every label correct.
+func (s *multiLabelScorer) accuracy() float64 {
+	return float64(s.exact) / float64(s.total)
+}
+
+// report logs the Hamming loss, subset accuracy and F1 scores, the macro F1 averaging over the labels
+// that were predicted or present at least once.
+func (s *multiLabelScorer) report(name string) {
+	var truePos, falsePos, falseNeg, macro float64
+	labels := 0
+	for i := range s.truePos {
+		tp, fp, fn := float64(s.truePos[i]), float64(s.falsePos[i]), float64(s.falseNeg[i])
+		truePos, falsePos, falseNeg = truePos+tp, falsePos+fp, falseNeg+fn
+		if tp+fp+fn > 0 {
+			macro += f1(tp, fp, fn)
+			labels++
+		}
+	}
+	if labels > 0 {
+		macro /= float64(labels)
+	}
+	log.Printf("%sHamming loss %f, subset accuracy %0.2f%%, micro F1 %f, macro F1 %f over %d predictions", name,
+		float64(s.wrong)/float64(s.total*len(s.truePos)), float32(s.exact)*100/float32(s.total), f1(truePos, falsePos, falseNeg), macro, s.total)
+}
+
+// f1 computes the harmonic mean of precision and recall from true positive, false positive and false negative counts.
+func f1(truePos, falsePos, falseNeg float64) float64 {
+	if truePos == 0 {
+		return 0
+	}
+	return 2 * truePos / (2*truePos + falsePos + falseNeg)
+}
+
+// headsScorer scores the outputs of each head of a multi-task network, as labels for sigmoid heads with binary cross-entropy,
+// as classes for other heads with more than one output and as regression otherwise.
+type headsScorer struct {
+	heads   []network.HeadConfig
+	scorers []scorer
+}
+
+func newHeadsScorer(heads []network.HeadConfig) *headsScorer {
+	s := &headsScorer{heads: heads, scorers: make([]scorer, len(heads))}
+	for i, h := range heads {
+		switch {
+		case h.Loss == network.LossTypeBinaryCrossEntropy && h.Activation == network.ActivationTypeSigmoid:
+			s.scorers[i] = &multiLabelScorer{}
+		case h.Size == 1:
+			s.scorers[i] = &regressionScorer{}
+		default:
+			s.scorers[i] = &classScorer{}
+		}
+	}
+	return s
+}
+
+func (s *headsScorer) add(outputs *mat.Dense, targets []float64) error {
+	offset := 0
+	for i, h := range s.heads {
+		if offset+h.Size > len(targets) {
+			return fmt.Errorf("target count %d is less than the total head size", len(targets))
+		}
+		headOutputs := mat.DenseCopyOf(outputs.Slice(offset, offset+h.Size, 0, 1))
+		if err := s.scorers[i].add(headOutputs, targets[offset:offset+h.Size]); err != nil {
+			return err
+		}
+		offset += h.Size
+	}
+	return nil
+}
+
+func (s *headsScorer) accuracy() float64 {
+	return math.NaN()
+}
+
+func (s *headsScorer) report(name string) {
+	for i, h := range s.heads {
+		s.scorers[i].report(fmt.Sprintf("%sHead '%s': ", name, h.Name))
+	}
+}
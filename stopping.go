@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Validation metrics monitored by early stopping.
+const (
+	monitorValidationLoss     = "val-loss"
+	monitorValidationAccuracy = "val-accuracy"
+)
+
+// earlyStopping stops training once the monitored validation metric hasn't improved by more than minDelta for patience epochs,
+// tracking the epoch with the best value so its weights can be restored.
+type earlyStopping struct {
+	monitor   string
+	patience  int
+	minDelta  float64
+	best      float64
+	bestEpoch int
+	wait      int
+}
+
+// observe records the monitored metric of an epoch, returning whether it improved on the best value so far.
+func (s *earlyStopping) observe(epoch int, loss, accuracy float64) (bool, error) {
+	value := loss
+	if s.monitor == monitorValidationAccuracy {
+		if math.IsNaN(accuracy) {
+			return false, fmt.Errorf("early stopping cannot monitor %s of networks scored without accuracy, monitor %s instead", monitorValidationAccuracy, monitorValidationLoss)
+		}
+		// Accuracy improves by increasing, so it is tracked negated like a loss.
+		value = -accuracy
+	}
+	if s.bestEpoch == 0 || value < s.best-s.minDelta {
+		s.best, s.bestEpoch, s.wait = value, epoch, 0
+		return true, nil
+	}
+	s.wait++
+	return false, nil
+}
+
+// stop reports whether training should stop, after patience epochs without improvement.
+func (s *earlyStopping) stop() bool {
+	return s.wait >= s.patience
+}
+
+// bestValue returns the best value of the monitored metric.
+func (s *earlyStopping) bestValue() float64 {
+	if s.monitor == monitorValidationAccuracy {
+		return -s.best
+	}
+	return s.best
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjohns1/neural-net-go/dataset"
+	"github.com/benjohns1/neural-net-go/network"
+)
+
+func TestEarlyStopping(t *testing.T) {
+	tests := []struct {
+		name          string
+		stopper       earlyStopping
+		losses        []float64
+		accuracies    []float64
+		wantImproved  []bool
+		wantStop      int // first epoch after which training stops, 0 for none
+		wantBestEpoch int
+		wantBest      float64
+	}{
+		{
+			name:          "should stop after patience epochs without a lower loss",
+			stopper:       earlyStopping{monitor: monitorValidationLoss, patience: 2},
+			losses:        []float64{1, 0.8, 0.9, 0.85, 0.7},
+			wantImproved:  []bool{true, true, false, false, true},
+			wantStop:      4,
+			wantBestEpoch: 5,
+			wantBest:      0.7,
+		},
+		{
+			name:          "should not count an equal loss as an improvement",
+			stopper:       earlyStopping{monitor: monitorValidationLoss, patience: 1},
+			losses:        []float64{1, 1},
+			wantImproved:  []bool{true, false},
+			wantStop:      2,
+			wantBestEpoch: 1,
+			wantBest:      1,
+		},
+		{
+			name:          "should reset the patience count on an improvement",
+			stopper:       earlyStopping{monitor: monitorValidationLoss, patience: 2},
+			losses:        []float64{1, 1.1, 0.9, 1.2, 0.8},
+			wantImproved:  []bool{true, false, true, false, true},
+			wantBestEpoch: 5,
+			wantBest:      0.8,
+		},
+		{
+			name:          "should only count a loss lower by more than min delta as an improvement",
+			stopper:       earlyStopping{monitor: monitorValidationLoss, patience: 2, minDelta: 0.1},
+			losses:        []float64{1, 0.95, 0.85, 0.8, 0.76},
+			wantImproved:  []bool{true, false, true, false, false},
+			wantStop:      5,
+			wantBestEpoch: 3,
+			wantBest:      0.85,
+		},
+		{
+			name:          "should monitor a higher accuracy as an improvement",
+			stopper:       earlyStopping{monitor: monitorValidationAccuracy, patience: 1},
+			losses:        []float64{1, 2, 3},
+			accuracies:    []float64{0.5, 0.7, 0.6},
+			wantImproved:  []bool{true, true, false},
+			wantStop:      3,
+			wantBestEpoch: 2,
+			wantBest:      0.7,
+		},
+		{
+			name:          "should only count an accuracy higher by more than min delta as an improvement",
+			stopper:       earlyStopping{monitor: monitorValidationAccuracy, patience: 3, minDelta: 0.05},
+			losses:        []float64{1, 1, 1},
+			accuracies:    []float64{0.5, 0.54, 0.56},
+			wantImproved:  []bool{true, false, true},
+			wantBestEpoch: 3,
+			wantBest:      0.56,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.stopper
+			stopped := 0
+			for i, loss := range tt.losses {
+				accuracy := math.NaN()
+				if tt.accuracies != nil {
+					accuracy = tt.accuracies[i]
+				}
+				improved, err := s.observe(i+1, loss, accuracy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if improved != tt.wantImproved[i] {
+					t.Errorf("observe() epoch %d improved = %v, want %v", i+1, improved, tt.wantImproved[i])
+				}
+				if stopped == 0 && s.stop() {
+					stopped = i + 1
+				}
+			}
+			if stopped != tt.wantStop {
+				t.Errorf("stop() first after epoch %d, want %d", stopped, tt.wantStop)
+			}
+			if s.bestEpoch != tt.wantBestEpoch || s.bestValue() != tt.wantBest {
+				t.Errorf("best epoch %d value %v, want epoch %d value %v", s.bestEpoch, s.bestValue(), tt.wantBestEpoch, tt.wantBest)
+			}
+		})
+	}
+
+	s := earlyStopping{monitor: monitorValidationAccuracy, patience: 1}
+	if _, err := s.observe(1, 1, math.NaN()); err == nil {
+		t.Errorf("observe() accuracy of a network scored without accuracy error = nil, want error")
+	}
+}
+
+func TestTrain_RestoresBestWeights(t *testing.T) {
+	filename := classDataset(t, "train.csv", 10, 10)
+	data, err := dataset.NewCSV(filename, parseClassRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The validation records have the opposite classes, so the validation loss gets worse as training fits the data.
+	flipped := func(record []string) ([]float64, []float64, error) {
+		inputs, targets, err := parseClassRecord(record)
+		return inputs, []float64{targets[1], targets[0]}, err
+	}
+	validation, err := dataset.NewCSV(filename, flipped, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := network.Config{InputCount: 1, LayerCounts: []int{3, 2}, Rate: 0.5, RandSeed: 1}
+	net, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopper := &earlyStopping{monitor: monitorValidationLoss, patience: 2}
+	if err := train(net, 20, data, validation, nil, stopper, 4, 100); err != nil {
+		t.Fatal(err)
+	}
+	epochs := stopper.bestEpoch + stopper.patience
+	if net.Epochs() != uint64(epochs) || net.Trained() != uint64(epochs*data.Len()) {
+		t.Errorf("train() trained %d records over %d epochs, want %d over %d", net.Trained(), net.Epochs(), epochs*data.Len(), epochs)
+	}
+
+	best, err := network.NewRandom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := train(best, stopper.bestEpoch, data, nil, nil, nil, 4, 100); err != nil {
+		t.Fatal(err)
+	}
+	_, got, err := validate(net, validation, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, want, err := validate(best, validation, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || got != stopper.bestValue() {
+		t.Errorf("validation loss after train() = %v, want the loss %v of the best epoch %d", got, want, stopper.bestEpoch)
+	}
+}